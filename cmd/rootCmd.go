@@ -18,16 +18,26 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime/debug"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/kastenhq/kubestr/pkg/block"
+	"github.com/kastenhq/kubestr/pkg/canary"
 	"github.com/kastenhq/kubestr/pkg/csi"
 	csitypes "github.com/kastenhq/kubestr/pkg/csi/types"
 	"github.com/kastenhq/kubestr/pkg/fio"
+	"github.com/kastenhq/kubestr/pkg/history"
 	"github.com/kastenhq/kubestr/pkg/kubestr"
 	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
@@ -58,15 +68,231 @@ var (
 	fioNodeSelector    map[string]string
 	fioCheckerFilePath string
 	fioCheckerTestName string
+	fioVolumeMode      string
+	fioPVC             string
+	fioClients         int
+	fioClientServer    bool
+	fioSharedFile      bool
+	fioHistogram       bool
+	fioLogInterval     time.Duration
+	fioLogDir          string
+	fioStatusInterval  time.Duration
+	fioTimeout         time.Duration
+	fioTolerations     []string
+	fioNodeAffinity    map[string]string
+	fioPodAffinity     map[string]string
+	fioRequests        map[string]string
+	fioLimits          map[string]string
+	fioPriorityClass   string
+	fioRuntimeClass    string
+	fioPodAnnotations  map[string]string
+	fioServiceAccount  string
+	fioPullSecrets     []string
+	fioPodOverlayFile  string
+	fioBaseSC          string
+	fioSCParams        map[string]string
+	fioMountOptions    []string
+	fioFSType          string
+	fioVars            map[string]string
+	fioVerify          bool
+	fioVerifyBacklog   int64
+	fioPrecondition    bool
+	fioAsJob           bool
+	fioRepeat          int
+	fioRepeatPause     time.Duration
+	fioMaxCV           float64
+	fioReference       bool
+	fioMemReference    bool
+	fioMemRefSize      string
+	fioSteadyState     string
+	fioSteadyStateDur  time.Duration
+	fioSteadyStateRamp time.Duration
+	fioProgressEvents  bool
+	fioAllSCs          bool
+	fioConcurrent      bool
+	fioSkipLint        bool
+	fioListTests       bool
+	fioThresholdsFile  string
+	fioMinIOPS         map[string]string
+	fioMinBW           map[string]string
+	fioMaxLatMean      map[string]string
+	fioMaxLatP99       map[string]string
 	fioCmd             = &cobra.Command{
 		Use:   "fio",
 		Short: "Runs an fio test",
 		Long:  `Run an fio test`,
 		Args:  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			if fioListTests {
+				return FioListTests(output, outfile)
+			}
+			thresholds, err := fioThresholds()
+			if err != nil {
+				return err
+			}
+			podOptions, err := fioPodOptions()
+			if err != nil {
+				return err
+			}
+			storageClasses := splitStorageClasses(storageClass)
+			timeout := fioTimeout
+			if fioRepeat > 1 {
+				// every run gets the full timeout
+				timeout = timeout*time.Duration(fioRepeat) + fioRepeatPause*time.Duration(fioRepeat-1)
+			}
+			fioArgs := fio.RunFIOArgs{
+				StorageClass:   strings.Join(storageClasses, ","),
+				Size:           fioCheckerSize,
+				Namespace:      namespace,
+				NodeSelector:   fioNodeSelector,
+				FIOJobName:     fioCheckerTestName,
+				FIOJobFilepath: fioCheckerFilePath,
+				Image:          containerImage,
+				VolumeMode:     v1.PersistentVolumeMode(fioVolumeMode),
+				PVC:            fioPVC,
+				Clients:        fioClients,
+				ClientServer:   fioClientServer,
+				SharedFile:     fioSharedFile,
+				Histogram:      fioHistogram,
+				LogInterval:    fioLogInterval,
+				StatusInterval: fioStatusInterval,
+				Vars:           fioVars,
+				Verify:         fioVerify,
+				VerifyBacklog:  fioVerifyBacklog,
+				Precondition:   fioPrecondition,
+				AsJob:          fioAsJob,
+				Repeat:         fioRepeat,
+				RepeatPause:    fioRepeatPause,
+				Pod:            podOptions,
+				Thresholds:     thresholds,
+				SkipLint:       fioSkipLint,
+			}
+			if fioBaseSC != "" {
+				fioArgs.StorageClass = fioBaseSC
+				fioArgs.Variant = &fio.StorageClassVariant{
+					Parameters:   fioSCParams,
+					MountOptions: fioMountOptions,
+					FSType:       fioFSType,
+				}
+			} else if len(fioSCParams) > 0 || len(fioMountOptions) > 0 || fioFSType != "" {
+				return fmt.Errorf("--sc-param, --mount-option and --fstype require --base-sc")
+			}
+			if cmd.Flags().Changed("max-cv") {
+				maxCV := fioMaxCV / 100
+				fioArgs.RepeatMaxCV = &maxCV
+			}
+			if fioReference {
+				fioArgs.References = append(fioArgs.References, fio.ReferenceDisk)
+			}
+			if fioMemReference {
+				fioArgs.References = append(fioArgs.References, fio.ReferenceMemory)
+				fioArgs.ReferenceSize = fioMemRefSize
+			}
+			if fioSteadyState != "" {
+				fioArgs.SteadyState = &fio.SteadyState{
+					Criterion: fioSteadyState,
+					Duration:  fioSteadyStateDur,
+					Ramp:      fioSteadyStateRamp,
+				}
+			}
+			if fioProgressEvents {
+				fioArgs.OnProgress = fio.NewProgressEventWriter(os.Stderr)
+			} else {
+				fioArgs.OnProgress = fio.NewProgressPrinter(os.Stdout)
+			}
+			if len(storageClasses) > 1 || fioAllSCs {
+				return FioComparison(timeout, output, outfile, fioLogDir, storageClasses, fioAllSCs, fioConcurrent, fioArgs)
+			}
+			ctx, cancel := interruptibleContext(timeout)
+			defer cancel()
+			return Fio(ctx, output, outfile, fioLogDir, fioArgs)
+		},
+	}
+
+	fioLintCmd = &cobra.Command{
+		Use:   "lint [fio file]",
+		Short: "Checks an fio job file without running it",
+		Long:  "Parses an fio job file, or a predefined kubestr fio test, and checks it against the volume it would run against.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			thresholds, err := fioThresholds()
+			if err != nil {
+				return err
+			}
+			fioArgs := fio.RunFIOArgs{
+				Size:       fioCheckerSize,
+				FIOJobName: fioCheckerTestName,
+				Image:      containerImage,
+				VolumeMode: v1.PersistentVolumeMode(fioVolumeMode),
+				Clients:    fioClients,
+				SharedFile: fioSharedFile,
+				Thresholds: thresholds,
+				Vars:       fioVars,
+			}
+			if len(args) > 0 {
+				fioArgs.FIOJobFilepath = args[0]
+			}
+			return FioLint(output, outfile, fioArgs)
+		},
+	}
+
+	fioAttachCmd = &cobra.Command{
+		Use:   "attach <run-id>",
+		Short: "Waits for an fio test running as a Job and reports its results",
+		Long:  "Re-attaches to an fio test started with --as-job, waits for its Job to complete, reports the results and deletes the resources of the run.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := interruptibleContext(fioTimeout)
+			defer cancel()
+			return FioAttach(ctx, output, outfile, namespace, args[0])
+		},
+	}
+
+	fioReportFrom        string
+	fioReportFromFioJSON string
+	fioReportCmd         = &cobra.Command{
+		Use:   "report",
+		Short: "Re-renders saved fio results",
+		Long: `Reads the results of an earlier run without touching a cluster, re-renders the report
+and evaluates thresholds against them. Results saved with -o json are read with --from,
+the JSON output of a plain fio run with --from-fio-json. Use -o json to convert the
+results of a plain fio run into the kubestr format.`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			thresholds, err := fioThresholds()
+			if err != nil {
+				return err
+			}
+			return FioReport(output, outfile, fioLogDir, fioReportFrom, fioReportFromFioJSON, thresholds)
+		},
+	}
+
+	etcdCheckSize string
+	etcdCheckCmd  = &cobra.Command{
+		Use:   "etcdcheck",
+		Short: "Checks if a storage class is fast enough for etcd",
+		Long: `Checks if volumes provisioned by a storage class are fast enough for etcd and other consensus stores.
+
+The checker runs the "etcd" fio test, small sequential writes each followed by
+fdatasync, and compares the 99th percentile fdatasync latency with the 10ms
+etcd recommends. The check warns when the latency is above 80% of the limit.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podOptions, err := fioPodOptions()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := interruptibleContext(fioTimeout)
 			defer cancel()
-			return Fio(ctx, output, outfile, storageClass, fioCheckerSize, namespace, fioNodeSelector, fioCheckerTestName, fioCheckerFilePath, containerImage)
+			return EtcdCheck(ctx, output, outfile, fio.RunFIOArgs{
+				StorageClass: storageClass,
+				Size:         etcdCheckSize,
+				Namespace:    namespace,
+				NodeSelector: fioNodeSelector,
+				Image:        containerImage,
+				Pod:          podOptions,
+			})
 		},
 	}
 
@@ -166,6 +392,75 @@ var (
 		},
 	}
 
+	canaryVolumeSnapshotClass string
+	canaryFIOImage            string
+	canaryRunAsUser           int64
+	canaryInterval            time.Duration
+	canaryRunTimeout          time.Duration
+	canaryWaitTimeout         time.Duration
+	canaryPVCSize             string
+	canaryListenAddress       string
+	canaryCmd                 = &cobra.Command{
+		Use:   "canary",
+		Short: "Continuously checks a storage class and exposes the results as Prometheus metrics",
+		Long: `Runs a storage canary until it is stopped, meant to run in the cluster as a Deployment.
+
+Every interval the canary:
+- Provisions a small PVC and mounts it in a pod.
+- Runs a short fio profile against the PVC.
+- Snapshots the PVC and restores the snapshot into a new PVC mounted in a pod, when a VolumeSnapshotClass is given.
+- Deletes everything it created.
+
+Provision, attach, snapshot and restore latencies, fio IOPS and p99 latencies and the
+number of failed runs per stage are served in the Prometheus format on /metrics.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return Canary(ctx, canaryListenAddress, canary.CanaryArgs{
+				StorageClass:          storageClass,
+				VolumeSnapshotClass:   canaryVolumeSnapshotClass,
+				Namespace:             namespace,
+				RunAsUser:             canaryRunAsUser,
+				ContainerImage:        containerImage,
+				FIOImage:              canaryFIOImage,
+				PVCSize:               canaryPVCSize,
+				Interval:              canaryInterval,
+				RunTimeout:            canaryRunTimeout,
+				K8sObjectReadyTimeout: canaryWaitTimeout,
+			})
+		},
+	}
+
+	historyDir  string
+	historySave bool
+	historyCmd  = &cobra.Command{
+		Use:   "history",
+		Short: "Shows the runs saved to the local history",
+	}
+	historyListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Lists the saved runs, oldest first",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return HistoryList(output, outfile, historyDir)
+		},
+	}
+
+	compareTolerance float64
+	compareCmd       = &cobra.Command{
+		Use:   "compare <run-a> <run-b>",
+		Short: "Compares a saved run with a saved baseline run",
+		Long: `Compares the metrics of run-b with those of the baseline run-a, both saved with --save-history.
+A metric regressed when it is worse than the baseline by more than the tolerance, the
+command fails when any metric regressed.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return HistoryCompare(output, outfile, historyDir, args[0], args[1], compareTolerance/100)
+		},
+	}
+
 	blockMountCmd = &cobra.Command{
 		Use:   "blockmount",
 		Short: "Checks if a storage class supports block volumes",
@@ -207,14 +502,89 @@ func init() {
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(fioCmd)
-	fioCmd.Flags().StringVarP(&storageClass, "storageclass", "s", "", "The name of a Storageclass. (Required)")
-	_ = fioCmd.MarkFlagRequired("storageclass")
+	fioCmd.Flags().StringVarP(&storageClass, "storageclass", "s", "", "The name of a Storageclass, or a comma separated list of StorageClasses to compare. (Required unless --pvc or --all-storageclasses is set)")
+	fioCmd.Flags().BoolVarP(&fioAllSCs, "all-storageclasses", "", false, "Compare every StorageClass in the cluster.")
+	fioCmd.Flags().BoolVarP(&fioConcurrent, "concurrent", "", false, "Test the compared StorageClasses at the same time instead of one after the other.")
+	fioCmd.Flags().StringVarP(&fioPVC, "pvc", "", "", "The name of an existing PVC to run FIO against instead of provisioning one. The PVC is never deleted; read-only jobs mount it read-only and other jobs run in a scratch directory.")
+	fioCmd.Flags().IntVarP(&fioClients, "clients", "", 1, "The number of pods that run FIO concurrently against one ReadWriteMany PVC, spread across nodes where possible.")
+	fioCmd.Flags().BoolVarP(&fioClientServer, "client-server", "", false, "Run fio --server in every client and drive them all from a controller pod with fio --client, so they start together and fio reports their aggregate.")
+	fioCmd.Flags().BoolVarP(&fioHistogram, "histogram", "", false, "Collect completion latency histograms (fio json+ output). Requires a job without gtod_reduce.")
+	fioCmd.Flags().DurationVarP(&fioLogInterval, "log-interval", "", 0, "Collect bandwidth, IOPS and latency logs averaged over this interval (e.g. 1s) and summarise them.")
+	fioCmd.Flags().DurationVarP(&fioTimeout, "timeout", "", 5*time.Minute, "How long the FIO test may take, per StorageClass when they are compared one after the other. Resources are cleaned up after a timeout or Ctrl-C.")
+	fioCmd.Flags().DurationVarP(&fioStatusInterval, "status-interval", "", 0, "Show the IOPS, bandwidth and ETA of every job at this interval (e.g. 5s) while FIO runs, instead of a spinner.")
+	fioCmd.Flags().BoolVarP(&fioProgressEvents, "progress-events", "", false, "With --status-interval, write the progress to stderr as JSON lines instead of rendering it.")
+	fioCmd.Flags().StringVarP(&fioLogDir, "log-dir", "", "kubestr-fio-logs", "The directory the time series collected with --log-interval are written to as CSV files.")
+	fioCmd.Flags().BoolVarP(&fioSharedFile, "shared-file", "", false, "With more than one client, run every client against a single shared file instead of a directory per client.")
+	fioCmd.Flags().StringVarP(&fioThresholdsFile, "thresholds", "", "", "The path to a YAML or JSON file of pass/fail thresholds evaluated against every job.")
+	fioCmd.Flags().StringToStringVarP(&fioMinIOPS, "min-iops", "", map[string]string{}, "Minimum IOPS per job or direction, e.g. read_iops=5000 or write=1000.")
+	fioCmd.Flags().StringToStringVarP(&fioMinBW, "min-bw", "", map[string]string{}, "Minimum bandwidth in bytes per second per job or direction, e.g. read_bw=100Mi.")
+	fioCmd.Flags().StringToStringVarP(&fioMaxLatMean, "max-lat-mean", "", map[string]string{}, "Maximum mean completion latency per job or direction, e.g. read=2ms.")
+	fioCmd.Flags().StringToStringVarP(&fioMaxLatP99, "max-lat-p99", "", map[string]string{}, "Maximum 99th percentile completion latency per job or direction, e.g. write=10ms.")
+	fioCmd.Flags().BoolVarP(&fioAsJob, "as-job", "", false, "Run FIO to completion in a Kubernetes Job and collect the results when it's done, instead of over a long exec. An interrupted run can be re-attached with fio attach.")
+	fioCmd.Flags().BoolVarP(&fioSkipLint, "skip-lint", "", false, "Run job files that fail lint, printing the errors as warnings, e.g. for options fio knows and the lint doesn't.")
+	fioCmd.AddCommand(fioLintCmd)
+	fioCmd.AddCommand(fioAttachCmd)
+	fioCmd.AddCommand(fioReportCmd)
+	fioReportCmd.Flags().StringVarP(&fioReportFrom, "from", "", "", "The path to results saved with kubestr fio -o json.")
+	fioReportCmd.Flags().StringVarP(&fioReportFromFioJSON, "from-fio-json", "", "", "The path to the JSON output of a plain fio run.")
+	fioReportCmd.MarkFlagsMutuallyExclusive("from", "from-fio-json")
+	fioReportCmd.MarkFlagsOneRequired("from", "from-fio-json")
+	fioReportCmd.Flags().StringVarP(&fioLogDir, "log-dir", "", "kubestr-fio-logs", "The directory saved time series are written to as CSV files.")
+	fioReportCmd.Flags().StringVarP(&fioThresholdsFile, "thresholds", "", "", "The path to a YAML or JSON file of pass/fail thresholds, replacing the thresholds the results were saved with.")
+	fioReportCmd.Flags().StringToStringVarP(&fioMinIOPS, "min-iops", "", map[string]string{}, "Minimum IOPS per job or direction, e.g. read_iops=5000 or write=1000.")
+	fioReportCmd.Flags().StringToStringVarP(&fioMinBW, "min-bw", "", map[string]string{}, "Minimum bandwidth in bytes per second per job or direction, e.g. read_bw=100Mi.")
+	fioReportCmd.Flags().StringToStringVarP(&fioMaxLatMean, "max-lat-mean", "", map[string]string{}, "Maximum mean completion latency per job or direction, e.g. read=2ms.")
+	fioReportCmd.Flags().StringToStringVarP(&fioMaxLatP99, "max-lat-p99", "", map[string]string{}, "Maximum 99th percentile completion latency per job or direction, e.g. write=10ms.")
+	fioAttachCmd.Flags().StringVarP(&namespace, "namespace", "n", fio.DefaultNS, "The namespace the Job runs in.")
+	fioAttachCmd.Flags().DurationVarP(&fioTimeout, "timeout", "", 5*time.Minute, "How long to wait for the Job, it keeps running after a timeout or Ctrl-C.")
+	fioLintCmd.Flags().StringVarP(&fioCheckerSize, "size", "z", fio.DefaultPVCSize, "The size of the volume the job would run against.")
+	fioLintCmd.Flags().StringVarP(&fioCheckerTestName, "testname", "t", "", "The Name of a predefined kubestr fio test to lint instead of a file.")
+	fioLintCmd.Flags().StringVarP(&containerImage, "image", "i", "", "The container image the job would run in.")
+	fioLintCmd.Flags().StringVarP(&fioVolumeMode, "volume-mode", "", "", "The volume mode the job would run against. Options(Filesystem, Block)")
+	fioLintCmd.Flags().IntVarP(&fioClients, "clients", "", 1, "The number of pods the job would run in.")
+	fioLintCmd.Flags().BoolVarP(&fioSharedFile, "shared-file", "", false, "The clients would share a single file.")
+	fioLintCmd.Flags().StringToStringVarP(&fioVars, "set", "", map[string]string{}, "Variables of the job file template, e.g. bs=8k,depth=32.")
+	fioLintCmd.Flags().StringVarP(&fioThresholdsFile, "thresholds", "", "", "The path to a thresholds file the results would be evaluated against.")
+	fioLintCmd.Flags().StringToStringVarP(&fioMaxLatMean, "max-lat-mean", "", map[string]string{}, "Maximum mean completion latency per job or direction.")
+	fioLintCmd.Flags().StringToStringVarP(&fioMaxLatP99, "max-lat-p99", "", map[string]string{}, "Maximum 99th percentile completion latency per job or direction.")
+	fioCmd.Flags().BoolVarP(&fioListTests, "list-tests", "", false, "List the predefined kubestr fio tests.")
+	fioCmd.Flags().StringVarP(&fioBaseSC, "base-sc", "", "", "Run against a temporary copy of this StorageClass with the overrides of --sc-param, --mount-option and --fstype. The copy is deleted afterwards.")
+	fioCmd.Flags().StringToStringVarP(&fioSCParams, "sc-param", "", map[string]string{}, "Parameters to override in the copy of --base-sc, e.g. type=io2,iops=16000. An empty value removes the parameter.")
+	fioCmd.Flags().StringArrayVarP(&fioMountOptions, "mount-option", "", nil, "A mount option to add to the copy of --base-sc, e.g. noatime. Can be repeated.")
+	fioCmd.Flags().StringVarP(&fioFSType, "fstype", "", "", "The filesystem of volumes of the copy of --base-sc, e.g. xfs.")
+	fioCmd.MarkFlagsMutuallyExclusive("storageclass", "pvc", "all-storageclasses", "base-sc")
+	fioCmd.MarkFlagsOneRequired("storageclass", "pvc", "all-storageclasses", "base-sc", "list-tests")
 	fioCmd.Flags().StringVarP(&fioCheckerSize, "size", "z", fio.DefaultPVCSize, "The size of the volume used to run FIO. Note that the FIO job definition is not scaled accordingly.")
 	fioCmd.Flags().StringVarP(&namespace, "namespace", "n", fio.DefaultNS, "The namespace used to run FIO.")
 	fioCmd.Flags().StringToStringVarP(&fioNodeSelector, "nodeselector", "N", map[string]string{}, "Node selector applied to pod.")
-	fioCmd.Flags().StringVarP(&fioCheckerFilePath, "fiofile", "f", "", "The path to a an fio config file.")
-	fioCmd.Flags().StringVarP(&fioCheckerTestName, "testname", "t", "", "The Name of a predefined kubestr fio test. Options(default-fio)")
+	fioCmd.Flags().StringVarP(&fioCheckerFilePath, "fiofile", "f", "", "The path to a an fio config file. The file is rendered as a Go template, see --set.")
+	fioCmd.Flags().BoolVarP(&fioVerify, "verify", "", false, "Read back the data every job writes and check it with crc32c checksums. See also the integrity test.")
+	fioCmd.Flags().Int64VarP(&fioVerifyBacklog, "verify-backlog", "", 0, "With --verify, check the data after this many blocks were written instead of after the job. Time based jobs default to 1024.")
+	fioCmd.Flags().BoolVarP(&fioReference, "with-reference", "", false, "Also run the test in the same pods against an emptyDir on the node's disk, and report the PVC relative to it.")
+	fioCmd.Flags().BoolVarP(&fioMemReference, "with-memory-reference", "", false, "Also run the test in the same pods against a memory backed emptyDir, and report the PVC relative to it.")
+	fioCmd.Flags().StringVarP(&fioMemRefSize, "memory-reference-size", "", fio.DefaultMemoryReferenceSize, "The size limit of the memory backed emptyDir of --with-memory-reference, the files of the jobs must fit in it.")
+	fioCmd.Flags().IntVarP(&fioRepeat, "repeat", "", 1, "Run the test this many times on the same PVC and report the mean, standard deviation, min, max and coefficient of variation of every metric.")
+	fioCmd.Flags().DurationVarP(&fioRepeatPause, "repeat-pause", "", 0, "Time to wait between repeated runs.")
+	fioCmd.Flags().Float64VarP(&fioMaxCV, "max-cv", "", fio.DefaultMaxCV*100, "Warn about metrics of repeated runs whose coefficient of variation exceeds this percentage.")
+	fioCmd.Flags().BoolVarP(&fioPrecondition, "precondition", "", false, "Sequentially fill the files of the test before it runs, so thin provisioned volumes are allocated.")
+	fioCmd.Flags().StringVarP(&fioSteadyState, "steady-state", "", "", "Stop every job once its throughput settles, e.g. iops_slope:0.3% or bw:5%. Metrics are iops, iops_slope, bw and bw_slope.")
+	fioCmd.Flags().DurationVarP(&fioSteadyStateDur, "steady-state-duration", "", fio.DefaultSteadyStateDuration, "Window the --steady-state criterion has to hold over.")
+	fioCmd.Flags().DurationVarP(&fioSteadyStateRamp, "steady-state-ramp", "", 0, "Time to wait before collecting data for the --steady-state criterion.")
+	fioCmd.Flags().StringToStringVarP(&fioVars, "set", "", map[string]string{}, "Variables of the --fiofile template, e.g. bs=8k,depth=32 for bs={{.bs}} and iodepth={{.depth}}.")
+	fioCmd.Flags().StringVarP(&fioCheckerTestName, "testname", "t", "", "The Name of a predefined kubestr fio test. See --list-tests for the options. (default default-fio)")
 	fioCmd.Flags().StringVarP(&containerImage, "image", "i", "", "The container image used to create a pod.")
+	addFioPodFlags(fioCmd)
+	fioCmd.Flags().StringVarP(&fioVolumeMode, "volume-mode", "", "", "The volume mode of the PVC used to run FIO. Options(Filesystem, Block). Defaults to Filesystem, or to the volume mode of --pvc. Block mode runs FIO against the raw device.")
+
+	rootCmd.AddCommand(etcdCheckCmd)
+	etcdCheckCmd.Flags().StringVarP(&storageClass, "storageclass", "s", "", "The name of a Storageclass. (Required)")
+	_ = etcdCheckCmd.MarkFlagRequired("storageclass")
+	etcdCheckCmd.Flags().StringVarP(&etcdCheckSize, "size", "z", fio.DefaultPVCSize, "The size of the volume used to run the check.")
+	etcdCheckCmd.Flags().StringVarP(&namespace, "namespace", "n", fio.DefaultNS, "The namespace used to run the check.")
+	etcdCheckCmd.Flags().StringToStringVarP(&fioNodeSelector, "nodeselector", "N", map[string]string{}, "Node selector applied to pod.")
+	etcdCheckCmd.Flags().StringVarP(&containerImage, "image", "i", "", "The container image used to create a pod.")
+	addFioPodFlags(etcdCheckCmd)
+	etcdCheckCmd.Flags().DurationVarP(&fioTimeout, "timeout", "", 5*time.Minute, "How long the check may take. Resources are cleaned up after a timeout or Ctrl-C.")
 
 	rootCmd.AddCommand(csiCheckCmd)
 	csiCheckCmd.Flags().StringVarP(&storageClass, "storageclass", "s", "", "The name of a Storageclass. (Required)")
@@ -252,6 +622,20 @@ func init() {
 	restoreFileCmd.Flags().IntVarP(&browseLocalPort, "localport", "l", 8080, "The local port to expose the inspector")
 	restoreFileCmd.Flags().StringVarP(&path, "path", "p", "", "Path of a file or directory that needs to be restored")
 
+	rootCmd.AddCommand(canaryCmd)
+	canaryCmd.Flags().StringVarP(&storageClass, "storageclass", "s", "", "The name of a StorageClass. (Required)")
+	_ = canaryCmd.MarkFlagRequired("storageclass")
+	canaryCmd.Flags().StringVarP(&canaryVolumeSnapshotClass, "volumesnapshotclass", "v", "", "The name of a VolumeSnapshotClass. The snapshot and restore round trip is skipped without one.")
+	canaryCmd.Flags().StringVarP(&namespace, "namespace", "n", fio.DefaultNS, "The namespace the canary creates its resources in.")
+	canaryCmd.Flags().StringVarP(&containerImage, "image", "i", "", "The container image of the pods that mount the PVCs.")
+	canaryCmd.Flags().StringVarP(&canaryFIOImage, "fio-image", "", "", "The container image used to run fio.")
+	canaryCmd.Flags().Int64VarP(&canaryRunAsUser, "runAsUser", "u", 0, "Runs the canary pods with the specified user ID (int)")
+	canaryCmd.Flags().DurationVarP(&canaryInterval, "interval", "", canary.DefaultInterval, "The time between the start of two canary runs.")
+	canaryCmd.Flags().DurationVarP(&canaryRunTimeout, "run-timeout", "", canary.DefaultRunTimeout, "The time a single canary run may take.")
+	canaryCmd.Flags().DurationVarP(&canaryWaitTimeout, "wait-timeout", "w", time.Minute, "Max time to wait for a PVC or pod to become ready.")
+	canaryCmd.Flags().StringVarP(&canaryPVCSize, "pvc-size", "", canary.DefaultPVCSize, "The size of the canary PVC.")
+	canaryCmd.Flags().StringVarP(&canaryListenAddress, "listen", "", ":9090", "The address to serve the metrics on.")
+
 	rootCmd.AddCommand(blockMountCmd)
 	blockMountCmd.Flags().StringVarP(&storageClass, "storageclass", "s", "", "The name of a StorageClass. (Required)")
 	_ = blockMountCmd.MarkFlagRequired("storageclass")
@@ -262,6 +646,32 @@ func init() {
 	blockMountCmd.Flags().Int64VarP(&blockMountRunAsUser, "runAsUser", "u", 0, "Runs the block mount check pod with the specified user ID (int)")
 	blockMountCmd.Flags().Uint32VarP(&blockMountWaitTimeoutSeconds, "wait-timeout", "w", 60, "Max time in seconds to wait for the check pod to become ready")
 	blockMountCmd.Flags().StringVarP(&blockMountPVCSize, "pvc-size", "", "1Gi", "The size of the provisioned PVC.")
+
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd)
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().Float64VarP(&compareTolerance, "tolerance", "", 100*history.DefaultTolerance, "How much worse than the baseline, in percent, a metric may get before it is a regression.")
+	for _, cmd := range []*cobra.Command{fioCmd, fioAttachCmd, csiCheckCmd, blockMountCmd} {
+		cmd.Flags().BoolVarP(&historySave, "save-history", "", false, "Save the results to the local history, to compare later runs with.")
+	}
+	for _, cmd := range []*cobra.Command{fioCmd, fioAttachCmd, csiCheckCmd, blockMountCmd, historyListCmd, compareCmd} {
+		cmd.Flags().StringVarP(&historyDir, "history-dir", "", history.DefaultDir(), "The directory of the local history.")
+	}
+}
+
+// interruptibleContext is cancelled after the timeout or on SIGINT/SIGTERM, so that the
+// resources created so far can be cleaned up. A second signal terminates immediately.
+func interruptibleContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
+	ctx, cancel := context.WithTimeout(signalCtx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
 }
 
 // Execute executes the main command
@@ -326,7 +736,7 @@ func PrintAndJsonOutput(result []*kubestr.TestOutput, output string, outfile str
 }
 
 // Fio executes the FIO test.
-func Fio(ctx context.Context, output, outfile, storageclass, size, namespace string, nodeSelector map[string]string, jobName, fioFilePath string, containerImage string) error {
+func Fio(ctx context.Context, output, outfile, logDir string, fioArgs fio.RunFIOArgs) error {
 	cli, err := kubestr.LoadKubeCli()
 	if err != nil {
 		fmt.Println(err.Error())
@@ -335,21 +745,271 @@ func Fio(ctx context.Context, output, outfile, storageclass, size, namespace str
 	fioRunner := &fio.FIOrunner{
 		Cli: cli,
 	}
-	testName := "FIO test results"
 	var result *kubestr.TestOutput
-	fioResult, err := fioRunner.RunFio(ctx, &fio.RunFIOArgs{
-		StorageClass:   storageclass,
-		Size:           size,
-		Namespace:      namespace,
-		NodeSelector:   nodeSelector,
-		FIOJobName:     jobName,
-		FIOJobFilepath: fioFilePath,
-		Image:          containerImage,
-	})
+	fioResult, err := fioRunner.RunFio(ctx, &fioArgs)
+	if err != nil {
+		result = kubestr.MakeTestOutput("FIO test results", kubestr.StatusError, err.Error(), fioResult)
+	} else {
+		if historySave {
+			saveRecord(ctx, cli, func() (*history.Record, error) {
+				return history.FioRecord(fioResult.TestFile, fioResult)
+			})
+		}
+		result, err = fioTestOutput("FIO test results", fioResult, "", logDir)
+	}
+	var wrappedResult = []*kubestr.TestOutput{result}
+	if !PrintAndJsonOutput(wrappedResult, output, outfile) {
+		result.Print()
+	}
+	return err
+}
+
+// FioAttach waits for a FIO test running as a Job and reports its results
+func FioAttach(ctx context.Context, output, outfile, namespace, runID string) error {
+	cli, err := kubestr.LoadKubeCli()
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	fioRunner := &fio.FIOrunner{
+		Cli: cli,
+	}
+	var result *kubestr.TestOutput
+	fioResult, err := fioRunner.AttachFio(ctx, namespace, runID)
+	if err != nil {
+		result = kubestr.MakeTestOutput("FIO test results", kubestr.StatusError, err.Error(), fioResult)
+	} else {
+		if historySave {
+			saveRecord(ctx, cli, func() (*history.Record, error) {
+				return history.FioRecord(fioResult.TestFile, fioResult)
+			})
+		}
+		result, err = fioTestOutput("FIO test results", fioResult, "", "")
+	}
+	var wrappedResult = []*kubestr.TestOutput{result}
+	if !PrintAndJsonOutput(wrappedResult, output, outfile) {
+		result.Print()
+	}
+	return err
+}
+
+// FioReport re-renders saved FIO results and evaluates the thresholds against them
+func FioReport(output, outfile, logDir, fromPath, fromFioJSONPath string, thresholds []fio.Threshold) error {
+	var runs []fio.SavedRun
+	if fromFioJSONPath != "" {
+		fioResult, err := fio.LoadFioOutput(fromFioJSONPath)
+		if err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+		runs = []fio.SavedRun{{TestName: "FIO test results", Result: fioResult}}
+	} else {
+		var err error
+		if runs, err = fio.LoadSavedRuns(fromPath); err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+	}
+	var results []*kubestr.TestOutput
+	var reportErr error
+	for _, run := range runs {
+		if err := run.Result.ApplyThresholds(thresholds); err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+		result, err := fioTestOutput(run.TestName, run.Result, "", logDir)
+		if err != nil && reportErr == nil {
+			reportErr = err
+		}
+		results = append(results, result)
+	}
+	if !PrintAndJsonOutput(results, output, outfile) {
+		for _, result := range results {
+			result.Print()
+		}
+	}
+	return reportErr
+}
+
+// fioTestOutput reports the result of a FIO run, the error is set when jobs failed, e.g. because
+// data didn't verify, or when thresholds were not met
+func fioTestOutput(testName string, fioResult *fio.RunFIOResult, fioErr, logDir string) (*kubestr.TestOutput, error) {
+	if fioErr != "" {
+		return kubestr.MakeTestOutput(testName, kubestr.StatusError, fioErr, fioResult), nil
+	}
+	result := kubestr.MakeTestOutput(testName, kubestr.StatusOK, fmt.Sprintf("\n%s", fioResult.Print()), fioResult)
+	jobErrors := fioResult.Result.JobErrors()
+	for _, jobErr := range jobErrors {
+		result.Status = append(result.Status, kubestr.Status{
+			StatusCode:    kubestr.StatusError,
+			StatusMessage: jobErr.String(),
+		})
+	}
+	result.Status = append(result.Status, steadyStateStatuses(fioResult.Result.SteadyStates())...)
+	if fioResult.Repeats != nil {
+		for _, stats := range fioResult.Repeats.HighVariance() {
+			result.Status = append(result.Status, kubestr.Status{
+				StatusCode:    kubestr.StatusWarning,
+				StatusMessage: fmt.Sprintf("high variance over %d runs, %s", stats.Samples, stats.Print()),
+			})
+		}
+	}
+	for _, reference := range fioResult.References {
+		if reference.Error != "" {
+			result.Status = append(result.Status, kubestr.Status{
+				StatusCode:    kubestr.StatusWarning,
+				StatusMessage: fmt.Sprintf("node local %s reference failed: %s", reference.Medium, reference.Error),
+			})
+		}
+	}
+	result.Status = append(result.Status, thresholdStatuses(fioResult.Thresholds)...)
+	result.Status = append(result.Status, logStatuses(fioResult.Logs, logDir)...)
+	if len(jobErrors) > 0 {
+		return result, fmt.Errorf("%d FIO jobs failed", len(jobErrors))
+	}
+	if fio.ThresholdsFailed(fioResult.Thresholds) {
+		return result, fmt.Errorf("FIO results did not meet the thresholds")
+	}
+	return result, nil
+}
+
+// splitStorageClasses splits a comma-separated list of StorageClasses, dropping spaces and empty entries
+func splitStorageClasses(list string) []string {
+	var storageClasses []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			storageClasses = append(storageClasses, name)
+		}
+	}
+	return storageClasses
+}
+
+// FioComparison runs the same FIO test against several StorageClasses and compares the results.
+// Every StorageClass in the cluster is compared when allSCs is set, the timeout is per StorageClass
+// when they are compared one after the other.
+func FioComparison(timeout time.Duration, output, outfile, logDir string, storageClasses []string, allSCs, concurrent bool, fioArgs fio.RunFIOArgs) error {
+	cli, err := kubestr.LoadKubeCli()
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	if allSCs {
+		listCtx, cancel := interruptibleContext(timeout)
+		storageClasses, err = fio.ListStorageClasses(listCtx, cli)
+		cancel()
+		if err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+	}
+	// the timeout applies to each StorageClass when they are compared one after the other
+	if !concurrent {
+		timeout *= time.Duration(len(storageClasses))
+	}
+	ctx, cancel := interruptibleContext(timeout)
+	defer cancel()
+	fioRunner := &fio.FIOrunner{
+		Cli: cli,
+	}
+	comparison, err := fioRunner.RunFioComparison(ctx, &fioArgs, storageClasses, concurrent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	var results []*kubestr.TestOutput
+	for _, r := range comparison {
+		if r.Error == "" && historySave {
+			saveRecord(ctx, cli, func() (*history.Record, error) {
+				return history.FioRecord(r.Result.TestFile, r.Result)
+			})
+		}
+		result, resErr := fioTestOutput(fmt.Sprintf("FIO test results (%s)", r.StorageClass), r.Result, r.Error, filepath.Join(logDir, r.StorageClass))
+		if resErr != nil {
+			err = resErr
+		}
+		results = append(results, result)
+	}
+	if comparison.Failed() {
+		err = fmt.Errorf("FIO test failed on one or more StorageClasses")
+	}
+	results = append(results, kubestr.MakeTestOutput("FIO comparison", kubestr.StatusInfo, comparison.Print(), nil))
+	if !PrintAndJsonOutput(results, output, outfile) {
+		for _, result := range results {
+			result.Print()
+		}
+	}
+	return err
+}
+
+// EtcdCheck runs the etcd fio test and judges the fdatasync latency
+func EtcdCheck(ctx context.Context, output, outfile string, fioArgs fio.RunFIOArgs) error {
+	cli, err := kubestr.LoadKubeCli()
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	fioRunner := &fio.FIOrunner{
+		Cli: cli,
+	}
+	testName := "etcd check"
+	fioArgs.FIOJobName = fio.EtcdFIOJob
+	fioArgs.Thresholds = fio.EtcdThresholds()
+	var result *kubestr.TestOutput
+	fioResult, err := fioRunner.RunFio(ctx, &fioArgs)
 	if err != nil {
 		result = kubestr.MakeTestOutput(testName, kubestr.StatusError, err.Error(), fioResult)
 	} else {
-		result = kubestr.MakeTestOutput(testName, kubestr.StatusOK, fmt.Sprintf("\n%s", fioResult.Result.Print()), fioResult)
+		check := fio.EvaluateEtcd(fioResult)
+		result = kubestr.MakeTestOutput(testName, kubestr.StatusCode(check.Status), check.Message, check)
+		if check.Status == fio.ThresholdError {
+			err = fmt.Errorf("StorageClass (%s) is too slow for etcd", fioArgs.StorageClass)
+		}
+	}
+	var wrappedResult = []*kubestr.TestOutput{result}
+	if !PrintAndJsonOutput(wrappedResult, output, outfile) {
+		result.Print()
+	}
+	return err
+}
+
+// FioListTests prints the predefined kubestr fio tests
+func FioListTests(output, outfile string) error {
+	tests := fio.ListFioTests()
+	result := &kubestr.TestOutput{TestName: "FIO tests", Raw: tests}
+	for _, test := range tests {
+		result.Status = append(result.Status, kubestr.Status{
+			StatusCode:    kubestr.StatusInfo,
+			StatusMessage: fmt.Sprintf("%s: %s", test.Name, test.Description),
+		})
+	}
+	var wrappedResult = []*kubestr.TestOutput{result}
+	if !PrintAndJsonOutput(wrappedResult, output, outfile) {
+		result.Print()
+	}
+	return nil
+}
+
+// FioLint checks an fio job file without touching the cluster
+func FioLint(output, outfile string, fioArgs fio.RunFIOArgs) error {
+	testName := "FIO job lint"
+	var result *kubestr.TestOutput
+	name, issues, err := fio.LintFioJob(&fioArgs)
+	switch {
+	case err != nil:
+		result = kubestr.MakeTestOutput(testName, kubestr.StatusError, err.Error(), nil)
+	case len(issues) == 0:
+		result = kubestr.MakeTestOutput(testName, kubestr.StatusOK, fmt.Sprintf("No issues found in FIO job (%s)", name), issues)
+	default:
+		result = &kubestr.TestOutput{TestName: testName, Raw: issues}
+		for _, issue := range issues {
+			result.Status = append(result.Status, kubestr.Status{
+				StatusCode:    kubestr.StatusCode(issue.Severity),
+				StatusMessage: issue.String(),
+			})
+		}
+		if fio.LintFailed(issues) {
+			err = fmt.Errorf("FIO job (%s) failed lint", name)
+		}
 	}
 	var wrappedResult = []*kubestr.TestOutput{result}
 	if !PrintAndJsonOutput(wrappedResult, output, outfile) {
@@ -358,6 +1018,138 @@ func Fio(ctx context.Context, output, outfile, storageclass, size, namespace str
 	return err
 }
 
+// logStatuses writes the time series to CSV files and warns about sustained drops
+func logStatuses(series []fio.FioLogSeries, logDir string) []kubestr.Status {
+	if len(series) == 0 {
+		return nil
+	}
+	var statuses []kubestr.Status
+	if paths, err := fio.WriteLogsCSV(logDir, series); err != nil {
+		statuses = append(statuses, kubestr.Status{
+			StatusCode:    kubestr.StatusWarning,
+			StatusMessage: err.Error(),
+		})
+	} else {
+		statuses = append(statuses, kubestr.Status{
+			StatusCode:    kubestr.StatusInfo,
+			StatusMessage: fmt.Sprintf("Wrote %d time series to (%s)", len(paths), logDir),
+		})
+	}
+	for _, s := range series {
+		if s.Drop != nil {
+			statuses = append(statuses, kubestr.Status{
+				StatusCode:    kubestr.StatusWarning,
+				StatusMessage: fmt.Sprintf("%s: %s", s.Name(), s.Drop.Print()),
+			})
+		}
+	}
+	return statuses
+}
+
+// fioThresholds collects the thresholds from the threshold flags and file
+func fioThresholds() ([]fio.Threshold, error) {
+	var thresholds []fio.Threshold
+	if fioThresholdsFile != "" {
+		fileThresholds, err := fio.LoadThresholdsFile(fioThresholdsFile)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, fileThresholds...)
+	}
+	for _, flag := range []struct {
+		metric fio.ThresholdMetric
+		isMin  bool
+		values map[string]string
+	}{
+		{metric: fio.ThresholdIOPS, isMin: true, values: fioMinIOPS},
+		{metric: fio.ThresholdBW, isMin: true, values: fioMinBW},
+		{metric: fio.ThresholdLatMean, isMin: false, values: fioMaxLatMean},
+		{metric: fio.ThresholdLatP99, isMin: false, values: fioMaxLatP99},
+	} {
+		flagThresholds, err := fio.ThresholdsFromFlags(flag.metric, flag.isMin, flag.values)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, flagThresholds...)
+	}
+	return thresholds, nil
+}
+
+// addFioPodFlags adds the flags that customise the pods running FIO
+func addFioPodFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&fioTolerations, "toleration", "", nil, "A toleration of the pod as key[=value][:effect], e.g. dedicated=storage:NoSchedule. Can be repeated.")
+	cmd.Flags().StringToStringVarP(&fioNodeAffinity, "node-affinity", "", map[string]string{}, "Required node labels, values separated by '|', an empty value only requires the label, e.g. topology.kubernetes.io/zone=a|b.")
+	cmd.Flags().StringToStringVarP(&fioPodAffinity, "pod-affinity", "", map[string]string{}, "Labels of pods the pod has to run on the same node as.")
+	cmd.Flags().StringToStringVarP(&fioRequests, "requests", "", map[string]string{}, "Resource requests of the FIO container, e.g. cpu=1,memory=1Gi.")
+	cmd.Flags().StringToStringVarP(&fioLimits, "limits", "", map[string]string{}, "Resource limits of the FIO container, e.g. cpu=2,memory=2Gi.")
+	cmd.Flags().StringVarP(&fioPriorityClass, "priority-class", "", "", "The priorityClass of the pod.")
+	cmd.Flags().StringVarP(&fioRuntimeClass, "runtime-class", "", "", "The runtimeClass of the pod.")
+	cmd.Flags().StringToStringVarP(&fioPodAnnotations, "pod-annotations", "", map[string]string{}, "Annotations of the pod, e.g. sidecar.istio.io/inject=false.")
+	cmd.Flags().StringVarP(&fioServiceAccount, "service-account", "", "", "The serviceAccount the pod runs as.")
+	cmd.Flags().StringSliceVarP(&fioPullSecrets, "image-pull-secrets", "", nil, "Comma separated names of secrets used to pull the container image.")
+	cmd.Flags().StringVarP(&fioPodOverlayFile, "pod-overlay", "", "", "The path to a partial pod manifest in YAML or JSON that is strategically merged into the generated pod.")
+}
+
+// fioPodOptions collects the pod flags
+func fioPodOptions() (fio.PodOptions, error) {
+	opts := fio.PodOptions{
+		Affinity:           fio.RequiredAffinity(fioNodeAffinity, fioPodAffinity),
+		PriorityClassName:  fioPriorityClass,
+		RuntimeClassName:   fioRuntimeClass,
+		Annotations:        fioPodAnnotations,
+		ServiceAccountName: fioServiceAccount,
+		ImagePullSecrets:   fioPullSecrets,
+	}
+	for _, t := range fioTolerations {
+		toleration, err := fio.ParseToleration(t)
+		if err != nil {
+			return opts, err
+		}
+		opts.Tolerations = append(opts.Tolerations, toleration)
+	}
+	resources, err := fio.ResourceRequirements(fioRequests, fioLimits)
+	if err != nil {
+		return opts, err
+	}
+	opts.Resources = resources
+	if fioPodOverlayFile != "" {
+		overlay, err := fio.LoadPodOverlayFile(fioPodOverlayFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Overlay = overlay
+	}
+	return opts, nil
+}
+
+// steadyStateStatuses warns about the jobs that didn't reach steady state
+func steadyStateStatuses(results []fio.SteadyStateResult) []kubestr.Status {
+	var statuses []kubestr.Status
+	for _, r := range results {
+		code := kubestr.StatusOK
+		if !r.Reached {
+			code = kubestr.StatusWarning
+		}
+		statuses = append(statuses, kubestr.Status{
+			StatusCode:    code,
+			StatusMessage: r.String(),
+		})
+	}
+	return statuses
+}
+
+// thresholdStatuses converts threshold results into statuses of a TestOutput
+func thresholdStatuses(results []fio.ThresholdResult) []kubestr.Status {
+	var statuses []kubestr.Status
+	for _, r := range results {
+		statuses = append(statuses, kubestr.Status{
+			StatusCode:    kubestr.StatusCode(r.Status),
+			StatusMessage: r.Message,
+		})
+	}
+	return statuses
+}
+
 func CSICheck(ctx context.Context, output, outfile,
 	namespace string,
 	storageclass string,
@@ -395,6 +1187,11 @@ func CSICheck(ctx context.Context, output, outfile,
 	if err != nil {
 		result = kubestr.MakeTestOutput(testName, kubestr.StatusError, err.Error(), csiCheckResult)
 	} else {
+		if historySave {
+			saveRecord(ctx, kubecli, func() (*history.Record, error) {
+				return history.CSICheckRecord(storageclass, csiCheckResult)
+			})
+		}
 		result = kubestr.MakeTestOutput(testName, kubestr.StatusOK, "CSI application successfully snapshotted and restored.", csiCheckResult)
 	}
 
@@ -513,6 +1310,56 @@ func FileRestore(ctx context.Context,
 	return err
 }
 
+// Canary runs the storage canary and serves its metrics until the context is cancelled
+func Canary(ctx context.Context, listenAddress string, args canary.CanaryArgs) error {
+	kubecli, err := kubestr.LoadKubeCli()
+	if err != nil {
+		fmt.Printf("Failed to load kubeCli (%s)", err.Error())
+		return err
+	}
+	args.KubeCli = kubecli
+	dyncli, err := kubestr.LoadDynCli()
+	if err != nil {
+		fmt.Printf("Failed to load dynCli (%s)", err.Error())
+		return err
+	}
+	args.DynCli = dyncli
+	metrics := canary.NewMetrics()
+	storageCanary, err := canary.NewCanary(args, metrics)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(canary.MetricsPath, metrics)
+	server := &http.Server{Addr: listenAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	fmt.Printf("Serving canary metrics on %s%s\n", listenAddress, canary.MetricsPath)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- storageCanary.Run(runCtx)
+	}()
+	select {
+	case err = <-serverErr:
+		cancel()
+		<-runErr
+		return err
+	case err = <-runErr:
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
 func BlockMountCheck(ctx context.Context, output, outfile string, cleanupOnly bool, checkerArgs block.BlockMountCheckerArgs) error {
 	kubecli, err := kubestr.LoadKubeCli()
 	if err != nil {
@@ -551,6 +1398,11 @@ func BlockMountCheck(ctx context.Context, output, outfile string, cleanupOnly bo
 		}
 		result = kubestr.MakeTestOutput(testName, kubestr.StatusError, fmt.Sprintf("StorageClass (%s) does not appear to support Block VolumeMode", checkerArgs.StorageClass), mountResult)
 	} else {
+		if historySave {
+			saveRecord(ctx, kubecli, func() (*history.Record, error) {
+				return history.BlockMountRecord(checkerArgs.StorageClass, mountResult)
+			})
+		}
 		result = kubestr.MakeTestOutput(testName, kubestr.StatusOK, fmt.Sprintf("StorageClass (%s) supports Block VolumeMode", checkerArgs.StorageClass), mountResult)
 	}
 
@@ -562,6 +1414,83 @@ func BlockMountCheck(ctx context.Context, output, outfile string, cleanupOnly bo
 	return err
 }
 
+// saveRecord saves the record of a run to the history, with the cluster and driver it ran against.
+// A run that can't be saved is not failed, the error is reported on stderr like the saved id.
+func saveRecord(ctx context.Context, cli kubernetes.Interface, record func() (*history.Record, error)) {
+	r, err := record()
+	if err == nil {
+		if host, hostErr := kubestr.ClusterHost(); hostErr == nil {
+			r.Cluster = host
+		}
+		history.DescribeDriver(ctx, cli, r)
+		err = history.NewStore(historyDir).Save(r)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the run to the history: %s\n", err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Saved run (%s) to the history in (%s)\n", r.ID, historyDir)
+}
+
+// HistoryList lists the runs saved to the history
+func HistoryList(output, outfile, dir string) error {
+	records, err := history.NewStore(dir).List()
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tKIND\tJOB\tSTORAGECLASS\tDRIVER\tVERSION\tCLUSTER\t")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", r.ID, r.Timestamp.Local().Format("2006-01-02 15:04"),
+			r.Kind, r.Job, r.StorageClass, r.Driver, r.DriverVersion, r.Cluster)
+	}
+	_ = w.Flush()
+	result := kubestr.MakeTestOutput("Saved runs", kubestr.StatusInfo, fmt.Sprintf("%d runs in (%s)", len(records), dir), records)
+	if !PrintAndJsonOutput([]*kubestr.TestOutput{result}, output, outfile) {
+		result.Print()
+		fmt.Print(buf.String())
+	}
+	return nil
+}
+
+// HistoryCompare compares a saved run with a saved baseline run, it fails when a metric regressed
+func HistoryCompare(output, outfile, dir, baseID, currentID string, tolerance float64) error {
+	store := history.NewStore(dir)
+	base, err := store.Load(baseID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	current, err := store.Load(currentID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	comparison, err := history.Compare(base, current, tolerance)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	testName := "History comparison"
+	result := kubestr.MakeTestOutput(testName, kubestr.StatusOK, "\n"+comparison.Print(), comparison)
+	for _, d := range comparison.Regressions() {
+		result.Status = append(result.Status, kubestr.Status{
+			StatusCode: kubestr.StatusError,
+			StatusMessage: fmt.Sprintf("%s regressed from %s to %s (%+.1f%%)", d.Metric.Name,
+				d.Metric.Format(d.Base), d.Metric.Format(d.Metric.Value), 100*d.Change),
+		})
+	}
+	if !PrintAndJsonOutput([]*kubestr.TestOutput{result}, output, outfile) {
+		result.Print()
+	}
+	if regressions := len(comparison.Regressions()); regressions > 0 {
+		return fmt.Errorf("%d metrics regressed by more than %.0f%%", regressions, 100*tolerance)
+	}
+	return nil
+}
+
 // getVersion returns the version of kubestr.
 // If the version was injected at build time via ldflags by goreleaser, it returns that.
 // Otherwise, it falls back to reading the git commit hash that Go automatically
@@ -46,6 +46,8 @@ type BlockMountChecker interface {
 
 type BlockMountCheckerResult struct {
 	StorageClass *sv1.StorageClass
+	Provision    time.Duration // until the PVC was created
+	PodReady     time.Duration // until the pod with the volumeDevice was ready
 }
 
 const (
@@ -127,7 +129,8 @@ func (b *blockMountChecker) Mount(ctx context.Context) (*BlockMountCheckerResult
 		fmt.Printf(" -> Failed to provision a Volume (%v)\n", err)
 		return nil, err
 	}
-	fmt.Printf(" -> Created PVC %s/%s (%s)\n", b.args.Namespace, b.pvcName, time.Since(tB).Truncate(time.Millisecond).String())
+	provision := time.Since(tB)
+	fmt.Printf(" -> Created PVC %s/%s (%s)\n", b.args.Namespace, b.pvcName, provision.Truncate(time.Millisecond).String())
 
 	fmt.Println("Creating a Pod with a volumeDevice ...")
 	tB = time.Now()
@@ -155,10 +158,13 @@ func (b *blockMountChecker) Mount(ctx context.Context) (*BlockMountCheckerResult
 		fmt.Printf(" -> The Pod timed out (%v)\n", err)
 		return nil, err
 	}
-	fmt.Printf(" -> The Pod is ready (%s)\n", time.Since(tB).Truncate(time.Millisecond).String())
+	podReady := time.Since(tB)
+	fmt.Printf(" -> The Pod is ready (%s)\n", podReady.Truncate(time.Millisecond).String())
 
 	return &BlockMountCheckerResult{
 		StorageClass: sc,
+		Provision:    provision,
+		PodReady:     podReady,
 	}, nil
 }
 
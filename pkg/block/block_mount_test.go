@@ -434,6 +434,8 @@ func TestBlockMountCheckerMount(t *testing.T) {
 
 			result, err := b.Mount(ctx)
 			if tc.result != nil {
+				// the timings are measured with the wall clock
+				result.Provision, result.PodReady = 0, 0
 				c.Assert(result, qt.DeepEquals, tc.result)
 				c.Assert(err, qt.IsNil)
 			} else {
@@ -0,0 +1,363 @@
+package canary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kankube "github.com/kanisterio/kanister/pkg/kube"
+	"github.com/kastenhq/kubestr/pkg/csi"
+	"github.com/kastenhq/kubestr/pkg/csi/types"
+	"github.com/kastenhq/kubestr/pkg/fio"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Stage is a step of a canary run, failures are counted per stage
+type Stage string
+
+const (
+	// StageProvision creates the PVC and waits for it to be bound
+	StageProvision Stage = "provision"
+	// StageAttach starts a pod with the PVC mounted
+	StageAttach Stage = "attach"
+	// StageFio runs the fio profile against the PVC
+	StageFio Stage = "fio"
+	// StageSnapshot snapshots the PVC and waits for the snapshot to be ready
+	StageSnapshot Stage = "snapshot"
+	// StageRestore restores the snapshot into a new PVC and mounts it in a pod
+	StageRestore Stage = "restore"
+	// StageCleanup deletes what the run created
+	StageCleanup Stage = "cleanup"
+)
+
+// Stages are the stages of a canary run in the order they run
+var Stages = []Stage{StageProvision, StageAttach, StageFio, StageSnapshot, StageRestore, StageCleanup}
+
+const (
+	// DefaultInterval is the time between the start of two canary runs
+	DefaultInterval = 15 * time.Minute
+	// DefaultRunTimeout bounds a single canary run
+	DefaultRunTimeout = 10 * time.Minute
+	// DefaultPVCSize is the size of the canary PVC
+	DefaultPVCSize = "1Gi"
+
+	canaryPVCGenerateName         = "kubestr-canary-pvc-"
+	canaryPodGenerateName         = "kubestr-canary-pod-"
+	canaryRestorePVCGenerateName  = "kubestr-canary-restore-pvc-"
+	canaryRestorePodGenerateName  = "kubestr-canary-restore-pod-"
+	canarySnapshotNameFmt         = "kubestr-canary-snapshot-%s"
+	canaryCleanupTimeout          = 2 * time.Minute
+	canaryPodDeletionTimeout      = 2 * time.Minute
+	canaryMountPath               = "/data"
+	canarySnapshotAPIGroup        = "snapshot.storage.k8s.io"
+	canarySnapshotKind            = "VolumeSnapshot"
+	canarySnapshotTimestampFormat = "20060102150405"
+)
+
+type CanaryArgs struct {
+	KubeCli kubernetes.Interface
+	DynCli  dynamic.Interface
+
+	StorageClass          string
+	VolumeSnapshotClass   string // the snapshot and restore stages are skipped when missing
+	Namespace             string
+	RunAsUser             int64
+	ContainerImage        string // image of the pods that mount the PVC and the restored PVC
+	FIOImage              string
+	PVCSize               string
+	Interval              time.Duration
+	RunTimeout            time.Duration
+	K8sObjectReadyTimeout time.Duration
+}
+
+func (a *CanaryArgs) Validate() error {
+	if a.KubeCli == nil || a.DynCli == nil || a.StorageClass == "" || a.Namespace == "" {
+		return fmt.Errorf("require fields are missing. (KubeCli, DynCli, StorageClass, Namespace)")
+	}
+	if a.Interval < 0 || a.RunTimeout < 0 {
+		return fmt.Errorf("invalid interval (%s) or run timeout (%s)", a.Interval, a.RunTimeout)
+	}
+	if a.PVCSize != "" {
+		if _, err := resource.ParseQuantity(a.PVCSize); err != nil {
+			return errors.Wrapf(err, "invalid PVC size (%s)", a.PVCSize)
+		}
+	}
+	return nil
+}
+
+// CanaryResult is the outcome of a single canary run. Latencies of fio are in nanoseconds,
+// a metric is 0 when its stage didn't run.
+type CanaryResult struct {
+	StorageClass  string        `json:"storageClass"`
+	Start         time.Time     `json:"start"`
+	Provision     time.Duration `json:"provision"`
+	Attach        time.Duration `json:"attach"`
+	ReadIOPS      float64       `json:"readIOPS"`
+	WriteIOPS     float64       `json:"writeIOPS"`
+	ReadLatP99    float64       `json:"readLatP99"`
+	WriteLatP99   float64       `json:"writeLatP99"`
+	SnapshotReady time.Duration `json:"snapshotReady,omitempty"`
+	Restore       time.Duration `json:"restore,omitempty"`
+	FailedStage   Stage         `json:"failedStage,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	CleanupError  string        `json:"cleanupError,omitempty"`
+}
+
+// Canary periodically provisions a small PVC, runs a short fio profile and a snapshot/restore
+// round trip against it and records the results in Metrics
+type Canary struct {
+	args            CanaryArgs
+	metrics         *Metrics
+	validator       csi.ArgumentValidator
+	appCreator      csi.ApplicationCreator
+	cleaner         csi.Cleaner
+	snapshotCreator csi.SnapshotCreator
+	versionFetcher  csi.ApiVersionFetcher
+	fioRunner       fio.FIO
+}
+
+func NewCanary(args CanaryArgs, metrics *Metrics) (*Canary, error) {
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+	if args.PVCSize == "" {
+		args.PVCSize = DefaultPVCSize
+	}
+	if args.Interval == 0 {
+		args.Interval = DefaultInterval
+	}
+	if args.RunTimeout == 0 {
+		args.RunTimeout = DefaultRunTimeout
+	}
+	return &Canary{
+		args:            args,
+		metrics:         metrics,
+		validator:       csi.NewArgumentValidator(args.KubeCli, args.DynCli),
+		appCreator:      csi.NewApplicationCreator(args.KubeCli, args.K8sObjectReadyTimeout),
+		cleaner:         csi.NewCleaner(args.KubeCli, args.DynCli),
+		snapshotCreator: csi.NewSnapshotCreator(args.KubeCli, args.DynCli),
+		versionFetcher:  csi.NewApiVersionFetcher(args.KubeCli),
+		fioRunner:       &fio.FIOrunner{Cli: args.KubeCli},
+	}, nil
+}
+
+// Run runs the canary every interval until the context is cancelled
+func (c *Canary) Run(ctx context.Context) error {
+	if _, err := c.validator.ValidateStorageClass(ctx, c.args.StorageClass); err != nil {
+		return errors.Wrap(err, "failed to validate StorageClass")
+	}
+	ticker := time.NewTicker(c.args.Interval)
+	defer ticker.Stop()
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, c.args.RunTimeout)
+		result := c.RunOnce(runCtx)
+		cancel()
+		if result.Error != "" {
+			fmt.Printf("Canary run failed at stage (%s): %s\n", result.FailedStage, result.Error)
+		} else {
+			fmt.Printf("Canary run succeeded: provision=%s attach=%s\n", result.Provision, result.Attach)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce runs every stage once, cleans up and records the result in the metrics
+func (c *Canary) RunOnce(ctx context.Context) *CanaryResult {
+	result := &CanaryResult{StorageClass: c.args.StorageClass, Start: time.Now()}
+	var created canaryResources
+	if stage, err := c.run(ctx, result, &created); err != nil {
+		result.FailedStage = stage
+		result.Error = err.Error()
+	}
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), canaryCleanupTimeout)
+	defer cancel()
+	if err := c.cleanup(cleanupCtx, created); err != nil {
+		result.CleanupError = err.Error()
+	}
+	if c.metrics != nil {
+		c.metrics.Observe(result)
+	}
+	return result
+}
+
+// canaryResources are the objects a run created, deleted when it ends
+type canaryResources struct {
+	pvc, pod, snapshot, restorePVC, restorePod string
+}
+
+// run runs the stages in order and returns the one that failed
+func (c *Canary) run(ctx context.Context, result *CanaryResult, created *canaryResources) (Stage, error) {
+	size, err := resource.ParseQuantity(c.args.PVCSize)
+	if err != nil {
+		return StageProvision, err
+	}
+	start := time.Now()
+	pvc, err := c.appCreator.CreatePVC(ctx, &types.CreatePVCArgs{
+		GenerateName: canaryPVCGenerateName,
+		StorageClass: c.args.StorageClass,
+		Namespace:    c.args.Namespace,
+		RestoreSize:  &size,
+	})
+	if err != nil {
+		return StageProvision, errors.Wrap(err, "failed to create PVC")
+	}
+	created.pvc = pvc.Name
+	// a WaitForFirstConsumer volume is only provisioned once a pod uses it
+	pod, err := c.createPod(ctx, canaryPodGenerateName, pvc.Name)
+	if err != nil {
+		return StageAttach, errors.Wrap(err, "failed to create pod")
+	}
+	created.pod = pod.Name
+	if err := c.appCreator.WaitForPVCReady(ctx, c.args.Namespace, pvc.Name); err != nil {
+		return StageProvision, errors.Wrap(err, "PVC failed to become ready")
+	}
+	result.Provision = time.Since(start)
+	bound := time.Now()
+	if err := c.appCreator.WaitForPodReady(ctx, c.args.Namespace, pod.Name); err != nil {
+		return StageAttach, errors.Wrap(err, "pod failed to become ready")
+	}
+	result.Attach = time.Since(bound)
+
+	// fio mounts the PVC in its own pod, the volume may only attach to one node at a time
+	if err := c.deletePod(ctx, pod.Name); err != nil {
+		return StageFio, errors.Wrap(err, "failed to delete pod")
+	}
+	created.pod = ""
+	fioResult, err := c.fioRunner.RunFio(ctx, &fio.RunFIOArgs{
+		PVC:        pvc.Name,
+		Namespace:  c.args.Namespace,
+		FIOJobName: fio.CanaryFIOJob,
+		Image:      c.args.FIOImage,
+	})
+	if err != nil {
+		return StageFio, err
+	}
+	if jobErrors := fioResult.Result.JobErrors(); len(jobErrors) > 0 {
+		return StageFio, errors.New(jobErrors[0].String())
+	}
+	result.ReadIOPS, _ = fioResult.Result.Metric("read", fio.ThresholdIOPS)
+	result.WriteIOPS, _ = fioResult.Result.Metric("write", fio.ThresholdIOPS)
+	result.ReadLatP99, _ = fioResult.Result.Metric("read", fio.ThresholdLatP99)
+	result.WriteLatP99, _ = fioResult.Result.Metric("write", fio.ThresholdLatP99)
+
+	if c.args.VolumeSnapshotClass == "" {
+		return "", nil
+	}
+	snapshotter, err := c.snapshotCreator.NewSnapshotter()
+	if err != nil {
+		return StageSnapshot, errors.Wrap(err, "failed to load snapshotter")
+	}
+	start = time.Now()
+	snapshotName := fmt.Sprintf(canarySnapshotNameFmt, start.Format(canarySnapshotTimestampFormat))
+	created.snapshot = snapshotName
+	snapshot, err := c.snapshotCreator.CreateSnapshot(ctx, snapshotter, &types.CreateSnapshotArgs{
+		Namespace:           c.args.Namespace,
+		PVCName:             pvc.Name,
+		VolumeSnapshotClass: c.args.VolumeSnapshotClass,
+		SnapshotName:        snapshotName,
+	})
+	if err != nil {
+		return StageSnapshot, err
+	}
+	result.SnapshotReady = time.Since(start)
+
+	start = time.Now()
+	apiGroup := canarySnapshotAPIGroup
+	restorePVC, err := c.appCreator.CreatePVC(ctx, &types.CreatePVCArgs{
+		GenerateName: canaryRestorePVCGenerateName,
+		StorageClass: c.args.StorageClass,
+		Namespace:    c.args.Namespace,
+		DataSource:   &v1.TypedLocalObjectReference{APIGroup: &apiGroup, Kind: canarySnapshotKind, Name: snapshot.Name},
+		RestoreSize:  snapshot.Status.RestoreSize,
+	})
+	if err != nil {
+		return StageRestore, errors.Wrap(err, "failed to restore PVC")
+	}
+	created.restorePVC = restorePVC.Name
+	restorePod, err := c.createPod(ctx, canaryRestorePodGenerateName, restorePVC.Name)
+	if err != nil {
+		return StageRestore, errors.Wrap(err, "failed to create restored pod")
+	}
+	created.restorePod = restorePod.Name
+	if err := c.appCreator.WaitForPVCReady(ctx, c.args.Namespace, restorePVC.Name); err != nil {
+		return StageRestore, errors.Wrap(err, "restored PVC failed to become ready")
+	}
+	if err := c.appCreator.WaitForPodReady(ctx, c.args.Namespace, restorePod.Name); err != nil {
+		return StageRestore, errors.Wrap(err, "restored pod failed to become ready")
+	}
+	result.Restore = time.Since(start)
+	return "", nil
+}
+
+func (c *Canary) createPod(ctx context.Context, generateName, pvcName string) (*v1.Pod, error) {
+	return c.appCreator.CreatePod(ctx, &types.CreatePodArgs{
+		GenerateName:   generateName,
+		Namespace:      c.args.Namespace,
+		RunAsUser:      c.args.RunAsUser,
+		ContainerImage: c.args.ContainerImage,
+		Command:        []string{"/bin/sh"},
+		ContainerArgs:  []string{"-c", "tail -f /dev/null"},
+		PVCMap: map[string]types.VolumePath{
+			pvcName: {
+				MountPath: canaryMountPath,
+			},
+		},
+	})
+}
+
+// deletePod deletes the pod and waits for it to be gone
+func (c *Canary) deletePod(ctx context.Context, name string) error {
+	if err := c.cleaner.DeletePod(ctx, name, c.args.Namespace); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, canaryPodDeletionTimeout)
+	defer cancel()
+	if err := kankube.WaitForPodCompletion(waitCtx, c.args.KubeCli, c.args.Namespace, name); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// cleanup deletes the resources of a run, the ones that depend on others first
+func (c *Canary) cleanup(ctx context.Context, created canaryResources) error {
+	var results []error
+	if created.restorePod != "" {
+		results = append(results, c.cleaner.DeletePod(ctx, created.restorePod, c.args.Namespace))
+	}
+	if created.restorePVC != "" {
+		results = append(results, c.cleaner.DeletePVC(ctx, created.restorePVC, c.args.Namespace))
+	}
+	if created.snapshot != "" {
+		groupVersion, err := c.versionFetcher.GetCSISnapshotGroupVersion()
+		if err == nil {
+			err = c.cleaner.DeleteSnapshot(ctx, created.snapshot, c.args.Namespace, groupVersion)
+		}
+		results = append(results, err)
+	}
+	if created.pod != "" {
+		results = append(results, c.cleaner.DeletePod(ctx, created.pod, c.args.Namespace))
+	}
+	if created.pvc != "" {
+		results = append(results, c.cleaner.DeletePVC(ctx, created.pvc, c.args.Namespace))
+	}
+	var errs []string
+	for _, err := range results {
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to clean up the canary run: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
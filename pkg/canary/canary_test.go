@@ -0,0 +1,200 @@
+package canary
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/golang/mock/gomock"
+	"github.com/kastenhq/kubestr/pkg/csi/mocks"
+	"github.com/kastenhq/kubestr/pkg/csi/types"
+	"github.com/kastenhq/kubestr/pkg/fio"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v4/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeFio struct {
+	args   *fio.RunFIOArgs
+	result *fio.RunFIOResult
+	err    error
+}
+
+func (f *fakeFio) RunFio(ctx context.Context, args *fio.RunFIOArgs) (*fio.RunFIOResult, error) {
+	f.args = args
+	return f.result, f.err
+}
+
+func TestNewCanary(t *testing.T) {
+	c := qt.New(t)
+	kubeCli := fake.NewSimpleClientset()
+	dynCli := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+
+	for _, args := range []CanaryArgs{
+		{},
+		{KubeCli: kubeCli, DynCli: dynCli, StorageClass: "sc"},
+		{KubeCli: kubeCli, DynCli: dynCli, StorageClass: "sc", Namespace: "ns", Interval: -time.Second},
+		{KubeCli: kubeCli, DynCli: dynCli, StorageClass: "sc", Namespace: "ns", PVCSize: "big"},
+	} {
+		_, err := NewCanary(args, nil)
+		c.Assert(err, qt.IsNotNil)
+	}
+
+	canary, err := NewCanary(CanaryArgs{KubeCli: kubeCli, DynCli: dynCli, StorageClass: "sc", Namespace: "ns"}, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(canary.args.PVCSize, qt.Equals, DefaultPVCSize)
+	c.Assert(canary.args.Interval, qt.Equals, DefaultInterval)
+	c.Assert(canary.args.RunTimeout, qt.Equals, DefaultRunTimeout)
+	c.Assert(canary.snapshotCreator, qt.IsNotNil)
+	c.Assert(canary.versionFetcher, qt.IsNotNil)
+}
+
+func TestCanaryRunOnce(t *testing.T) {
+	type prepareArgs struct {
+		appCreator      *mocks.MockApplicationCreator
+		cleaner         *mocks.MockCleaner
+		snapshotCreator *mocks.MockSnapshotCreator
+		versionFetcher  *mocks.MockApiVersionFetcher
+		fio             *fakeFio
+	}
+	someError := errors.New("test error")
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc"}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+	restoreSize := resource.MustParse("1Gi")
+	snapshot := &snapv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "snapshot"},
+		Status:     &snapv1.VolumeSnapshotStatus{RestoreSize: &restoreSize},
+	}
+	fioResult := &fio.RunFIOResult{Result: fio.FioResult{Jobs: []fio.FioJobs{{
+		JobName: "canary",
+		Read:    fio.FioStats{TotalIos: 10, Iops: 300, ClatNs: fio.FioNS{N: 10, Percentile: map[string]float64{"99.000000": 2e6}}},
+		Write:   fio.FioStats{TotalIos: 10, Iops: 100},
+	}}}}
+	groupVersion := &metav1.GroupVersionForDiscovery{Version: "v1"}
+
+	// provisionAndAttach expects the PVC and the pod mounting it to be created and become ready
+	provisionAndAttach := func(pa *prepareArgs) {
+		pa.appCreator.EXPECT().CreatePVC(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, args *types.CreatePVCArgs) (*v1.PersistentVolumeClaim, error) {
+				if args.GenerateName != canaryPVCGenerateName || args.RestoreSize.String() != DefaultPVCSize {
+					return nil, someError
+				}
+				return pvc, nil
+			})
+		pa.appCreator.EXPECT().CreatePod(gomock.Any(), gomock.Any()).Return(pod, nil)
+		pa.appCreator.EXPECT().WaitForPVCReady(gomock.Any(), "ns", "pvc").Return(nil)
+		pa.appCreator.EXPECT().WaitForPodReady(gomock.Any(), "ns", "pod").Return(nil)
+		pa.cleaner.EXPECT().DeletePod(gomock.Any(), "pod", "ns").Return(nil)
+	}
+
+	for _, tc := range []struct {
+		name                string
+		volumeSnapshotClass string
+		prepare             func(*prepareArgs)
+		failedStage         Stage
+		cleanupError        bool
+	}{
+		{
+			name:                "success",
+			volumeSnapshotClass: "vsc",
+			prepare: func(pa *prepareArgs) {
+				provisionAndAttach(pa)
+				pa.fio.result = fioResult
+				pa.snapshotCreator.EXPECT().NewSnapshotter().Return(nil, nil)
+				pa.snapshotCreator.EXPECT().CreateSnapshot(gomock.Any(), nil, gomock.Any()).Return(snapshot, nil)
+				pa.appCreator.EXPECT().CreatePVC(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, args *types.CreatePVCArgs) (*v1.PersistentVolumeClaim, error) {
+						if args.DataSource == nil || args.DataSource.Name != "snapshot" {
+							return nil, someError
+						}
+						return &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "restore-pvc"}}, nil
+					})
+				pa.appCreator.EXPECT().CreatePod(gomock.Any(), gomock.Any()).Return(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "restore-pod"}}, nil)
+				pa.appCreator.EXPECT().WaitForPVCReady(gomock.Any(), "ns", "restore-pvc").Return(nil)
+				pa.appCreator.EXPECT().WaitForPodReady(gomock.Any(), "ns", "restore-pod").Return(nil)
+				pa.cleaner.EXPECT().DeletePod(gomock.Any(), "restore-pod", "ns").Return(nil)
+				pa.cleaner.EXPECT().DeletePVC(gomock.Any(), "restore-pvc", "ns").Return(nil)
+				pa.versionFetcher.EXPECT().GetCSISnapshotGroupVersion().Return(groupVersion, nil)
+				pa.cleaner.EXPECT().DeleteSnapshot(gomock.Any(), gomock.Any(), "ns", groupVersion).Return(nil)
+				pa.cleaner.EXPECT().DeletePVC(gomock.Any(), "pvc", "ns").Return(nil)
+			},
+		},
+		{
+			name: "no-snapshot-class",
+			prepare: func(pa *prepareArgs) {
+				provisionAndAttach(pa)
+				pa.fio.result = fioResult
+				pa.cleaner.EXPECT().DeletePVC(gomock.Any(), "pvc", "ns").Return(someError)
+			},
+			cleanupError: true,
+		},
+		{
+			name: "provision-fails",
+			prepare: func(pa *prepareArgs) {
+				pa.appCreator.EXPECT().CreatePVC(gomock.Any(), gomock.Any()).Return(pvc, nil)
+				pa.appCreator.EXPECT().CreatePod(gomock.Any(), gomock.Any()).Return(pod, nil)
+				pa.appCreator.EXPECT().WaitForPVCReady(gomock.Any(), "ns", "pvc").Return(someError)
+				pa.cleaner.EXPECT().DeletePod(gomock.Any(), "pod", "ns").Return(nil)
+				pa.cleaner.EXPECT().DeletePVC(gomock.Any(), "pvc", "ns").Return(nil)
+			},
+			failedStage: StageProvision,
+		},
+		{
+			name:                "fio-fails",
+			volumeSnapshotClass: "vsc",
+			prepare: func(pa *prepareArgs) {
+				provisionAndAttach(pa)
+				pa.fio.err = someError
+				pa.cleaner.EXPECT().DeletePVC(gomock.Any(), "pvc", "ns").Return(nil)
+			},
+			failedStage: StageFio,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := qt.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			canary, err := NewCanary(CanaryArgs{
+				KubeCli:             fake.NewSimpleClientset(),
+				DynCli:              fakedynamic.NewSimpleDynamicClient(runtime.NewScheme()),
+				StorageClass:        "sc",
+				VolumeSnapshotClass: tc.volumeSnapshotClass,
+				Namespace:           "ns",
+			}, NewMetrics())
+			c.Assert(err, qt.IsNil)
+			pa := &prepareArgs{
+				appCreator:      mocks.NewMockApplicationCreator(ctrl),
+				cleaner:         mocks.NewMockCleaner(ctrl),
+				snapshotCreator: mocks.NewMockSnapshotCreator(ctrl),
+				versionFetcher:  mocks.NewMockApiVersionFetcher(ctrl),
+				fio:             &fakeFio{},
+			}
+			tc.prepare(pa)
+			canary.appCreator = pa.appCreator
+			canary.cleaner = pa.cleaner
+			canary.snapshotCreator = pa.snapshotCreator
+			canary.versionFetcher = pa.versionFetcher
+			canary.fioRunner = pa.fio
+
+			result := canary.RunOnce(context.Background())
+			c.Assert(result.FailedStage, qt.Equals, tc.failedStage)
+			c.Assert(result.CleanupError != "", qt.Equals, tc.cleanupError)
+			if tc.failedStage == "" {
+				c.Assert(pa.fio.args.PVC, qt.Equals, "pvc")
+				c.Assert(pa.fio.args.FIOJobName, qt.Equals, fio.CanaryFIOJob)
+				c.Assert(result.ReadIOPS, qt.Equals, float64(300))
+				c.Assert(result.WriteIOPS, qt.Equals, float64(100))
+				c.Assert(result.ReadLatP99, qt.Equals, 2e6)
+			}
+			c.Assert(result.SnapshotReady > 0, qt.Equals, tc.name == "success")
+			c.Assert(result.Restore > 0, qt.Equals, tc.name == "success")
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package canary
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsPath is where the canary serves its metrics
+const MetricsPath = "/metrics"
+
+// metricDesc describes a metric in the Prometheus text exposition format
+type metricDesc struct {
+	name string
+	kind string // gauge or counter
+	help string
+}
+
+var (
+	runsMetric        = metricDesc{"kubestr_canary_runs_total", "counter", "Canary runs."}
+	failuresMetric    = metricDesc{"kubestr_canary_failures_total", "counter", "Canary runs that failed, by the stage that failed."}
+	provisionMetric   = metricDesc{"kubestr_canary_provision_seconds", "gauge", "Time from creating the PVC until it was bound in the last successful provisioning."}
+	attachMetric      = metricDesc{"kubestr_canary_attach_seconds", "gauge", "Time from the PVC being bound until the pod mounting it was ready in the last successful attach."}
+	iopsMetric        = metricDesc{"kubestr_canary_fio_iops", "gauge", "IOPS of the last successful fio run."}
+	latencyP99Metric  = metricDesc{"kubestr_canary_fio_latency_p99_seconds", "gauge", "99th percentile completion latency of the last successful fio run."}
+	snapshotMetric    = metricDesc{"kubestr_canary_snapshot_ready_seconds", "gauge", "Time from creating the snapshot until it was ready to use in the last successful snapshot."}
+	restoreMetric     = metricDesc{"kubestr_canary_restore_seconds", "gauge", "Time from restoring the snapshot until the pod mounting it was ready in the last successful restore."}
+	lastRunMetric     = metricDesc{"kubestr_canary_last_run_timestamp_seconds", "gauge", "Unix time the last canary run started."}
+	lastSuccessMetric = metricDesc{"kubestr_canary_last_success_timestamp_seconds", "gauge", "Unix time the last successful canary run started."}
+	exportedMetrics   = []metricDesc{runsMetric, failuresMetric, provisionMetric, attachMetric, iopsMetric, latencyP99Metric,
+		snapshotMetric, restoreMetric, lastRunMetric, lastSuccessMetric}
+)
+
+// Metrics holds the results of canary runs and serves them in the Prometheus text exposition format.
+// Gauges keep the value of the last run the stage succeeded in.
+type Metrics struct {
+	mu     sync.Mutex
+	values map[string]map[string]float64 // metric name to labels to value
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{values: map[string]map[string]float64{}}
+}
+
+func (m *Metrics) set(metric metricDesc, labels string, value float64) {
+	if m.values[metric.name] == nil {
+		m.values[metric.name] = map[string]float64{}
+	}
+	m.values[metric.name][labels] = value
+}
+
+func (m *Metrics) add(metric metricDesc, labels string, value float64) {
+	if m.values[metric.name] == nil {
+		m.values[metric.name] = map[string]float64{}
+	}
+	m.values[metric.name][labels] += value
+}
+
+// Observe records the result of a canary run
+func (m *Metrics) Observe(r *CanaryResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sc := labelPair("storage_class", r.StorageClass)
+	m.add(runsMetric, sc, 1)
+	// every stage is exported from the first run on so that rates work from zero
+	for _, stage := range Stages {
+		failed := stage == r.FailedStage || (stage == StageCleanup && r.CleanupError != "")
+		m.add(failuresMetric, sc+","+labelPair("stage", string(stage)), boolValue(failed))
+	}
+	m.set(lastRunMetric, sc, float64(r.Start.Unix()))
+	if r.FailedStage == "" && r.CleanupError == "" {
+		m.set(lastSuccessMetric, sc, float64(r.Start.Unix()))
+	}
+	if r.passed(StageAttach) {
+		m.set(provisionMetric, sc, r.Provision.Seconds())
+		m.set(attachMetric, sc, r.Attach.Seconds())
+	}
+	if r.passed(StageFio) {
+		for direction, values := range map[string][2]float64{
+			"read":  {r.ReadIOPS, r.ReadLatP99},
+			"write": {r.WriteIOPS, r.WriteLatP99},
+		} {
+			labels := sc + "," + labelPair("direction", direction)
+			m.set(iopsMetric, labels, values[0])
+			m.set(latencyP99Metric, labels, time.Duration(values[1]).Seconds())
+		}
+	}
+	if r.passed(StageSnapshot) && r.SnapshotReady > 0 {
+		m.set(snapshotMetric, sc, r.SnapshotReady.Seconds())
+	}
+	if r.passed(StageRestore) && r.Restore > 0 {
+		m.set(restoreMetric, sc, r.Restore.Seconds())
+	}
+}
+
+// passed reports whether the run got past the stage
+func (r *CanaryResult) passed(stage Stage) bool {
+	if r.FailedStage == "" {
+		return true
+	}
+	for _, s := range Stages {
+		switch s {
+		case r.FailedStage:
+			return false
+		case stage:
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	for _, metric := range exportedMetrics {
+		values, ok := m.values[metric.name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		labels := make([]string, 0, len(values))
+		for l := range values {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			fmt.Fprintf(&b, "%s{%s} %g\n", metric.name, l, values[l])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// labelPair formats a label, escaping the value as the exposition format requires
+func labelPair(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
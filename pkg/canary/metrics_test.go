@@ -0,0 +1,73 @@
+package canary
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMetricsObserve(t *testing.T) {
+	c := qt.New(t)
+	m := NewMetrics()
+	start := time.Unix(1700000000, 0)
+	m.Observe(&CanaryResult{
+		StorageClass:  "fast",
+		Start:         start,
+		Provision:     2 * time.Second,
+		Attach:        1500 * time.Millisecond,
+		ReadIOPS:      300,
+		WriteIOPS:     100,
+		ReadLatP99:    2e6,
+		SnapshotReady: 4 * time.Second,
+		Restore:       3 * time.Second,
+	})
+	m.Observe(&CanaryResult{
+		StorageClass: "fast",
+		Start:        start.Add(time.Minute),
+		Provision:    5 * time.Second,
+		Attach:       time.Second,
+		FailedStage:  StageFio,
+		Error:        "fio failed",
+	})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", MetricsPath, nil))
+	c.Assert(rec.Header().Get("Content-Type"), qt.Matches, "text/plain.*")
+	out := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE kubestr_canary_runs_total counter",
+		`kubestr_canary_runs_total{storage_class="fast"} 2`,
+		`kubestr_canary_failures_total{storage_class="fast",stage="fio"} 1`,
+		`kubestr_canary_failures_total{storage_class="fast",stage="provision"} 0`,
+		// the failed run still provisioned and attached
+		`kubestr_canary_provision_seconds{storage_class="fast"} 5`,
+		`kubestr_canary_attach_seconds{storage_class="fast"} 1`,
+		// fio values are kept from the last run that got past fio
+		`kubestr_canary_fio_iops{storage_class="fast",direction="read"} 300`,
+		`kubestr_canary_fio_latency_p99_seconds{storage_class="fast",direction="read"} 0.002`,
+		`kubestr_canary_snapshot_ready_seconds{storage_class="fast"} 4`,
+		`kubestr_canary_last_run_timestamp_seconds{storage_class="fast"} 1.70000006e+09`,
+		`kubestr_canary_last_success_timestamp_seconds{storage_class="fast"} 1.7e+09`,
+	} {
+		c.Check(strings.Contains(out, line+"\n"), qt.IsTrue, qt.Commentf("missing %q in:\n%s", line, out))
+	}
+	// metrics are written in a stable order
+	c.Assert(strings.Index(out, "kubestr_canary_runs_total") < strings.Index(out, "kubestr_canary_restore_seconds"), qt.IsTrue)
+}
+
+func TestCanaryResultPassed(t *testing.T) {
+	c := qt.New(t)
+	r := &CanaryResult{FailedStage: StageSnapshot}
+	c.Assert(r.passed(StageFio), qt.IsTrue)
+	c.Assert(r.passed(StageSnapshot), qt.IsFalse)
+	c.Assert(r.passed(StageRestore), qt.IsFalse)
+	c.Assert((&CanaryResult{}).passed(StageRestore), qt.IsTrue)
+}
+
+func TestLabelPair(t *testing.T) {
+	c := qt.New(t)
+	c.Assert(labelPair("storage_class", `a"b\c`), qt.Equals, `storage_class="a\"b\\c"`)
+}
@@ -361,6 +361,13 @@ type snapshotCreate struct {
 	dynCli  dynamic.Interface
 }
 
+func NewSnapshotCreator(kubeCli kubernetes.Interface, dynCli dynamic.Interface) SnapshotCreator {
+	return &snapshotCreate{
+		kubeCli: kubeCli,
+		dynCli:  dynCli,
+	}
+}
+
 func (c *snapshotCreate) NewSnapshotter() (kansnapshot.Snapshotter, error) {
 	if c.kubeCli == nil {
 		return nil, fmt.Errorf("kubeCli not initialized")
@@ -497,6 +504,12 @@ type apiVersionFetch struct {
 	kubeCli kubernetes.Interface
 }
 
+func NewApiVersionFetcher(kubeCli kubernetes.Interface) ApiVersionFetcher {
+	return &apiVersionFetch{
+		kubeCli: kubeCli,
+	}
+}
+
 func (p *apiVersionFetch) GetCSISnapshotGroupVersion() (*metav1.GroupVersionForDiscovery, error) {
 	if p.kubeCli == nil {
 		return nil, fmt.Errorf("kubeCli not initialized")
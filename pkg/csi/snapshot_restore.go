@@ -78,7 +78,9 @@ func (r *SnapshotRestoreRunner) RunSnapshotRestoreHelper(ctx context.Context, ar
 	data := time.Now().Format("20060102150405")
 
 	fmt.Println("Creating application")
+	start := time.Now()
 	results.OriginalPod, results.OriginalPVC, err = r.srSteps.CreateApplication(ctx, args, data)
+	results.Timings.CreateApplication = time.Since(start)
 
 	if err == nil {
 		if results.OriginalPod != nil && results.OriginalPVC != nil {
@@ -90,7 +92,9 @@ func (r *SnapshotRestoreRunner) RunSnapshotRestoreHelper(ctx context.Context, ar
 	snapName := snapshotPrefix + data
 	if err == nil {
 		fmt.Println("Taking a snapshot")
+		start = time.Now()
 		results.Snapshot, err = r.srSteps.SnapshotApplication(ctx, args, results.OriginalPVC, snapName)
+		results.Timings.SnapshotApplication = time.Since(start)
 	}
 
 	if err == nil {
@@ -98,7 +102,9 @@ func (r *SnapshotRestoreRunner) RunSnapshotRestoreHelper(ctx context.Context, ar
 			fmt.Printf("  -> Created snapshot (%s)\n", results.Snapshot.Name)
 		}
 		fmt.Println("Restoring application")
+		start = time.Now()
 		results.ClonedPod, results.ClonedPVC, err = r.srSteps.RestoreApplication(ctx, args, results.Snapshot)
+		results.Timings.RestoreApplication = time.Since(start)
 	}
 
 	if err == nil {
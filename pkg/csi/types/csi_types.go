@@ -35,6 +35,14 @@ type CSISnapshotRestoreResults struct {
 	Snapshot    *snapv1.VolumeSnapshot
 	ClonedPVC   *v1.PersistentVolumeClaim
 	ClonedPod   *v1.Pod
+	Timings     CSISnapshotRestoreTimings
+}
+
+// CSISnapshotRestoreTimings are the durations of the steps of the snapshot and restore check
+type CSISnapshotRestoreTimings struct {
+	CreateApplication   time.Duration // until the pod mounting the new PVC was ready
+	SnapshotApplication time.Duration // until the snapshot was ready to use
+	RestoreApplication  time.Duration // until the pod mounting the restored PVC was ready
 }
 
 type CreatePVCArgs struct {
@@ -1,17 +1,19 @@
 package fio
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/briandowns/spinner"
 	kankube "github.com/kanisterio/kanister/pkg/kube"
 	"github.com/kastenhq/kubestr/pkg/common"
 	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	sv1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -27,6 +29,8 @@ const (
 	PodNamespaceEnvKey = "POD_NAMESPACE"
 	// DefaultFIOJob describes the default FIO job
 	DefaultFIOJob = "default-fio"
+	// CanaryFIOJob is the short predefined test the storage canary runs
+	CanaryFIOJob = "canary"
 	// KubestrFIOJobGenName describes the generate name
 	KubestrFIOJobGenName = "kubestr-fio"
 	// ConfigMapJobKey is the default fio job key
@@ -45,8 +49,22 @@ const (
 	ConfigMapMountPath = "/etc/fio-config"
 	// VolumeMountPath is the path where we mount the volume
 	VolumeMountPath = "/dataset"
+	// VolumeDevicePath is the path where we attach the volume in Block mode
+	VolumeDevicePath = "/dev/kubestr-fio"
+	// ScratchDirName is the directory FIO runs in when using an existing PVC
+	ScratchDirName = "kubestr-fio-scratch"
 	// CreatedByFIOLabel is the key that desrcibes the label used to mark configmaps
 	CreatedByFIOLabel = "createdbyfio"
+	// FIORunLabel is the key of the label that groups the pods of a single run
+	FIORunLabel = "kubestr-fio-run"
+	// OutputFormatJSON is the default fio output format
+	OutputFormatJSON = "json"
+	// OutputFormatJSONPlus adds completion latency histogram bins to the JSON output
+	OutputFormatJSONPlus = "json+"
+	// CleanupTimeout bounds the deletion of the resources of a run, it applies even after the run was cancelled
+	CleanupTimeout = 2 * time.Minute
+	// stopFIOScript interrupts fio so it exits cleanly and kills it if it is still running 30 seconds later
+	stopFIOScript = "pkill -INT fio; for i in $(seq 30); do pgrep fio > /dev/null || exit 0; sleep 1; done; pkill -KILL fio"
 )
 
 // FIO is an interface that represents FIO related commands
@@ -68,28 +86,210 @@ type RunFIOArgs struct {
 	FIOJobFilepath string
 	FIOJobName     string
 	Image          string
+	VolumeMode     v1.PersistentVolumeMode // missing implies v1.PersistentVolumeFilesystem
+	PVC            string                  // existing PVC to run against, StorageClass and Size are ignored
+	Clients        int                     // number of pods sharing a ReadWriteMany PVC, missing implies 1
+	SharedFile     bool                    // clients share a single file instead of a directory each
+	Thresholds     []Threshold
+	Histogram      bool                 // report completion latency histograms (json+ output)
+	LogInterval    time.Duration        // collect bandwidth, IOPS and latency logs averaged over this interval, 0 disables them
+	StatusInterval time.Duration        // report progress at this interval while FIO runs, 0 disables it
+	Pod            PodOptions           // scheduling, resources and metadata of the FIO pods
+	Variant        *StorageClassVariant // run against a temporary clone of StorageClass with these overrides
+	Vars           map[string]string    // variables of the job file template
+	Verify         bool                 // read back the data every job writes and check it
+	VerifyBacklog  int64                // with Verify, check the data after this many blocks were written
+	Precondition   bool                 // sequentially fill the files of the test before it runs
+	SteadyState    *SteadyState         // stop jobs once their throughput settles
+	References     []ReferenceMedium    // also run the test against these node local volumes, in the same pods
+	ReferenceSize  string               // size limit of the memory reference, missing implies DefaultMemoryReferenceSize
+	Repeat         int                  // run the test this many times on the same PVC, missing implies 1
+	RepeatPause    time.Duration        // time to wait between repeated runs
+	RepeatMaxCV    *float64             // coefficient of variation above which a metric of repeated runs is flagged, missing implies DefaultMaxCV
+	AsJob          bool                 // run FIO to completion in a Job instead of over an exec stream
+	ClientServer   bool                 // run fio --server in the clients and drive them from a controller pod with fio --client
+	SkipLint       bool                 // print lint errors as warnings instead of refusing to run the job file
+	OnProgress     func(FioProgress)
 }
 
 func (a *RunFIOArgs) Validate() error {
-	if a.StorageClass == "" || a.Size == "" || a.Namespace == "" {
+	if a.PVC != "" {
+		if a.Namespace == "" {
+			return fmt.Errorf("required fields are missing: (PVC, Namespace)")
+		}
+	} else if a.StorageClass == "" || a.Size == "" || a.Namespace == "" {
 		return fmt.Errorf("required fields are missing: (StorageClass, Size, Namespace)")
 	}
+	if a.Variant != nil && a.PVC != "" {
+		return fmt.Errorf("a StorageClass variant can't be used with an existing PVC")
+	}
+	if len(a.Vars) > 0 && a.FIOJobFilepath == "" {
+		return fmt.Errorf("variables can only be set for a job file")
+	}
+	if err := validateFioVars(a.Vars); err != nil {
+		return err
+	}
+	if a.VerifyBacklog < 0 || (a.VerifyBacklog > 0 && !a.Verify) {
+		return fmt.Errorf("invalid verify backlog (%d), it requires verification", a.VerifyBacklog)
+	}
+	for _, medium := range a.References {
+		if err := medium.Validate(); err != nil {
+			return err
+		}
+	}
+	if a.ReferenceSize != "" {
+		if size, err := resource.ParseQuantity(a.ReferenceSize); err != nil || size.Sign() <= 0 {
+			return fmt.Errorf("invalid memory reference size (%s)", a.ReferenceSize)
+		}
+	}
+	if a.SteadyState != nil {
+		if err := a.SteadyState.Validate(); err != nil {
+			return err
+		}
+	}
+	if a.Repeat < 0 || a.RepeatPause < 0 || a.maxCV() < 0 {
+		return fmt.Errorf("invalid repeat (%d), pause (%s) or maximum coefficient of variation (%g)", a.Repeat, a.RepeatPause, a.maxCV())
+	}
+	if a.AsJob && (a.clientCount() > 1 || a.LogInterval > 0 || a.StatusInterval > 0 || len(a.References) > 0 || a.repeatCount() > 1) {
+		return fmt.Errorf("clients, logs, progress, references and repeated runs are not supported when running as a Job")
+	}
+	if a.ClientServer && (a.AsJob || a.LogInterval > 0 || a.StatusInterval > 0 || len(a.References) > 0) {
+		return fmt.Errorf("a Job, logs, progress and references are not supported in client/server mode")
+	}
+	if a.Clients < 0 {
+		return fmt.Errorf("invalid number of clients (%d)", a.Clients)
+	}
+	if a.LogInterval < 0 || (a.LogInterval > 0 && a.LogInterval < time.Millisecond) {
+		return fmt.Errorf("invalid log interval (%s)", a.LogInterval)
+	}
+	if a.StatusInterval < 0 || (a.StatusInterval > 0 && a.StatusInterval < time.Millisecond) {
+		return fmt.Errorf("invalid status interval (%s)", a.StatusInterval)
+	}
+	switch a.VolumeMode {
+	case "", v1.PersistentVolumeFilesystem, v1.PersistentVolumeBlock:
+	default:
+		return fmt.Errorf("unsupported volume mode (%s), options(%s, %s)", a.VolumeMode, v1.PersistentVolumeFilesystem, v1.PersistentVolumeBlock)
+	}
+	if a.isBlock() && len(a.References) > 0 {
+		return fmt.Errorf("references run on a filesystem and can't be compared with a %s volume", v1.PersistentVolumeBlock)
+	}
+	for _, t := range a.Thresholds {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// outputFormat is the fio output format matching the requested detail
+func (a *RunFIOArgs) outputFormat() string {
+	if a.Histogram {
+		return OutputFormatJSONPlus
+	}
+	return OutputFormatJSON
+}
+
+// fioRunOptions controls how fio is run and how its output is reported
+type fioRunOptions struct {
+	outputFormat   string
+	statusInterval time.Duration
+	progress       func(FioResult)
+}
+
+// runOptions returns the options of a single fio invocation, progress is set per client
+func (a *RunFIOArgs) runOptions() fioRunOptions {
+	return fioRunOptions{
+		outputFormat:   a.outputFormat(),
+		statusInterval: a.StatusInterval,
+	}
+}
+
+// fioCommand is the fio command line running the job file with the given options
+func (o fioRunOptions) fioCommand(fioArgs []string, jobFilePath string) []string {
+	command := append([]string{"fio"}, fioArgs...)
+	command = append(command, jobFilePath, "--output-format="+o.outputFormat)
+	if o.statusInterval > 0 {
+		command = append(command, fmt.Sprintf("--status-interval=%dms", o.statusInterval.Milliseconds()))
+	}
+	return command
+}
+
+func (a *RunFIOArgs) isBlock() bool {
+	return a.VolumeMode == v1.PersistentVolumeBlock
+}
+
+func (a *RunFIOArgs) repeatCount() int {
+	if a.Repeat < 1 {
+		return 1
+	}
+	return a.Repeat
+}
+
+func (a *RunFIOArgs) maxCV() float64 {
+	if a.RepeatMaxCV == nil {
+		return DefaultMaxCV
+	}
+	return *a.RepeatMaxCV
+}
+
+func (a *RunFIOArgs) clientCount() int {
+	if a.Clients < 1 {
+		return 1
+	}
+	return a.Clients
+}
+
 type RunFIOResult struct {
 	Size         string            `json:"size,omitempty"`
+	PVC          string            `json:"pvc,omitempty"`
 	StorageClass *sv1.StorageClass `json:"storageClass,omitempty"`
+	TestFile     string            `json:"testFile,omitempty"` // the name of the job file FIO ran
 	FioConfig    string            `json:"fioConfig,omitempty"`
+	Precondition time.Duration     `json:"precondition,omitempty"` // time spent filling the test files
 	Result       FioResult         `json:"result,omitempty"`
+	Clients      []FioClientResult `json:"clients,omitempty"`
+	Thresholds   []ThresholdResult `json:"thresholds,omitempty"`
+	Logs         []FioLogSeries    `json:"logs,omitempty"`
+	References   []FioReference    `json:"references,omitempty"`
+	Repeats      *FioRepeats       `json:"repeats,omitempty"`
+	Job          string            `json:"job,omitempty"`        // the Job that ran FIO, also the id to attach to it
+	AllClients   *FioJobs          `json:"allClients,omitempty"` // the aggregate fio reported in client/server mode
 }
 
-func (f *FIOrunner) RunFio(ctx context.Context, args *RunFIOArgs) (*RunFIOResult, error) {
-	f.fioSteps = &fioStepper{
-		cli:          f.Cli,
-		podReady:     &podReadyChecker{cli: f.Cli},
-		kubeExecutor: &kubeExecutor{cli: f.Cli},
+func (r RunFIOResult) Print() string {
+	res := r.Result.Print()
+	if r.Precondition > 0 {
+		res = fmt.Sprintf("Preconditioned in %s\n", r.Precondition.Round(time.Second)) + res
 	}
+	if len(r.Clients) > 0 {
+		res += fmt.Sprintf("\nAggregated across %d clients. Per client results:\n", len(r.Clients))
+		if r.AllClients != nil {
+			res += printAllClients(*r.AllClients, len(r.Clients))
+		}
+		for _, client := range r.Clients {
+			res += client.Print()
+		}
+	}
+	if r.Repeats != nil {
+		res += "\n" + r.Repeats.Print()
+	}
+	for _, reference := range r.References {
+		res += "\n" + reference.Print()
+	}
+	if len(r.Logs) > 0 {
+		res += "\nTime series:\n"
+		for _, series := range r.Logs {
+			res += series.Print() + "\n"
+			if series.Drop != nil {
+				res += fmt.Sprintf("    %s\n", series.Drop.Print())
+			}
+		}
+	}
+	return res
+}
+
+func (f *FIOrunner) RunFio(ctx context.Context, args *RunFIOArgs) (*RunFIOResult, error) {
+	f.fioSteps = newFioStepper(f.Cli)
 	return f.RunFioHelper(ctx, args)
 
 }
@@ -112,65 +312,345 @@ func (f *FIOrunner) RunFioHelper(ctx context.Context, args *RunFIOArgs) (*RunFIO
 		return nil, errors.Wrapf(err, "unable to find nodes satisfying node selector (%v)", args.NodeSelector)
 	}
 
-	sc, err := f.fioSteps.storageClassExists(ctx, args.StorageClass)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot find StorageClass")
+	var existingPVC *v1.PersistentVolumeClaim
+	if args.PVC != "" {
+		pvc, err := f.fioSteps.getPVC(ctx, args.PVC, args.Namespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find PVC (%s)", args.PVC)
+		}
+		if err := useExistingPVC(args, pvc); err != nil {
+			return nil, err
+		}
+		existingPVC = pvc
+	}
+
+	if err := lintFioJob(args); err != nil {
+		return nil, err
+	}
+
+	// once a Job runs the test, it owns the resources of the run
+	detached := false
+	var serviceName string
+	var sc *sv1.StorageClass
+	if args.StorageClass != "" {
+		storageClass, err := f.fioSteps.storageClassExists(ctx, args.StorageClass)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot find StorageClass")
+		}
+		sc = storageClass
+	}
+	if sc != nil && args.Variant != nil {
+		variant, err := f.fioSteps.createStorageClass(ctx, storageClassVariant(sc, *args.Variant))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create a variant of StorageClass (%s)", sc.Name)
+		}
+		defer cleanup(ctx, func(ctx context.Context) error {
+			if detached {
+				return nil
+			}
+			return f.fioSteps.deleteStorageClass(ctx, variant)
+		})
+		fmt.Printf("StorageClass created %s (%s with %s)\n", variant.Name, sc.Name, args.Variant)
+		sc = variant
+	}
+	storageClassName := args.StorageClass
+	if sc != nil {
+		storageClassName = sc.Name
 	}
 
 	configMap, err := f.fioSteps.loadConfigMap(ctx, args)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create a ConfigMap")
 	}
-	defer func() {
-		_ = f.fioSteps.deleteConfigMap(context.TODO(), configMap, args.Namespace)
-	}()
+	defer cleanup(ctx, func(ctx context.Context) error {
+		if detached {
+			return nil
+		}
+		return f.fioSteps.deleteConfigMap(ctx, configMap, args.Namespace)
+	})
 
 	testFileName, err := fioTestFilename(configMap.Data)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get test file name")
 	}
+	_, precondition := configMap.Data[PreconditionFileName]
+	writes := fioJobWrites(configMap.Data[testFileName]) || precondition
+	if existingPVC != nil && args.isBlock() && writes {
+		return nil, fmt.Errorf("refusing to run FIO test (%s) that writes to the raw block device of existing PVC (%s)", testFileName, args.PVC)
+	}
 
-	pvc, err := f.fioSteps.createPVC(ctx, args.StorageClass, args.Size, args.Namespace)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create PVC")
+	readOnly := existingPVC != nil && !writes
+	if jobs := fioJobsWithoutFilename(configMap.Data[testFileName]); readOnly && !args.isBlock() && len(jobs) > 0 {
+		return nil, fmt.Errorf("FIO test (%s) only reads, so PVC (%s) is mounted read-only, but jobs (%s) don't name the existing files to read with filename", testFileName, args.PVC, strings.Join(jobs, ", "))
+	}
+	if existingPVC != nil && args.clientCount() > 1 && !pvcSharable(existingPVC, readOnly) {
+		return nil, fmt.Errorf("PVC (%s) cannot be mounted by more than one client, access modes (%v)", args.PVC, existingPVC.Spec.AccessModes)
 	}
-	defer func() {
-		_ = f.fioSteps.deletePVC(context.TODO(), pvc.Name, args.Namespace)
-	}()
-	fmt.Println("PVC created", pvc.Name)
 
-	pod, err := f.fioSteps.createPod(ctx, pvc.Name, configMap.Name, testFileName, args.Namespace, args.NodeSelector, args.Image)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create POD")
+	pvc := existingPVC
+	if pvc == nil {
+		accessMode := v1.ReadWriteOnce
+		if args.clientCount() > 1 {
+			accessMode = v1.ReadWriteMany
+		}
+		pvc, err = f.fioSteps.createPVC(ctx, storageClassName, args.Size, args.Namespace, args.VolumeMode, accessMode)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create PVC")
+		}
+		defer cleanup(ctx, func(ctx context.Context) error {
+			if detached {
+				return nil
+			}
+			return f.fioSteps.deletePVC(ctx, pvc.Name, args.Namespace)
+		})
+		fmt.Println("PVC created", pvc.Name)
 	}
-	defer func() {
-		_ = f.fioSteps.deletePod(context.TODO(), pod.Name, args.Namespace)
-	}()
-	fmt.Println("Pod created", pod.Name)
-	fmt.Printf("Running FIO test (%s) on StorageClass (%s) with a PVC of Size (%s)\n", testFileName, args.StorageClass, args.Size)
-	fioOutput, err := f.fioSteps.runFIOCommand(ctx, pod.Name, ContainerName, testFileName, args.Namespace)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed while running FIO test")
+
+	if args.AsJob {
+		run := fioRun{
+			TestFile:     testFileName,
+			FioConfig:    configMap.Data[testFileName],
+			ConfigMap:    configMap.Name,
+			PVC:          pvc.Name,
+			CreatedPVC:   existingPVC == nil,
+			Size:         args.Size,
+			StorageClass: storageClassName,
+			Variant:      args.Variant != nil,
+			Thresholds:   args.Thresholds,
+		}
+		if path, onVolume := jobResultPath(args); onVolume {
+			run.ResultPath = path
+		}
+		spec, err := fioBatchJob(pvc.Name, configMap.Name, testFileName, precondition, readOnly, run, args)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create a Job")
+		}
+		job, err := f.fioSteps.createJob(ctx, spec)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create a Job")
+		}
+		detached = true
+		fmt.Printf("Running FIO test (%s) in Job (%s)\n", testFileName, job.Name)
+		return f.finishJob(ctx, job)
+	}
+
+	if args.ClientServer {
+		svc, err := f.fioSteps.createService(ctx, fioServerService(configMap.Name, args.Namespace))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the Service of the FIO servers")
+		}
+		defer cleanup(ctx, func(ctx context.Context) error {
+			return f.fioSteps.deleteService(ctx, svc)
+		})
+		fmt.Println("Service created", svc.Name)
+		serviceName = svc.Name
+	}
+	clients := make([]fioClient, 0, args.clientCount())
+	for i := 0; i < args.clientCount(); i++ {
+		pod, err := f.fioSteps.createPod(ctx, pvc.Name, configMap.Name, testFileName, readOnly, args)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create POD")
+		}
+		defer cleanup(ctx, func(ctx context.Context) error {
+			return f.fioSteps.deletePod(ctx, pod.Name, args.Namespace)
+		})
+		fmt.Println("Pod created", pod.Name)
+		fioArgs, dir := clientFioArgs(args, i, readOnly)
+		if args.LogInterval > 0 {
+			fioArgs = append(fioArgs, logFioArgs(LogDirPath, args.LogInterval)...)
+		}
+		clients = append(clients, fioClient{pod: pod, fioArgs: fioArgs, dir: dir})
+	}
+
+	if args.PVC != "" && !readOnly && !args.isBlock() {
+		defer cleanup(ctx, func(ctx context.Context) error {
+			return f.fioSteps.deleteScratchDir(ctx, clients[0].pod.Name, ContainerName, args.Namespace, scratchDirPath())
+		})
+	}
+	for _, client := range clients {
+		if client.dir == "" {
+			continue
+		}
+		if err := f.fioSteps.createScratchDir(ctx, client.pod.Name, ContainerName, args.Namespace, client.dir); err != nil {
+			return nil, errors.Wrap(err, "failed to create scratch directory")
+		}
+	}
+	if args.LogInterval > 0 {
+		for _, client := range clients {
+			if err := f.fioSteps.createScratchDir(ctx, client.pod.Name, ContainerName, args.Namespace, LogDirPath); err != nil {
+				return nil, errors.Wrap(err, "failed to create log directory")
+			}
+		}
+	}
+
+	var controller *v1.Pod
+	var servers map[string]string
+	if args.ClientServer {
+		servers, err = f.fioSteps.discoverServers(ctx, serviceName, args.Namespace, len(clients))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to discover the FIO servers")
+		}
+		for _, client := range clients {
+			if _, ok := servers[client.pod.Name]; !ok {
+				return nil, fmt.Errorf("no FIO server found for pod (%s)", client.pod.Name)
+			}
+		}
+		controller, err = f.fioSteps.createControllerPod(ctx, configMap.Name, args)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the FIO controller pod")
+		}
+		defer cleanup(ctx, func(ctx context.Context) error {
+			return f.fioSteps.deletePod(ctx, controller.Name, args.Namespace)
+		})
+		fmt.Println("Controller pod created", controller.Name)
+	}
+
+	if args.isBlock() && writes {
+		fmt.Printf("Warning: FIO test (%s) writes directly to the raw block device (%s), any data on the volume will be destroyed.\n", testFileName, VolumeDevicePath)
+	}
+	if existingPVC != nil {
+		fmt.Printf("Running FIO test (%s) on existing PVC (%s) of Size (%s)\n", testFileName, args.PVC, args.Size)
+	} else {
+		fmt.Printf("Running FIO test (%s) on StorageClass (%s) with a PVC of Size (%s)\n", testFileName, storageClassName, args.Size)
 	}
-	return &RunFIOResult{
+	if len(clients) > 1 {
+		fmt.Printf("Starting FIO on %d clients\n", len(clients))
+	}
+	var preconditionTime time.Duration
+	if precondition {
+		fmt.Println("Preconditioning the test files")
+		start := time.Now()
+		if _, err := f.runClients(ctx, preconditionClients(clients, args), PreconditionFileName, args); err != nil {
+			return nil, errors.Wrap(err, "failed while preconditioning")
+		}
+		preconditionTime = time.Since(start)
+	}
+	var clientResults []FioClientResult
+	var allClients *FioJobs
+	var iterations []FioResult
+	for i := 0; i < args.repeatCount(); i++ {
+		if i > 0 {
+			if err := pause(ctx, args.RepeatPause); err != nil {
+				return nil, err
+			}
+			fmt.Printf("Repeating FIO test (%s), run %d of %d\n", testFileName, i+1, args.repeatCount())
+		}
+		if args.ClientServer {
+			clientResults, allClients, err = f.runClientServer(ctx, controller, clients, servers, testFileName, args)
+		} else {
+			clientResults, err = f.runClients(ctx, clients, testFileName, args)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed while running FIO test")
+		}
+		iteration := clientResults[0].Result
+		if len(clientResults) > 1 {
+			iteration = aggregateFioResults(clientResults)
+		}
+		iterations = append(iterations, iteration)
+	}
+	// the report, thresholds and logs are those of the last run
+	result := &RunFIOResult{
 		Size:         args.Size,
+		PVC:          args.PVC,
 		StorageClass: sc,
+		TestFile:     testFileName,
 		FioConfig:    configMap.Data[testFileName],
-		Result:       fioOutput,
-	}, nil
+		Precondition: preconditionTime,
+		Result:       iterations[len(iterations)-1],
+		AllClients:   allClients,
+	}
+	if len(clientResults) > 1 {
+		result.Clients = clientResults
+	}
+	if len(iterations) > 1 {
+		result.Repeats = &FioRepeats{
+			Iterations: iterations,
+			Stats:      repeatStats(iterations),
+			MaxCV:      args.maxCV(),
+		}
+	}
+	if len(args.Thresholds) > 0 {
+		result.Thresholds = EvaluateThresholds(result.Result, args.Thresholds)
+	}
+	if args.LogInterval > 0 {
+		for i, client := range clients {
+			files, err := f.fioSteps.collectLogs(ctx, client.pod.Name, ContainerName, args.Namespace, LogDirPath)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to collect FIO logs")
+			}
+			pod := ""
+			if len(clients) > 1 {
+				pod = client.pod.Name
+			}
+			series, err := parseFioLogs(files, configMap.Data[testFileName], clientResults[i].Result.Jobs, pod)
+			if err != nil {
+				return nil, err
+			}
+			result.Logs = append(result.Logs, series...)
+		}
+	}
+	for _, medium := range args.References {
+		result.References = append(result.References, f.runReference(ctx, clients, testFileName, medium, result.Result, args))
+	}
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "FIO was interrupted")
+	}
+	return result, nil
+}
+
+// useExistingPVC fills in the StorageClass, Size and VolumeMode arguments from an existing PVC
+func useExistingPVC(args *RunFIOArgs, pvc *v1.PersistentVolumeClaim) error {
+	volumeMode := v1.PersistentVolumeFilesystem
+	if pvc.Spec.VolumeMode != nil {
+		volumeMode = *pvc.Spec.VolumeMode
+	}
+	if args.VolumeMode != "" && args.VolumeMode != volumeMode {
+		return fmt.Errorf("volume mode (%s) does not match the volume mode of PVC (%s) (%s)", args.VolumeMode, pvc.Name, volumeMode)
+	}
+	args.VolumeMode = volumeMode
+	if args.isBlock() && len(args.References) > 0 {
+		return fmt.Errorf("references run on a filesystem and can't be compared with PVC (%s) in %s mode", pvc.Name, volumeMode)
+	}
+	args.StorageClass = ""
+	if pvc.Spec.StorageClassName != nil {
+		args.StorageClass = *pvc.Spec.StorageClassName
+	}
+	if capacity, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok {
+		args.Size = capacity.String()
+	} else if request, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+		args.Size = request.String()
+	}
+	return nil
 }
 
 type fioSteps interface {
 	validateNamespace(ctx context.Context, namespace string) error
 	validateNodeSelector(ctx context.Context, selector map[string]string) error
 	storageClassExists(ctx context.Context, storageClass string) (*sv1.StorageClass, error)
+	createStorageClass(ctx context.Context, sc *sv1.StorageClass) (*sv1.StorageClass, error)
+	deleteStorageClass(ctx context.Context, sc *sv1.StorageClass) error
 	loadConfigMap(ctx context.Context, args *RunFIOArgs) (*v1.ConfigMap, error)
-	createPVC(ctx context.Context, storageclass, size, namespace string) (*v1.PersistentVolumeClaim, error)
+	getPVC(ctx context.Context, pvcName, namespace string) (*v1.PersistentVolumeClaim, error)
+	createPVC(ctx context.Context, storageclass, size, namespace string, volumeMode v1.PersistentVolumeMode, accessMode v1.PersistentVolumeAccessMode) (*v1.PersistentVolumeClaim, error)
 	deletePVC(ctx context.Context, pvcName, namespace string) error
-	createPod(ctx context.Context, pvcName, configMapName, testFileName, namespace string, nodeSelector map[string]string, image string) (*v1.Pod, error)
+	createPod(ctx context.Context, pvcName, configMapName, testFileName string, readOnly bool, args *RunFIOArgs) (*v1.Pod, error)
 	deletePod(ctx context.Context, podName, namespace string) error
-	runFIOCommand(ctx context.Context, podName, containerName, testFileName, namespace string) (FioResult, error)
+	createScratchDir(ctx context.Context, podName, containerName, namespace, dir string) error
+	deleteScratchDir(ctx context.Context, podName, containerName, namespace, dir string) error
+	collectLogs(ctx context.Context, podName, containerName, namespace, dir string) (map[string]string, error)
+	runFIOCommand(ctx context.Context, podName, containerName, testFileName, namespace string, fioArgs []string, opts fioRunOptions) (FioResult, error)
+	createJob(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error)
+	getJob(ctx context.Context, name, namespace string) (*batchv1.Job, error)
+	waitForJob(ctx context.Context, name, namespace string) (string, bool, error)
+	readJobResult(ctx context.Context, job *batchv1.Job, path string) (string, error)
+	deleteJob(ctx context.Context, job *batchv1.Job) error
 	deleteConfigMap(ctx context.Context, configMap *v1.ConfigMap, namespace string) error
+	createService(ctx context.Context, svc *v1.Service) (*v1.Service, error)
+	deleteService(ctx context.Context, svc *v1.Service) error
+	discoverServers(ctx context.Context, serviceName, namespace string, count int) (map[string]string, error)
+	createControllerPod(ctx context.Context, configMapName string, args *RunFIOArgs) (*v1.Pod, error)
+	runFIOClients(ctx context.Context, podName, containerName, namespace, script string) (FioResult, error)
 }
 
 type fioStepper struct {
@@ -179,6 +659,14 @@ type fioStepper struct {
 	kubeExecutor kubeExecInterface
 }
 
+func newFioStepper(cli kubernetes.Interface) *fioStepper {
+	return &fioStepper{
+		cli:          cli,
+		podReady:     &podReadyChecker{cli: cli},
+		kubeExecutor: &kubeExecutor{cli: cli},
+	}
+}
+
 func (s *fioStepper) validateNamespace(ctx context.Context, namespace string) error {
 	if _, err := s.cli.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err != nil {
 		return err
@@ -205,24 +693,40 @@ func (s *fioStepper) storageClassExists(ctx context.Context, storageClass string
 	return s.cli.StorageV1().StorageClasses().Get(ctx, storageClass, metav1.GetOptions{})
 }
 
+func (s *fioStepper) createStorageClass(ctx context.Context, sc *sv1.StorageClass) (*sv1.StorageClass, error) {
+	return s.cli.StorageV1().StorageClasses().Create(ctx, sc, metav1.CreateOptions{})
+}
+
+// deleteStorageClass only deletes a StorageClass if it has the label
+func (s *fioStepper) deleteStorageClass(ctx context.Context, sc *sv1.StorageClass) error {
+	if val, ok := sc.Labels[CreatedByFIOLabel]; ok && val == "true" {
+		return s.cli.StorageV1().StorageClasses().Delete(ctx, sc.Name, metav1.DeleteOptions{})
+	}
+	return nil
+}
+
 func (s *fioStepper) loadConfigMap(ctx context.Context, args *RunFIOArgs) (*v1.ConfigMap, error) {
+	name, config, err := fioJobConfig(args)
+	if err != nil {
+		return nil, err
+	}
 	configMap := &v1.ConfigMap{
-		Data: make(map[string]string),
+		Data: map[string]string{name: config},
 	}
-	switch {
-	case args.FIOJobFilepath != "":
-		data, err := os.ReadFile(args.FIOJobFilepath)
+	if args.Precondition {
+		precondition, err := preconditionFioJob(config)
 		if err != nil {
-			return nil, errors.Wrap(err, "file reading error")
+			return nil, errors.Wrapf(err, "unable to create a precondition job for fio job (%s)", name)
 		}
-		configMap.Data[filepath.Base(args.FIOJobFilepath)] = string(data)
-	case args.FIOJobName != "":
-		if _, ok := fioJobs[args.FIOJobName]; !ok {
-			return nil, fmt.Errorf("did not find FIO job (%s)", args.FIOJobName)
+		configMap.Data[PreconditionFileName] = precondition
+	}
+	if args.hasReference(ReferenceMemory) {
+		limit := args.memoryReferenceSize()
+		memoryReference, err := memoryReferenceFioJob(config, limit.Value())
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create the memory reference job for fio job (%s)", name)
 		}
-		configMap.Data[args.FIOJobName] = fioJobs[args.FIOJobName]
-	default:
-		configMap.Data[DefaultFIOJob] = fioJobs[DefaultFIOJob]
+		configMap.Data[MemoryReferenceFileName] = memoryReference
 	}
 	// create
 	configMap.GenerateName = KubestrFIOJobGenName
@@ -234,7 +738,85 @@ func (s *fioStepper) loadConfigMap(ctx context.Context, args *RunFIOArgs) (*v1.C
 	return cm, nil
 }
 
-func (s *fioStepper) createPVC(ctx context.Context, storageclass, size, namespace string) (*v1.PersistentVolumeClaim, error) {
+// fioJobConfig returns the name and contents of the fio job file to run, with verification
+// and steady state detection if requested
+func fioJobConfig(args *RunFIOArgs) (string, string, error) {
+	name, config, err := selectedFioJob(args)
+	if err != nil {
+		return "", "", err
+	}
+	if args.Verify {
+		if config, err = verifyFioJob(config, args.VerifyBacklog); err != nil {
+			return "", "", errors.Wrapf(err, "unable to add verification to fio job (%s)", name)
+		}
+	}
+	if args.SteadyState != nil {
+		if config, err = args.SteadyState.fioJob(config); err != nil {
+			return "", "", errors.Wrapf(err, "unable to add steady state detection to fio job (%s)", name)
+		}
+	}
+	return name, config, nil
+}
+
+// selectedFioJob returns the job file or predefined test of the arguments, job files are rendered as templates
+func selectedFioJob(args *RunFIOArgs) (string, string, error) {
+	switch {
+	case args.FIOJobFilepath != "":
+		data, err := os.ReadFile(args.FIOJobFilepath)
+		if err != nil {
+			return "", "", errors.Wrap(err, "file reading error")
+		}
+		name := filepath.Base(args.FIOJobFilepath)
+		config, err := renderFioJob(name, string(data), args)
+		if err != nil {
+			return "", "", err
+		}
+		return name, config, nil
+	case args.FIOJobName != "":
+		job, ok := fioJobs[args.FIOJobName]
+		if !ok {
+			return "", "", fmt.Errorf("did not find FIO job (%s)", args.FIOJobName)
+		}
+		return args.FIOJobName, job.config, nil
+	}
+	return DefaultFIOJob, fioJobs[DefaultFIOJob].config, nil
+}
+
+// LintFioJob lints the job file selected by the arguments and returns its name and the issues found
+func LintFioJob(args *RunFIOArgs) (string, []FioLintIssue, error) {
+	name, config, err := fioJobConfig(args)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, LintFioJobFile(config, args), nil
+}
+
+// lintFioJob checks the job file before anything is created, warnings are printed and so are
+// errors when lint is skipped
+func lintFioJob(args *RunFIOArgs) error {
+	name, issues, err := LintFioJob(args)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, issue := range issues {
+		if issue.Severity == LintError && !args.SkipLint {
+			errs = append(errs, issue.String())
+			continue
+		}
+		fmt.Printf("Warning: FIO job (%s) %s\n", name, issue)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("FIO job (%s) failed lint: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (s *fioStepper) getPVC(ctx context.Context, pvcName, namespace string) (*v1.PersistentVolumeClaim, error) {
+	return s.cli.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
+}
+
+func (s *fioStepper) createPVC(ctx context.Context, storageclass, size, namespace string, volumeMode v1.PersistentVolumeMode, accessMode v1.PersistentVolumeAccessMode) (*v1.PersistentVolumeClaim, error) {
 	sizeResource, err := resource.ParseQuantity(size)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to parse PVC size (%s)", size)
@@ -245,7 +827,7 @@ func (s *fioStepper) createPVC(ctx context.Context, storageclass, size, namespac
 		},
 		Spec: v1.PersistentVolumeClaimSpec{
 			StorageClassName: &storageclass,
-			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			AccessModes:      []v1.PersistentVolumeAccessMode{accessMode},
 			Resources: v1.VolumeResourceRequirements{
 				Requests: v1.ResourceList{
 					v1.ResourceName(v1.ResourceStorage): sizeResource,
@@ -253,6 +835,9 @@ func (s *fioStepper) createPVC(ctx context.Context, storageclass, size, namespac
 			},
 		},
 	}
+	if volumeMode != "" {
+		pvc.Spec.VolumeMode = &volumeMode
+	}
 	cm, err := s.cli.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{})
 	if err != nil {
 		return nil, err
@@ -264,36 +849,76 @@ func (s *fioStepper) deletePVC(ctx context.Context, pvcName, namespace string) e
 	return s.cli.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, pvcName, metav1.DeleteOptions{})
 }
 
-func (s *fioStepper) createPod(ctx context.Context, pvcName, configMapName, testFileName, namespace string, nodeSelector map[string]string, image string) (*v1.Pod, error) {
-	if pvcName == "" || configMapName == "" || testFileName == "" {
+func (s *fioStepper) createPod(ctx context.Context, pvcName, configMapName, testFileName string, readOnly bool, args *RunFIOArgs) (*v1.Pod, error) {
+	if pvcName == "" || configMapName == "" || testFileName == "" || args == nil {
 		return nil, fmt.Errorf("create pod missing required arguments")
 	}
+	pod, err := fioPod(pvcName, configMapName, readOnly, args)
+	if err != nil {
+		return nil, err
+	}
+	namespace := args.Namespace
+	podRes, err := s.cli.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return podRes, err
+	}
 
+	err = s.podReady.waitForPodReady(ctx, namespace, podRes.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	podRes, err = s.cli.CoreV1().Pods(namespace).Get(ctx, podRes.Name, metav1.GetOptions{})
+	if err != nil {
+		return podRes, err
+	}
+
+	return podRes, nil
+}
+
+// fioPod is the pod FIO runs in, mounting the PVC and the ConfigMap of the job file
+func fioPod(pvcName, configMapName string, readOnly bool, args *RunFIOArgs) (*v1.Pod, error) {
+	namespace := args.Namespace
+	image := args.Image
 	if image == "" {
 		image = common.DefaultPodImage
 	}
 
+	container := v1.Container{
+		Name:    ContainerName,
+		Command: []string{"/bin/sh"},
+		Args:    []string{"-c", "tail -f /dev/null"},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "persistent-storage", MountPath: VolumeMountPath, ReadOnly: readOnly},
+			{Name: "config-map", MountPath: ConfigMapMountPath},
+		},
+		Image: image,
+	}
+	if args.isBlock() {
+		container.VolumeMounts = []v1.VolumeMount{
+			{Name: "config-map", MountPath: ConfigMapMountPath},
+		}
+		container.VolumeDevices = []v1.VolumeDevice{
+			{Name: "persistent-storage", DevicePath: VolumeDevicePath},
+		}
+	}
+	if args.ClientServer {
+		serverContainer(&container)
+	}
+
 	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: PodGenerateName,
 			Namespace:    namespace,
+			Labels:       map[string]string{FIORunLabel: configMapName},
 		},
 		Spec: v1.PodSpec{
-			Containers: []v1.Container{{
-				Name:    ContainerName,
-				Command: []string{"/bin/sh"},
-				Args:    []string{"-c", "tail -f /dev/null"},
-				VolumeMounts: []v1.VolumeMount{
-					{Name: "persistent-storage", MountPath: VolumeMountPath},
-					{Name: "config-map", MountPath: ConfigMapMountPath},
-				},
-				Image: image,
-			}},
+			Containers: []v1.Container{container},
 			Volumes: []v1.Volume{
 				{
 					Name: "persistent-storage",
 					VolumeSource: v1.VolumeSource{
-						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName, ReadOnly: readOnly},
 					},
 				},
 				{
@@ -307,68 +932,108 @@ func (s *fioStepper) createPod(ctx context.Context, pvcName, configMapName, test
 					},
 				},
 			},
-			NodeSelector: nodeSelector,
+			NodeSelector: args.NodeSelector,
 		},
 	}
-	podRes, err := s.cli.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
-	if err != nil {
-		return podRes, err
+	if args.clientCount() > 1 {
+		pod.Spec.Affinity = clientAntiAffinity(configMapName)
 	}
+	addReferenceVolumes(pod, args)
+	return args.Pod.apply(pod)
+}
 
-	err = s.podReady.waitForPodReady(ctx, namespace, podRes.Name)
+func (s *fioStepper) deletePod(ctx context.Context, podName, namespace string) error {
+	return s.cli.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+}
+
+func (s *fioStepper) createScratchDir(ctx context.Context, podName, containerName, namespace, dir string) error {
+	command := []string{"mkdir", "-p", dir}
+	_, stderr, err := s.kubeExecutor.exec(ctx, namespace, podName, containerName, command)
 	if err != nil {
-		return nil, err
+		return errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr)
 	}
+	return nil
+}
 
-	podRes, err = s.cli.CoreV1().Pods(namespace).Get(ctx, podRes.Name, metav1.GetOptions{})
+func (s *fioStepper) deleteScratchDir(ctx context.Context, podName, containerName, namespace, dir string) error {
+	command := []string{"rm", "-rf", dir}
+	_, stderr, err := s.kubeExecutor.exec(ctx, namespace, podName, containerName, command)
 	if err != nil {
-		return podRes, err
+		return errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr)
 	}
-
-	return podRes, nil
+	return nil
 }
 
-func (s *fioStepper) deletePod(ctx context.Context, podName, namespace string) error {
-	return s.cli.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+// collectLogs reads every log file in dir, keyed by file name
+func (s *fioStepper) collectLogs(ctx context.Context, podName, containerName, namespace, dir string) (map[string]string, error) {
+	command := []string{"ls", "-1", dir}
+	stdout, stderr, err := s.kubeExecutor.exec(ctx, namespace, podName, containerName, command)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr)
+	}
+	files := map[string]string{}
+	for _, name := range strings.Fields(stdout) {
+		if !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		command = []string{"cat", fmt.Sprintf("%s/%s", dir, name)}
+		data, stderr, err := s.kubeExecutor.exec(ctx, namespace, podName, containerName, command)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr)
+		}
+		files[name] = data
+	}
+	return files, nil
 }
 
-func (s *fioStepper) runFIOCommand(ctx context.Context, podName, containerName, testFileName, namespace string) (FioResult, error) {
+// runFIOCommand streams the output of fio, with a status interval every intermediate result
+// is passed to the progress callback before the final one is returned
+func (s *fioStepper) runFIOCommand(ctx context.Context, podName, containerName, testFileName, namespace string, fioArgs []string, opts fioRunOptions) (FioResult, error) {
 	jobFilePath := fmt.Sprintf("%s/%s", ConfigMapMountPath, testFileName)
-	command := []string{"fio", "--directory", VolumeMountPath, jobFilePath, "--output-format=json"}
-	done := make(chan bool, 1)
+	command := opts.fioCommand(fioArgs, jobFilePath)
 	var fioOut FioResult
-	var stdout string
-	var stderr string
-	var err error
-	timestart := time.Now()
+	var stderr bytes.Buffer
+	pr, pw := io.Pipe()
+	decoded := make(chan error, 1)
 	go func() {
-		stdout, stderr, err = s.kubeExecutor.exec(ctx, namespace, podName, containerName, command)
-		if err != nil || stderr != "" {
-			if err == nil {
-				err = fmt.Errorf("stderr when running FIO")
-			}
-			err = errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr)
-		}
-		done <- true
+		var err error
+		fioOut, err = decodeFioStream(pr, opts.progress)
+		decoded <- err
 	}()
-	spin := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
-	spin.Start()
-	<-done
-	spin.Stop()
-	elapsed := time.Since(timestart)
-	fmt.Println("Elapsed time-", elapsed)
-	if err != nil {
-		return fioOut, err
+	err := s.kubeExecutor.execStream(ctx, namespace, podName, containerName, command, pw, &stderr)
+	_ = pw.Close()
+	decodeErr := <-decoded
+	if ctx.Err() != nil {
+		s.stopFIO(ctx, podName, containerName, namespace)
+		return fioOut, errors.Wrap(ctx.Err(), "FIO was interrupted")
 	}
-
-	err = json.Unmarshal([]byte(stdout), &fioOut)
-	if err != nil {
-		return fioOut, errors.Wrapf(err, "unable to parse fio output into JSON")
+	// fio fails when jobs do, e.g. when data doesn't verify, but still reports the results
+	if decodeErr == nil && len(fioOut.JobErrors()) > 0 {
+		return fioOut, nil
+	}
+	if err != nil || stderr.Len() != 0 {
+		if err == nil {
+			err = fmt.Errorf("stderr when running FIO")
+		}
+		return fioOut, errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr.String())
+	}
+	if decodeErr != nil {
+		return fioOut, errors.Wrapf(decodeErr, "unable to parse fio output into JSON")
 	}
 
 	return fioOut, nil
 }
 
+// stopFIO stops fio after the run was cancelled and waits for it to exit,
+// abandoning the exec doesn't stop the command in the pod
+func (s *fioStepper) stopFIO(ctx context.Context, podName, containerName, namespace string) {
+	fmt.Printf("Stopping FIO in pod (%s)\n", podName)
+	cleanup(ctx, func(ctx context.Context) error {
+		_, _, err := s.kubeExecutor.exec(ctx, namespace, podName, containerName, []string{"sh", "-c", stopFIOScript})
+		return err
+	})
+}
+
 // deleteConfigMap only deletes a config map if it has the label
 func (s *fioStepper) deleteConfigMap(ctx context.Context, configMap *v1.ConfigMap, namespace string) error {
 	if val, ok := configMap.Labels[CreatedByFIOLabel]; ok && val == "true" {
@@ -377,15 +1042,67 @@ func (s *fioStepper) deleteConfigMap(ctx context.Context, configMap *v1.ConfigMa
 	return nil
 }
 
-func fioTestFilename(configMap map[string]string) (string, error) {
-	if len(configMap) != 1 {
-		return "", fmt.Errorf("unable to find fio file in configmap/more than one found %v", configMap)
+func scratchDirPath() string {
+	return fmt.Sprintf("%s/%s", VolumeMountPath, ScratchDirName)
+}
+
+// fioJobWrites reports whether any job in the fio config issues writes or trims
+func fioJobWrites(config string) bool {
+	for _, line := range strings.Split(config, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key != "rw" && key != "readwrite" {
+			continue
+		}
+		mode, _, _ := strings.Cut(strings.TrimSpace(value), ":")
+		switch mode {
+		case "read", "randread":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// fioJobsWithoutFilename returns the jobs that leave fio to name and lay out their files,
+// which it can't do on a read-only volume
+func fioJobsWithoutFilename(config string) []string {
+	file, err := ParseFioJobFile(config)
+	if err != nil {
+		return nil
 	}
-	var fileName string
+	var names []string
+	for _, job := range file.Jobs() {
+		if _, ok := job.Option("filename"); !ok {
+			names = append(names, job.JobName())
+		}
+	}
+	return names
+}
+
+// cleanup runs fn with a context that is not cancelled along with ctx, so resources are
+// deleted after a timeout or an interrupt, but that has a deadline of its own
+func cleanup(ctx context.Context, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), CleanupTimeout)
+	defer cancel()
+	_ = fn(ctx)
+}
+
+// fioTestFilename returns the job file of the test, the precondition job file doesn't count
+func fioTestFilename(configMap map[string]string) (string, error) {
+	var fileNames []string
 	for key := range configMap {
-		fileName = key
+		if key != PreconditionFileName && key != MemoryReferenceFileName {
+			fileNames = append(fileNames, key)
+		}
 	}
-	return fileName, nil
+	if len(fileNames) != 1 {
+		return "", fmt.Errorf("unable to find fio file in configmap/more than one found %v", configMap)
+	}
+	return fileNames[0], nil
 }
 
 type waitForPodReadyInterface interface {
@@ -402,6 +1119,7 @@ func (p *podReadyChecker) waitForPodReady(ctx context.Context, namespace, name s
 
 type kubeExecInterface interface {
 	exec(ctx context.Context, namespace, podName, containerName string, command []string) (string, string, error)
+	execStream(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error
 }
 
 type kubeExecutor struct {
@@ -411,3 +1129,14 @@ type kubeExecutor struct {
 func (k *kubeExecutor) exec(ctx context.Context, namespace, podName, containerName string, command []string) (string, string, error) {
 	return kankube.Exec(ctx, k.cli, namespace, podName, containerName, command, nil)
 }
+
+func (k *kubeExecutor) execStream(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error {
+	return kankube.ExecWithOptions(ctx, k.cli, kankube.ExecOptions{
+		Command:       command,
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: containerName,
+		Stdout:        stdout,
+		Stderr:        stderr,
+	})
+}
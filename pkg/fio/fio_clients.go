@@ -0,0 +1,247 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SharedFileName is the name of the file used when clients share a single file
+	SharedFileName = "kubestr-fio-shared"
+	// ClientDirFmt is the format of the directory each client runs in
+	ClientDirFmt = "client-%d"
+)
+
+// fioClient is a pod that runs FIO against the volume
+type fioClient struct {
+	pod     *v1.Pod
+	fioArgs []string
+	dir     string // directory to create before running, if any
+}
+
+// FioClientResult is the result of a single client in a multi client run
+type FioClientResult struct {
+	Pod    string    `json:"pod,omitempty"`
+	Node   string    `json:"node,omitempty"`
+	Result FioResult `json:"result,omitempty"`
+}
+
+func (c FioClientResult) Print() string {
+	res := fmt.Sprintf("  %s (node %s)\n", c.Pod, c.Node)
+	for _, job := range c.Result.Jobs {
+		res += fmt.Sprintf("    %s: read IOPS=%f BW(KiB/s)=%d, write IOPS=%f BW(KiB/s)=%d\n",
+			job.JobOptions.Name, job.Read.Iops, job.Read.BW, job.Write.Iops, job.Write.BW)
+	}
+	return res
+}
+
+// runClients starts FIO on every client at the same time and waits for all of them to finish.
+// With a status interval, progress is reported through args.OnProgress one client at a time instead of a spinner.
+func (f *FIOrunner) runClients(ctx context.Context, clients []fioClient, testFileName string, args *RunFIOArgs) ([]FioClientResult, error) {
+	live := args.StatusInterval > 0 && args.OnProgress != nil
+	var progressMu sync.Mutex
+	results := make([]FioClientResult, len(clients))
+	errs := make([]error, len(clients))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		results[i] = FioClientResult{
+			Pod:  client.pod.Name,
+			Node: client.pod.Spec.NodeName,
+		}
+		opts := args.runOptions()
+		if live {
+			pod := ""
+			if len(clients) > 1 {
+				pod = client.pod.Name
+			}
+			opts.progress = func(r FioResult) {
+				progressMu.Lock()
+				defer progressMu.Unlock()
+				args.OnProgress(newFioProgress(pod, r))
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			results[i].Result, errs[i] = f.fioSteps.runFIOCommand(ctx, client.pod.Name, ContainerName, testFileName, args.Namespace, client.fioArgs, opts)
+		}()
+	}
+	timestart := time.Now()
+	spin := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
+	if !live {
+		spin.Start()
+	}
+	close(start)
+	wg.Wait()
+	if !live {
+		spin.Stop()
+	}
+	fmt.Println("Elapsed time-", time.Since(timestart))
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrapf(err, "client (%s) failed", results[i].Pod)
+		}
+	}
+	return results, nil
+}
+
+// clientFioArgs returns the fio arguments that point the given client at the volume,
+// and the directory that has to exist before it runs
+func clientFioArgs(args *RunFIOArgs, client int, readOnly bool) ([]string, string) {
+	switch {
+	case args.isBlock() && readOnly:
+		return []string{"--filename", VolumeDevicePath, "--readonly"}, ""
+	case args.isBlock():
+		return []string{"--filename", VolumeDevicePath}, ""
+	case readOnly:
+		// read-only jobs read files that already exist on the volume
+		return []string{"--directory", VolumeMountPath, "--readonly"}, ""
+	}
+	base := VolumeMountPath
+	if args.PVC != "" {
+		base = scratchDirPath()
+	}
+	switch {
+	case args.clientCount() == 1 && args.PVC == "":
+		return []string{"--directory", base}, ""
+	case args.clientCount() == 1:
+		return []string{"--directory", base}, base
+	case args.SharedFile:
+		return []string{"--filename", fmt.Sprintf("%s/%s", base, SharedFileName)}, base
+	}
+	dir := fmt.Sprintf("%s/"+ClientDirFmt, base, client)
+	return []string{"--directory", dir}, dir
+}
+
+// pvcSharable reports whether an existing PVC can be mounted by more than one pod
+func pvcSharable(pvc *v1.PersistentVolumeClaim, readOnly bool) bool {
+	for _, mode := range pvc.Spec.AccessModes {
+		if mode == v1.ReadWriteMany || (readOnly && mode == v1.ReadOnlyMany) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAntiAffinity spreads the pods of a run across nodes where possible
+func clientAntiAffinity(runName string) *v1.Affinity {
+	return &v1.Affinity{
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: v1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{FIORunLabel: runName},
+					},
+					TopologyKey: v1.LabelHostname,
+				},
+			}},
+		},
+	}
+}
+
+// aggregateFioResults sums the throughput of every client per job.
+// Latencies are averaged, weighted by the number of samples. Percentiles are
+// recomputed when histogram bins are available, otherwise the highest is kept.
+// The first error of a job across clients is kept, steady state needs to be reached by all.
+func aggregateFioResults(clients []FioClientResult) FioResult {
+	agg := clients[0].Result
+	agg.Jobs = append([]FioJobs(nil), agg.Jobs...)
+	agg.DiskUtil = nil
+	for _, client := range clients[1:] {
+		for i := range agg.Jobs {
+			if i >= len(client.Result.Jobs) {
+				break
+			}
+			job := client.Result.Jobs[i]
+			agg.Jobs[i].Read = aggregateFioStats(agg.Jobs[i].Read, job.Read)
+			agg.Jobs[i].Write = aggregateFioStats(agg.Jobs[i].Write, job.Write)
+			agg.Jobs[i].Trim = aggregateFioStats(agg.Jobs[i].Trim, job.Trim)
+			agg.Jobs[i].Sync = aggregateFioStats(agg.Jobs[i].Sync, job.Sync)
+			// a job failing on any client fails the aggregated job
+			if agg.Jobs[i].Error == 0 {
+				agg.Jobs[i].Error = job.Error
+			}
+			// steady state is only reached when every client reached it
+			if ss := agg.Jobs[i].SteadyState; ss != nil && job.SteadyState != nil && job.SteadyState.Attained == 0 {
+				notAttained := *ss
+				notAttained.Attained = 0
+				agg.Jobs[i].SteadyState = &notAttained
+			}
+		}
+	}
+	return agg
+}
+
+func aggregateFioStats(a, b FioStats) FioStats {
+	a.IOBytes += b.IOBytes
+	a.IOKBytes += b.IOKBytes
+	a.BWBytes += b.BWBytes
+	a.BW += b.BW
+	a.Iops += b.Iops
+	a.TotalIos += b.TotalIos
+	a.ShortIos += b.ShortIos
+	a.DropIos += b.DropIos
+	a.BwMin += b.BwMin
+	a.BwMax += b.BwMax
+	a.BwMean += b.BwMean
+	a.IopsMin += b.IopsMin
+	a.IopsMax += b.IopsMax
+	a.IopsMean += b.IopsMean
+	a.SlatNs = aggregateFioNS(a.SlatNs, b.SlatNs)
+	a.ClatNs = aggregateFioNS(a.ClatNs, b.ClatNs)
+	a.LatNs = aggregateFioNS(a.LatNs, b.LatNs)
+	return a
+}
+
+func aggregateFioNS(a, b FioNS) FioNS {
+	if b.N == 0 {
+		return a
+	}
+	if a.N == 0 {
+		return b
+	}
+	res := FioNS{
+		Min:  min(a.Min, b.Min),
+		Max:  max(a.Max, b.Max),
+		Mean: (a.Mean*float64(a.N) + b.Mean*float64(b.N)) / float64(a.N+b.N),
+		N:    a.N + b.N,
+	}
+	// percentiles can't be combined, but they can be recomputed from the histogram bins
+	if len(a.Bins) > 0 || len(b.Bins) > 0 {
+		res.Bins = map[string]int64{}
+		for _, bins := range []map[string]int64{a.Bins, b.Bins} {
+			for key, count := range bins {
+				res.Bins[key] += count
+			}
+		}
+		res.Percentile = map[string]float64{}
+		for key := range a.Percentile {
+			if p, err := strconv.ParseFloat(key, 64); err == nil {
+				res.Percentile[key], _ = res.binsPercentile(p)
+			}
+		}
+		return res
+	}
+	// without bins the highest percentile is kept, the combined percentile is no higher than that
+	if len(a.Percentile) > 0 || len(b.Percentile) > 0 {
+		res.Percentile = map[string]float64{}
+		for _, percentiles := range []map[string]float64{a.Percentile, b.Percentile} {
+			for key, value := range percentiles {
+				res.Percentile[key] = max(res.Percentile[key], value)
+			}
+		}
+		res.ApproxPercentile = true
+	}
+	return res
+}
@@ -0,0 +1,112 @@
+package fio
+
+import (
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func (s *FIOTestSuite) TestClientFioArgs(c *C) {
+	for _, tc := range []struct {
+		args     *RunFIOArgs
+		client   int
+		readOnly bool
+		fioArgs  []string
+		dir      string
+	}{
+		{
+			args:    &RunFIOArgs{},
+			fioArgs: []string{"--directory", VolumeMountPath},
+		},
+		{
+			args:    &RunFIOArgs{VolumeMode: v1.PersistentVolumeBlock, Clients: 3},
+			client:  2,
+			fioArgs: []string{"--filename", VolumeDevicePath},
+		},
+		{
+			args:     &RunFIOArgs{VolumeMode: v1.PersistentVolumeBlock, PVC: "pvc"},
+			readOnly: true,
+			fioArgs:  []string{"--filename", VolumeDevicePath, "--readonly"},
+		},
+		{
+			args:     &RunFIOArgs{PVC: "pvc", Clients: 2},
+			readOnly: true,
+			fioArgs:  []string{"--directory", VolumeMountPath, "--readonly"},
+		},
+		{
+			args:    &RunFIOArgs{PVC: "pvc"},
+			fioArgs: []string{"--directory", VolumeMountPath + "/" + ScratchDirName},
+			dir:     VolumeMountPath + "/" + ScratchDirName,
+		},
+		{
+			args:    &RunFIOArgs{Clients: 2},
+			client:  1,
+			fioArgs: []string{"--directory", VolumeMountPath + "/client-1"},
+			dir:     VolumeMountPath + "/client-1",
+		},
+		{
+			args:    &RunFIOArgs{PVC: "pvc", Clients: 2},
+			client:  1,
+			fioArgs: []string{"--directory", VolumeMountPath + "/" + ScratchDirName + "/client-1"},
+			dir:     VolumeMountPath + "/" + ScratchDirName + "/client-1",
+		},
+		{
+			args:    &RunFIOArgs{Clients: 2, SharedFile: true},
+			client:  1,
+			fioArgs: []string{"--filename", VolumeMountPath + "/" + SharedFileName},
+			dir:     VolumeMountPath,
+		},
+	} {
+		fioArgs, dir := clientFioArgs(tc.args, tc.client, tc.readOnly)
+		c.Check(fioArgs, DeepEquals, tc.fioArgs)
+		c.Check(dir, Equals, tc.dir)
+	}
+}
+
+func (s *FIOTestSuite) TestPVCSharable(c *C) {
+	pvc := func(modes ...v1.PersistentVolumeAccessMode) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{Spec: v1.PersistentVolumeClaimSpec{AccessModes: modes}}
+	}
+	c.Check(pvcSharable(pvc(v1.ReadWriteOnce), false), Equals, false)
+	c.Check(pvcSharable(pvc(v1.ReadWriteOnce, v1.ReadWriteMany), false), Equals, true)
+	c.Check(pvcSharable(pvc(v1.ReadOnlyMany), false), Equals, false)
+	c.Check(pvcSharable(pvc(v1.ReadOnlyMany), true), Equals, true)
+}
+
+func (s *FIOTestSuite) TestAggregateFioResults(c *C) {
+	clients := []FioClientResult{
+		{Pod: "a", Result: FioResult{Jobs: []FioJobs{{
+			JobName: "job",
+			Read:    FioStats{Iops: 100, BW: 400, ClatNs: FioNS{Min: 10, Max: 100, Mean: 50, N: 10}},
+		}}}},
+		{Pod: "b", Result: FioResult{Jobs: []FioJobs{{
+			JobName: "job",
+			Read:    FioStats{Iops: 300, BW: 1200, ClatNs: FioNS{Min: 5, Max: 200, Mean: 150, N: 30}},
+		}}}},
+	}
+	agg := aggregateFioResults(clients)
+	c.Assert(agg.Jobs, HasLen, 1)
+	c.Check(agg.Jobs[0].Read.Iops, Equals, float64(400))
+	c.Check(agg.Jobs[0].Read.BW, Equals, int64(1600))
+	c.Check(agg.Jobs[0].Read.ClatNs, DeepEquals, FioNS{Min: 5, Max: 200, Mean: 125, N: 40})
+	// the first client is left untouched
+	c.Check(clients[0].Result.Jobs[0].Read.Iops, Equals, float64(100))
+
+	res := RunFIOResult{Result: agg, Clients: clients}
+	c.Check(res.Print(), Matches, "(?s).*Aggregated across 2 clients.*a \\(node \\).*b \\(node \\).*")
+}
+
+func (s *FIOTestSuite) TestAggregateFioNSBins(c *C) {
+	a := FioNS{N: 10, Mean: 100, Percentile: map[string]float64{"50.000000": 100, "99.000000": 200}, Bins: map[string]int64{"100": 9, "200": 1}}
+	b := FioNS{N: 10, Mean: 300, Percentile: map[string]float64{"50.000000": 300, "99.000000": 300}, Bins: map[string]int64{"300": 10}}
+	agg := aggregateFioNS(a, b)
+	c.Check(agg.Bins, DeepEquals, map[string]int64{"100": 9, "200": 1, "300": 10})
+	c.Check(agg.Percentile, DeepEquals, map[string]float64{"50.000000": 200, "99.000000": 300})
+
+	c.Check(agg.ApproxPercentile, Equals, false)
+
+	// without bins the highest percentiles are kept as an approximation
+	a.Bins, b.Bins = nil, nil
+	agg = aggregateFioNS(a, b)
+	c.Check(agg.Percentile, DeepEquals, map[string]float64{"50.000000": 300, "99.000000": 300})
+	c.Check(agg.ApproxPercentile, Equals, true)
+}
@@ -0,0 +1,172 @@
+package fio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// FIOComparisonResult is the result of running a FIO job against one of the compared StorageClasses
+type FIOComparisonResult struct {
+	StorageClass string        `json:"storageClass"`
+	Result       *RunFIOResult `json:"result,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// FIOComparison holds the results of running the same FIO job against several StorageClasses
+type FIOComparison []FIOComparisonResult
+
+// ListStorageClasses returns the names of every StorageClass in the cluster
+func ListStorageClasses(ctx context.Context, cli kubernetes.Interface) ([]string, error) {
+	scs, err := cli.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list StorageClasses")
+	}
+	var names []string
+	for _, sc := range scs.Items {
+		names = append(names, sc.Name)
+	}
+	return names, nil
+}
+
+// RunFioComparison runs the same FIO job against each StorageClass, one after the other or all at once
+func (f *FIOrunner) RunFioComparison(ctx context.Context, args *RunFIOArgs, storageClasses []string, concurrent bool) (FIOComparison, error) {
+	f.fioSteps = newFioStepper(f.Cli)
+	return f.RunFioComparisonHelper(ctx, args, storageClasses, concurrent)
+}
+
+func (f *FIOrunner) RunFioComparisonHelper(ctx context.Context, args *RunFIOArgs, storageClasses []string, concurrent bool) (FIOComparison, error) {
+	if len(storageClasses) == 0 {
+		return nil, fmt.Errorf("no StorageClasses to compare")
+	}
+	if args.PVC != "" {
+		return nil, fmt.Errorf("an existing PVC can't be compared across StorageClasses")
+	}
+	comparison := make(FIOComparison, len(storageClasses))
+	runEach(len(storageClasses), concurrent, func(i int) {
+		classArgs := *args
+		classArgs.StorageClass = storageClasses[i]
+		comparison[i].StorageClass = storageClasses[i]
+		if !concurrent {
+			fmt.Printf("Comparing StorageClass (%s) (%d/%d)\n", storageClasses[i], i+1, len(storageClasses))
+		}
+		result, err := f.RunFioHelper(ctx, &classArgs)
+		if err != nil {
+			comparison[i].Error = err.Error()
+			return
+		}
+		comparison[i].Result = result
+	})
+	return comparison, nil
+}
+
+// runEach calls fn for every index in [0, n), concurrently if requested
+func runEach(n int, concurrent bool, fn func(i int)) {
+	if !concurrent {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// Failed reports whether the job could not be run against any of the StorageClasses
+func (c FIOComparison) Failed() bool {
+	for _, r := range c {
+		if r.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Print renders a table per job and direction, relative to the first StorageClass that succeeded
+func (c FIOComparison) Print() string {
+	var baseline *RunFIOResult
+	for _, r := range c {
+		if r.Result != nil {
+			baseline = r.Result
+			break
+		}
+	}
+	var buf bytes.Buffer
+	for _, r := range c {
+		if r.Error != "" {
+			fmt.Fprintf(&buf, "%s: failed: %s\n", r.StorageClass, r.Error)
+		}
+	}
+	if baseline == nil {
+		return buf.String()
+	}
+	for _, job := range baseline.Result.Grouped().Jobs {
+		for _, direction := range fioDirections {
+			base := job.stats(direction)
+			if !base.active() || direction == "sync" {
+				continue
+			}
+			fmt.Fprintf(&buf, "\n%s %s:\n", job.name(), direction)
+			w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  StorageClass\tIOPS\tBW(KiB/s)\tclat avg(usec)\t")
+			for _, r := range c {
+				if r.Result == nil {
+					continue
+				}
+				stats, found := comparedStats(r.Result.Result, job.name(), direction)
+				if !found {
+					fmt.Fprintf(w, "  %s\t-\t-\t-\t\n", r.StorageClass)
+					continue
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t\n", r.StorageClass,
+					compareValue(stats.Iops, base.Iops, "%.2f"),
+					compareValue(float64(stats.BW), float64(base.BW), "%.0f"),
+					compareLatency(stats.latency(), base.latency()))
+			}
+			_ = w.Flush()
+		}
+	}
+	return buf.String()
+}
+
+// comparedStats finds the stats of the named job in a result, clones of the job are merged
+func comparedStats(result FioResult, jobName, direction string) (FioStats, bool) {
+	for _, job := range result.Grouped().Jobs {
+		if job.name() == jobName {
+			return job.stats(direction), true
+		}
+	}
+	return FioStats{}, false
+}
+
+// compareValue formats a value with its difference to the baseline in percent
+func compareValue(value, base float64, format string) string {
+	res := fmt.Sprintf(format, value)
+	if base == 0 {
+		return res
+	}
+	return fmt.Sprintf("%s (%+.1f%%)", res, 100*(value-base)/base)
+}
+
+func compareLatency(lat, base FioNS) string {
+	if lat.N == 0 {
+		return "-"
+	}
+	if base.N == 0 {
+		return fmt.Sprintf("%.2f", nsToUsec(lat.Mean))
+	}
+	return compareValue(nsToUsec(lat.Mean), nsToUsec(base.Mean), "%.2f")
+}
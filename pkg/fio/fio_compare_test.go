@@ -0,0 +1,94 @@
+package fio
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func (s *FIOTestSuite) TestListStorageClasses(c *C) {
+	ctx := context.Background()
+	cli := fake.NewSimpleClientset(
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "gp3"}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "io2"}},
+	)
+	names, err := ListStorageClasses(ctx, cli)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"gp3", "io2"})
+}
+
+func (s *FIOTestSuite) TestRunFioComparisonHelper(c *C) {
+	ctx := context.Background()
+	stepper := &fakeFioStepper{
+		lcmConfigMap: &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "CM1"},
+			Data:       map[string]string{"testfile.fio": "testfiledata"},
+		},
+		cPVC: &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "PVC"}},
+		cPod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "Pod"}},
+	}
+	runner := &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	args := &RunFIOArgs{Size: "10Gi", Namespace: "foo"}
+	comparison, err := runner.RunFioComparisonHelper(ctx, args, []string{"gp3", "io2"}, false)
+	c.Assert(err, IsNil)
+	c.Assert(comparison, HasLen, 2)
+	c.Check(comparison[0].StorageClass, Equals, "gp3")
+	c.Check(comparison[1].StorageClass, Equals, "io2")
+	c.Check(comparison[1].Result, NotNil)
+	c.Check(comparison.Failed(), Equals, false)
+	// the last class ran last, and the arguments were not modified
+	c.Check(stepper.cPVCExpSC, Equals, "io2")
+	c.Check(args.StorageClass, Equals, "")
+
+	stepper.cPVCErr = context.DeadlineExceeded
+	comparison, err = runner.RunFioComparisonHelper(ctx, args, []string{"gp3"}, false)
+	c.Assert(err, IsNil)
+	c.Check(comparison.Failed(), Equals, true)
+	c.Check(comparison[0].Result, IsNil)
+
+	_, err = runner.RunFioComparisonHelper(ctx, args, nil, false)
+	c.Check(err, NotNil)
+	_, err = runner.RunFioComparisonHelper(ctx, &RunFIOArgs{PVC: "pvc", Namespace: "foo"}, []string{"gp3"}, false)
+	c.Check(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestRunEach(c *C) {
+	for _, concurrent := range []bool{false, true} {
+		var calls int32
+		seen := make([]bool, 5)
+		runEach(len(seen), concurrent, func(i int) {
+			atomic.AddInt32(&calls, 1)
+			seen[i] = true
+		})
+		c.Check(calls, Equals, int32(5))
+		c.Check(seen, DeepEquals, []bool{true, true, true, true, true})
+	}
+}
+
+func (s *FIOTestSuite) TestFIOComparisonPrint(c *C) {
+	result := func(iops float64, bw int64, lat float64) *RunFIOResult {
+		return &RunFIOResult{Result: FioResult{Jobs: []FioJobs{{
+			JobOptions: FioJobOptions{Name: "read_iops"},
+			Read:       FioStats{Iops: iops, BW: bw, ClatNs: FioNS{Mean: lat, N: 10}},
+		}}}}
+	}
+	comparison := FIOComparison{
+		{StorageClass: "gp3", Result: result(1000, 4000, 2000)},
+		{StorageClass: "io2", Result: result(1500, 6000, 1000)},
+		{StorageClass: "ceph", Error: "failed to create PVC"},
+	}
+	out := comparison.Print()
+	c.Check(strings.Contains(out, "ceph: failed: failed to create PVC"), Equals, true)
+	c.Check(strings.Contains(out, "read_iops read:"), Equals, true)
+	c.Check(out, Matches, "(?s).*gp3 +1000.00 \\(\\+0.0%\\) +4000 \\(\\+0.0%\\) +2.00 \\(\\+0.0%\\).*")
+	c.Check(out, Matches, "(?s).*io2 +1500.00 \\(\\+50.0%\\) +6000 \\(\\+50.0%\\) +1.00 \\(-50.0%\\).*")
+	c.Check(strings.Contains(out, "write"), Equals, false)
+
+	c.Check(FIOComparison{{StorageClass: "gp3", Error: "boom"}}.Print(), Equals, "gp3: failed: boom\n")
+}
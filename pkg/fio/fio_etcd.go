@@ -0,0 +1,58 @@
+package fio
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// EtcdFIOJob is the predefined test that mimics the etcd write ahead log
+	EtcdFIOJob = "etcd"
+	// EtcdSyncP99Limit is the 99th percentile fdatasync latency etcd recommends staying under
+	EtcdSyncP99Limit = 10 * time.Millisecond
+	// etcdWarnFraction is the share of the limit above which the storage is considered marginal
+	etcdWarnFraction = 0.8
+)
+
+// EtcdCheckResult is the verdict on whether a StorageClass is fast enough for etcd
+type EtcdCheckResult struct {
+	SyncP99 time.Duration   `json:"syncP99,omitempty"`
+	Limit   time.Duration   `json:"limit"`
+	Status  ThresholdStatus `json:"status"`
+	Message string          `json:"message"`
+	FIO     *RunFIOResult   `json:"fio,omitempty"`
+}
+
+// EtcdThresholds are the thresholds the etcd check evaluates
+func EtcdThresholds() []Threshold {
+	return []Threshold{{
+		Metric:   ThresholdLatP99,
+		Selector: "sync",
+		Max:      EtcdSyncP99Limit.String(),
+	}}
+}
+
+// EvaluateEtcd turns the sync latencies of an etcd FIO run into a pass, warn or fail verdict.
+// Storage is marginal when the p99 passes but is above 80% of the limit.
+func EvaluateEtcd(result *RunFIOResult) EtcdCheckResult {
+	check := EtcdCheckResult{
+		Limit: EtcdSyncP99Limit,
+		FIO:   result,
+	}
+	// a single threshold always produces at least one result
+	t := EvaluateThresholds(result.Result, EtcdThresholds())[0]
+	check.Status = t.Status
+	check.SyncP99 = time.Duration(t.Value)
+	switch {
+	case t.Status == ThresholdWarning:
+		check.Message = fmt.Sprintf("Unable to evaluate fdatasync latency: %s", t.Message)
+	case t.Status == ThresholdError:
+		check.Message = fmt.Sprintf("fdatasync p99 latency %s exceeds the %s etcd recommends", check.SyncP99, EtcdSyncP99Limit)
+	case t.Value > etcdWarnFraction*float64(EtcdSyncP99Limit):
+		check.Status = ThresholdWarning
+		check.Message = fmt.Sprintf("fdatasync p99 latency %s is close to the %s etcd recommends", check.SyncP99, EtcdSyncP99Limit)
+	default:
+		check.Message = fmt.Sprintf("fdatasync p99 latency %s is within the %s etcd recommends", check.SyncP99, EtcdSyncP99Limit)
+	}
+	return check
+}
@@ -0,0 +1,70 @@
+package fio
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FIOTestSuite) TestEvaluateEtcd(c *C) {
+	result := func(p99 float64) *RunFIOResult {
+		return &RunFIOResult{Result: FioResult{Jobs: []FioJobs{{
+			JobOptions: FioJobOptions{Name: "etcd_wal"},
+			Write:      FioStats{Iops: 500, TotalIos: 9000},
+			Sync: FioStats{TotalIos: 9000, LatNs: FioNS{N: 9000, Percentile: map[string]float64{
+				"99.000000": p99,
+			}}},
+		}}}}
+	}
+	for _, tc := range []struct {
+		result  *RunFIOResult
+		status  ThresholdStatus
+		p99     time.Duration
+		message string
+	}{
+		{
+			result:  result(float64(2 * time.Millisecond)),
+			status:  ThresholdOK,
+			p99:     2 * time.Millisecond,
+			message: "fdatasync p99 latency 2ms is within the 10ms etcd recommends",
+		},
+		{
+			result:  result(float64(9 * time.Millisecond)),
+			status:  ThresholdWarning,
+			p99:     9 * time.Millisecond,
+			message: "fdatasync p99 latency 9ms is close to the 10ms etcd recommends",
+		},
+		{
+			result:  result(float64(25 * time.Millisecond)),
+			status:  ThresholdError,
+			p99:     25 * time.Millisecond,
+			message: "fdatasync p99 latency 25ms exceeds the 10ms etcd recommends",
+		},
+		{ // fdatasync not set in the job
+			result: &RunFIOResult{Result: FioResult{Jobs: []FioJobs{{
+				Write: FioStats{Iops: 500, TotalIos: 9000},
+			}}}},
+			status:  ThresholdWarning,
+			message: "Unable to evaluate fdatasync latency: lat_p99 (max 10ms): no job matched selector (sync)",
+		},
+	} {
+		check := EvaluateEtcd(tc.result)
+		c.Check(check.Status, Equals, tc.status)
+		c.Check(check.SyncP99, Equals, tc.p99)
+		c.Check(check.Message, Equals, tc.message)
+		c.Check(check.Limit, Equals, EtcdSyncP99Limit)
+	}
+}
+
+func (s *FIOTestSuite) TestEtcdJob(c *C) {
+	job, ok := fioJobs[EtcdFIOJob]
+	c.Assert(ok, Equals, true)
+	file, err := ParseFioJobFile(job.config)
+	c.Assert(err, IsNil)
+	jobs := file.Jobs()
+	c.Assert(jobs, HasLen, 1)
+	fdatasync, ok := jobs[0].Option("fdatasync")
+	c.Check(ok, Equals, true)
+	c.Check(fdatasync.Value, Equals, "1")
+	c.Check(LintFioJobFile(job.config, &RunFIOArgs{Size: "1Gi", Thresholds: EtcdThresholds()}), HasLen, 0)
+}
@@ -0,0 +1,349 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	sv1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// JobGenerateName is the name to generate for the Job FIO runs in
+	JobGenerateName = "kubestr-fio-job-"
+	// FioRunAnnotation records on the Job what fio attach needs to finish the run
+	FioRunAnnotation = "kubestr.io/fio-run"
+	// JobResultFileName is the file the Job writes the fio output to
+	JobResultFileName = "kubestr-fio-result.json"
+	// JobPollInterval is how often the status of the Job is checked
+	JobPollInterval = 5 * time.Second
+	// jobErrFile collects the stderr of fio in the Job
+	jobErrFile = "/tmp/kubestr-fio.err"
+	// jobNameLabel is the label the Job controller puts on its pods
+	jobNameLabel = "job-name"
+	// jobOutputMarker is printed before the output of fio, logs without it lost their start
+	jobOutputMarker = "kubestr-fio-output"
+	// ResultReaderGenerateName is the name to generate for the pod reading the result of a Job from its volume
+	ResultReaderGenerateName = "kubestr-fio-result-"
+)
+
+// fioRun is stored on the Job so that a run can be finished by whoever attaches to it
+type fioRun struct {
+	TestFile     string      `json:"testFile"`
+	FioConfig    string      `json:"fioConfig,omitempty"`
+	ConfigMap    string      `json:"configMap"`
+	PVC          string      `json:"pvc"`
+	CreatedPVC   bool        `json:"createdPVC,omitempty"`
+	Size         string      `json:"size,omitempty"`
+	StorageClass string      `json:"storageClass,omitempty"`
+	Variant      bool        `json:"variant,omitempty"`    // the StorageClass is a variant to delete with the run
+	ResultPath   string      `json:"resultPath,omitempty"` // where the output of fio stays on the volume
+	Thresholds   []Threshold `json:"thresholds,omitempty"`
+}
+
+// jobFioRun reads the run recorded on a Job
+func jobFioRun(job *batchv1.Job) (fioRun, error) {
+	var run fioRun
+	data, ok := job.Annotations[FioRunAnnotation]
+	if job.Labels[CreatedByFIOLabel] != "true" || !ok {
+		return run, fmt.Errorf("job (%s) was not created by kubestr fio", job.Name)
+	}
+	if err := json.Unmarshal([]byte(data), &run); err != nil {
+		return run, errors.Wrapf(err, "unable to read the run of Job (%s)", job.Name)
+	}
+	return run, nil
+}
+
+// jobScript is the shell script of the Job. It prints the fio output once fio exits,
+// the output also stays in the result file, on the volume if it's writable.
+func jobScript(args *RunFIOArgs, testFileName string, precondition, readOnly bool) string {
+	fioArgs, dir := clientFioArgs(args, 0, readOnly)
+	resultPath, _ := jobResultPath(args)
+	opts := args.runOptions()
+	opts.statusInterval = 0
+	var steps []string
+	if dir != "" {
+		steps = append(steps, "mkdir -p "+shellQuote(dir))
+	}
+	if precondition {
+		command := opts.fioCommand(fioArgs, fmt.Sprintf("%s/%s", ConfigMapMountPath, PreconditionFileName))
+		steps = append(steps, fmt.Sprintf("%s --output=/dev/null 2>%s", shellJoin(command), jobErrFile))
+	}
+	command := opts.fioCommand(fioArgs, fmt.Sprintf("%s/%s", ConfigMapMountPath, testFileName))
+	steps = append(steps, fmt.Sprintf("%s --output=%s 2>>%s", shellJoin(command), shellQuote(resultPath), jobErrFile))
+	script := strings.Join(steps, " && ") + "; rc=$?"
+	// fio exits non zero when jobs fail, e.g. when data doesn't verify, but still writes its results
+	script += fmt.Sprintf("; echo %[3]s; if [ -s %[1]s ]; then cat %[1]s; else cat %[2]s; fi", shellQuote(resultPath), jobErrFile, jobOutputMarker)
+	if dir != "" && args.PVC != "" {
+		script += "; rm -rf " + shellQuote(dir)
+	}
+	return script + "; exit $rc"
+}
+
+// jobResultPath is where the Job writes the output of fio, on the volume when kubestr created
+// it with a filesystem, otherwise in the pod
+func jobResultPath(args *RunFIOArgs) (string, bool) {
+	if args.PVC == "" && !args.isBlock() {
+		return fmt.Sprintf("%s/%s", VolumeMountPath, JobResultFileName), true
+	}
+	return "/tmp/" + JobResultFileName, false
+}
+
+// jobLogsOutput returns the output of fio the Job printed. The kubelet rotates the logs of a
+// container, the start of a large output can be lost.
+func jobLogsOutput(name, logs string) (string, error) {
+	_, output, found := strings.Cut(logs, jobOutputMarker+"\n")
+	if !found {
+		return "", fmt.Errorf("the logs of Job (%s) are incomplete, the start of the fio output was rotated away", name)
+	}
+	return output, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, shellQuote(w))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// fioBatchJob is the Job running the FIO test to completion in a single pod
+func fioBatchJob(pvcName, configMapName, testFileName string, precondition, readOnly bool, run fioRun, args *RunFIOArgs) (*batchv1.Job, error) {
+	pod, err := fioPod(pvcName, configMapName, readOnly, args)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == ContainerName {
+			pod.Spec.Containers[i].Command = []string{"/bin/sh", "-c", jobScript(args, testFileName, precondition, readOnly)}
+			pod.Spec.Containers[i].Args = nil
+		}
+	}
+	pod.Spec.RestartPolicy = v1.RestartPolicyNever
+	data, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	backoffLimit := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: JobGenerateName,
+			Namespace:    args.Namespace,
+			Labels:       map[string]string{CreatedByFIOLabel: "true", FIORunLabel: configMapName},
+			Annotations:  map[string]string{FioRunAnnotation: string(data)},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: pod.Labels, Annotations: pod.Annotations},
+				Spec:       pod.Spec,
+			},
+		},
+	}, nil
+}
+
+// AttachFio waits for a FIO test running as a Job to complete, reports its result and cleans up
+func (f *FIOrunner) AttachFio(ctx context.Context, namespace, runID string) (*RunFIOResult, error) {
+	f.fioSteps = newFioStepper(f.Cli)
+	return f.AttachFioHelper(ctx, namespace, runID)
+}
+
+func (f *FIOrunner) AttachFioHelper(ctx context.Context, namespace, runID string) (*RunFIOResult, error) {
+	job, err := f.fioSteps.getJob(ctx, runID, namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find Job (%s)", runID)
+	}
+	return f.finishJob(ctx, job)
+}
+
+// finishJob waits for the Job, parses the fio output it printed and deletes the resources of the run
+func (f *FIOrunner) finishJob(ctx context.Context, job *batchv1.Job) (*RunFIOResult, error) {
+	run, err := jobFioRun(job)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Waiting for Job (%s) to complete\n", job.Name)
+	output, succeeded, err := f.fioSteps.waitForJob(ctx, job.Name, job.Namespace)
+	if ctx.Err() != nil {
+		return nil, errors.Wrapf(ctx.Err(), "detached from Job (%s), it keeps running, re-attach with: kubestr fio attach %s -n %s", job.Name, job.Name, job.Namespace)
+	}
+	// the Job may still be running, its resources are only deleted once it finished
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed while waiting for Job (%s), its resources are kept, re-attach with: kubestr fio attach %s -n %s", job.Name, job.Name, job.Namespace)
+	}
+	// the output on the volume is complete, the logs may not be
+	fromVolume := ""
+	if run.ResultPath != "" {
+		if result, readErr := f.fioSteps.readJobResult(ctx, job, run.ResultPath); readErr == nil {
+			fromVolume = result
+		} else {
+			fmt.Printf("Failed to read the result of Job (%s) from its volume, reading its logs: %s\n", job.Name, readErr.Error())
+		}
+	}
+	if fromVolume != "" {
+		output = fromVolume
+	} else if output, err = jobLogsOutput(job.Name, output); err != nil {
+		return nil, errors.Wrapf(err, "its resources are kept, re-attach with: kubestr fio attach %s -n %s", job.Name, job.Namespace)
+	}
+	defer cleanup(ctx, func(ctx context.Context) error {
+		return f.deleteJobResources(ctx, job, run)
+	})
+	fioOut, decodeErr := decodeFioStream(strings.NewReader(output), nil)
+	if decodeErr != nil || (!succeeded && len(fioOut.JobErrors()) == 0) {
+		return nil, fmt.Errorf("job (%s) failed, output:(%s)", job.Name, output)
+	}
+	var sc *sv1.StorageClass
+	if run.StorageClass != "" {
+		if storageClass, err := f.fioSteps.storageClassExists(ctx, run.StorageClass); err == nil {
+			sc = storageClass
+		}
+	}
+	result := &RunFIOResult{
+		Size:         run.Size,
+		PVC:          run.PVC,
+		StorageClass: sc,
+		TestFile:     run.TestFile,
+		FioConfig:    run.FioConfig,
+		Job:          job.Name,
+		Result:       fioOut,
+	}
+	if len(run.Thresholds) > 0 {
+		result.Thresholds = EvaluateThresholds(result.Result, run.Thresholds)
+	}
+	return result, nil
+}
+
+// deleteJobResources deletes the Job and the ConfigMap, PVC and StorageClass variant kubestr created for it
+func (f *FIOrunner) deleteJobResources(ctx context.Context, job *batchv1.Job, run fioRun) error {
+	var errs []string
+	if err := f.fioSteps.deleteJob(ctx, job); err != nil {
+		errs = append(errs, err.Error())
+	}
+	configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: run.ConfigMap, Labels: map[string]string{CreatedByFIOLabel: "true"}}}
+	if err := f.fioSteps.deleteConfigMap(ctx, configMap, job.Namespace); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if run.CreatedPVC {
+		if err := f.fioSteps.deletePVC(ctx, run.PVC, job.Namespace); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if run.Variant {
+		sc, err := f.fioSteps.storageClassExists(ctx, run.StorageClass)
+		if err == nil {
+			err = f.fioSteps.deleteStorageClass(ctx, sc)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to delete the resources of Job (%s): %s", job.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (s *fioStepper) createJob(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error) {
+	return s.cli.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{})
+}
+
+func (s *fioStepper) getJob(ctx context.Context, name, namespace string) (*batchv1.Job, error) {
+	return s.cli.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// waitForJob waits for the Job to finish and returns what its pod printed and whether it succeeded.
+// Failing to get the Job doesn't stop the wait unless the Job is gone.
+func (s *fioStepper) waitForJob(ctx context.Context, name, namespace string) (string, bool, error) {
+	var succeeded bool
+	err := wait.PollUntilContextCancel(ctx, JobPollInterval, true, func(ctx context.Context) (bool, error) {
+		job, err := s.cli.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, err
+		}
+		if err != nil {
+			fmt.Printf("Failed to get Job (%s), retrying: %s\n", name, err.Error())
+			return false, nil
+		}
+		succeeded = job.Status.Succeeded > 0
+		return succeeded || job.Status.Failed > 0, nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	pods, err := s.cli.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: jobNameLabel + "=" + name})
+	if err != nil {
+		return "", false, err
+	}
+	if len(pods.Items) == 0 {
+		return "", false, fmt.Errorf("no pod found for Job (%s)", name)
+	}
+	logs, err := s.cli.CoreV1().Pods(namespace).GetLogs(pods.Items[0].Name, &v1.PodLogOptions{Container: ContainerName}).DoRaw(ctx)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "unable to read the output of pod (%s)", pods.Items[0].Name)
+	}
+	return string(logs), succeeded, nil
+}
+
+// readJobResult reads a file from the volume of a finished Job, in a pod with the template of the
+// Job on the node its pod ran on
+func (s *fioStepper) readJobResult(ctx context.Context, job *batchv1.Job, path string) (string, error) {
+	pods, err := s.cli.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: jobNameLabel + "=" + job.Name})
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pod found for Job (%s)", job.Name)
+	}
+	spec := job.Spec.Template.Spec.DeepCopy()
+	spec.NodeName = pods.Items[0].Spec.NodeName
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == ContainerName {
+			spec.Containers[i].Command = []string{"/bin/sh"}
+			spec.Containers[i].Args = []string{"-c", "tail -f /dev/null"}
+		}
+	}
+	reader, err := s.cli.CoreV1().Pods(job.Namespace).Create(ctx, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: ResultReaderGenerateName,
+			Namespace:    job.Namespace,
+			Labels:       map[string]string{CreatedByFIOLabel: "true"},
+		},
+		Spec: *spec,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer cleanup(ctx, func(ctx context.Context) error {
+		return s.deletePod(ctx, reader.Name, job.Namespace)
+	})
+	if err := s.podReady.waitForPodReady(ctx, job.Namespace, reader.Name); err != nil {
+		return "", err
+	}
+	command := []string{"sh", "-c", fmt.Sprintf("if [ -s %[1]s ]; then cat %[1]s; fi", shellQuote(path))}
+	stdout, stderr, err := s.kubeExecutor.exec(ctx, job.Namespace, reader.Name, ContainerName, command)
+	if err != nil {
+		return "", errors.Wrapf(err, "error running command:(%v), stderr:(%s)", command, stderr)
+	}
+	return stdout, nil
+}
+
+// deleteJob only deletes a Job if it has the label, its pods are deleted with it
+func (s *fioStepper) deleteJob(ctx context.Context, job *batchv1.Job) error {
+	if val, ok := job.Labels[CreatedByFIOLabel]; !ok || val != "true" {
+		return nil
+	}
+	propagation := metav1.DeletePropagationBackground
+	return s.cli.BatchV1().Jobs(job.Namespace).Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+}
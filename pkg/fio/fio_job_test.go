@@ -0,0 +1,211 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func (s *FIOTestSuite) TestJobScript(c *C) {
+	c.Assert(jobScript(&RunFIOArgs{}, "test.fio", false, false), Equals,
+		"'fio' '--directory' '/dataset' '/etc/fio-config/test.fio' '--output-format=json' --output='/dataset/kubestr-fio-result.json' 2>>/tmp/kubestr-fio.err; rc=$?"+
+			"; echo kubestr-fio-output; if [ -s '/dataset/kubestr-fio-result.json' ]; then cat '/dataset/kubestr-fio-result.json'; else cat /tmp/kubestr-fio.err; fi; exit $rc")
+
+	script := jobScript(&RunFIOArgs{PVC: "existing", Histogram: true}, "test.fio", true, false)
+	c.Assert(script, Equals,
+		"mkdir -p '/dataset/kubestr-fio-scratch'"+
+			" && 'fio' '--directory' '/dataset/kubestr-fio-scratch' '/etc/fio-config/kubestr-precondition.fio' '--output-format=json+' --output=/dev/null 2>/tmp/kubestr-fio.err"+
+			" && 'fio' '--directory' '/dataset/kubestr-fio-scratch' '/etc/fio-config/test.fio' '--output-format=json+' --output='/tmp/kubestr-fio-result.json' 2>>/tmp/kubestr-fio.err; rc=$?"+
+			"; echo kubestr-fio-output; if [ -s '/tmp/kubestr-fio-result.json' ]; then cat '/tmp/kubestr-fio-result.json'; else cat /tmp/kubestr-fio.err; fi"+
+			"; rm -rf '/dataset/kubestr-fio-scratch'; exit $rc")
+
+	c.Assert(shellQuote("it's"), Equals, `'it'\''s'`)
+}
+
+func (s *FIOTestSuite) TestJobLogsOutput(c *C) {
+	out, err := jobLogsOutput("job", "mkdir: exists\n"+jobOutputMarker+"\n{}")
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, "{}")
+	_, err = jobLogsOutput("job", `"jobs": []}`)
+	c.Assert(err, ErrorMatches, "the logs of Job \\(job\\) are incomplete.*")
+}
+
+func (s *FIOTestSuite) TestFioBatchJob(c *C) {
+	run := fioRun{TestFile: "test.fio", ConfigMap: "cm", PVC: "pvc", CreatedPVC: true, Size: "10Gi"}
+	job, err := fioBatchJob("pvc", "cm", "test.fio", false, false, run, &RunFIOArgs{Namespace: "ns"})
+	c.Assert(err, IsNil)
+	c.Assert(job.Namespace, Equals, "ns")
+	c.Assert(job.GenerateName, Equals, JobGenerateName)
+	c.Assert(*job.Spec.BackoffLimit, Equals, int32(0))
+	c.Assert(job.Spec.Template.Labels, DeepEquals, map[string]string{FIORunLabel: "cm"})
+	c.Assert(job.Spec.Template.Spec.RestartPolicy, Equals, v1.RestartPolicyNever)
+	container := job.Spec.Template.Spec.Containers[0]
+	c.Assert(container.Command[:2], DeepEquals, []string{"/bin/sh", "-c"})
+	c.Assert(container.Args, IsNil)
+	recorded, err := jobFioRun(job)
+	c.Assert(err, IsNil)
+	c.Assert(recorded, DeepEquals, run)
+
+	_, err = jobFioRun(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "other"}})
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestRunFioHelperAsJob(c *C) {
+	newStepper := func() *fakeFioStepper {
+		return &fakeFioStepper{
+			lcmConfigMap: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "CM1"},
+				Data:       map[string]string{"testfile.fio": "testfiledata"},
+			},
+			cPVC:          &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "PVC"}},
+			wJobOut:       jobOutputMarker + "\n" + parsableFioOutput,
+			wJobSucceeded: true,
+			rJobOut:       parsableFioOutput,
+		}
+	}
+	args := &RunFIOArgs{StorageClass: "sc", Size: "100Gi", Namespace: "foo", AsJob: true}
+
+	stepper := newStepper()
+	runner := &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	res, err := runner.RunFioHelper(context.Background(), args)
+	c.Assert(err, IsNil)
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CJOB", "WJOB", "RJOB", "SCE", "DJOB", "DCM", "DPVC"})
+	c.Assert(stepper.cJobExp.Annotations[FioRunAnnotation], Matches, `.*"resultPath":"/dataset/kubestr-fio-result.json".*`)
+	c.Assert(res.Job, Equals, "job")
+	c.Assert(res.PVC, Equals, "PVC")
+	c.Assert(res.Result.Jobs, Not(HasLen), 0)
+
+	// a failed Job is reported with its output and cleaned up
+	stepper = newStepper()
+	stepper.wJobOut, stepper.wJobSucceeded, stepper.rJobOut = jobOutputMarker+"\nfio: unknown option", false, ""
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.RunFioHelper(context.Background(), args)
+	c.Assert(err, ErrorMatches, ".*fio: unknown option.*")
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CJOB", "WJOB", "RJOB", "DJOB", "DCM", "DPVC"})
+
+	// the logs are read when the volume can't be
+	stepper = newStepper()
+	stepper.rJobErr = fmt.Errorf("pod unschedulable")
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	res, err = runner.RunFioHelper(context.Background(), args)
+	c.Assert(err, IsNil)
+	c.Assert(res.Result.Jobs, Not(HasLen), 0)
+
+	// logs that lost their start are not parsed, the resources are kept to re-attach
+	stepper = newStepper()
+	stepper.rJobErr = fmt.Errorf("pod unschedulable")
+	stepper.wJobOut = parsableFioOutput[len(parsableFioOutput)/2:]
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.RunFioHelper(context.Background(), args)
+	c.Assert(err, ErrorMatches, "(?s).*kubestr fio attach job -n foo.*the logs of Job \\(job\\) are incomplete.*")
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CJOB", "WJOB", "RJOB"})
+
+	// an interrupted run leaves the Job and its resources to be attached to
+	stepper = newStepper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.RunFioHelper(ctx, args)
+	c.Assert(err, ErrorMatches, ".*kubestr fio attach job -n foo.*")
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CJOB", "WJOB"})
+
+	// failing to wait leaves the Job and its resources to be attached to
+	stepper = newStepper()
+	stepper.wJobErr = fmt.Errorf("no pod found for Job (job)")
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.RunFioHelper(context.Background(), args)
+	c.Assert(err, ErrorMatches, ".*kubestr fio attach job -n foo.*")
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CJOB", "WJOB"})
+
+	// creating the Job fails
+	stepper = newStepper()
+	stepper.cJobErr = fmt.Errorf("forbidden")
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.RunFioHelper(context.Background(), args)
+	c.Assert(err, NotNil)
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CJOB", "DPVC", "DCM"})
+
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", AsJob: true, Clients: 2}).Validate(), NotNil)
+}
+
+func (s *FIOTestSuite) TestAttachFioHelper(c *C) {
+	job, err := fioBatchJob("pvc", "cm", "test.fio", false, false, fioRun{ConfigMap: "cm", PVC: "pvc"}, &RunFIOArgs{Namespace: "ns"})
+	c.Assert(err, IsNil)
+	job.Name = "kubestr-fio-job-abc"
+	stepper := &fakeFioStepper{gJob: job, wJobOut: jobOutputMarker + "\n" + parsableFioOutput, wJobSucceeded: true}
+	runner := &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	res, err := runner.AttachFioHelper(context.Background(), "ns", job.Name)
+	c.Assert(err, IsNil)
+	c.Assert(res.Job, Equals, job.Name)
+	// the PVC existed before the run
+	c.Assert(stepper.steps, DeepEquals, []string{"GJOB", "WJOB", "DJOB", "DCM"})
+
+	stepper = &fakeFioStepper{gJobErr: fmt.Errorf("not found")}
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.AttachFioHelper(context.Background(), "ns", "missing")
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestWaitForJob(c *C) {
+	ctx := context.Background()
+	cli := fake.NewSimpleClientset(
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "ns"},
+			Status:     batchv1.JobStatus{Succeeded: 1},
+		},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "job-pod", Namespace: "ns", Labels: map[string]string{jobNameLabel: "job"}}},
+	)
+	stepper := &fioStepper{cli: cli}
+	out, succeeded, err := stepper.waitForJob(ctx, "job", "ns")
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, true)
+	c.Assert(out, Equals, "fake logs")
+
+	_, _, err = stepper.waitForJob(ctx, "missing", "ns")
+	c.Assert(apierrors.IsNotFound(err), Equals, true)
+
+	// other errors getting the Job keep the wait going
+	cli.PrependReactor("get", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, _, err = stepper.waitForJob(timeoutCtx, "job", "ns")
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	cli.ReactionChain = cli.ReactionChain[1:]
+
+	// the result is read in a pod on the node the Job ran on
+	executor := &fakeKubeExecutor{keStdOut: "{}"}
+	readStepper := &fioStepper{
+		cli: fake.NewSimpleClientset(
+			&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "job-pod", Namespace: "ns", Labels: map[string]string{jobNameLabel: "job"}}, Spec: v1.PodSpec{NodeName: "node1"}},
+		),
+		podReady:     &fakePodReadyChecker{},
+		kubeExecutor: executor,
+	}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "ns"}}
+	job.Spec.Template.Spec.Containers = []v1.Container{{Name: ContainerName, Command: []string{"/bin/sh", "-c", "fio"}}}
+	out, err = readStepper.readJobResult(ctx, job, "/dataset/kubestr-fio-result.json")
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, "{}")
+	c.Assert(executor.keInCommand, DeepEquals, []string{"sh", "-c", "if [ -s '/dataset/kubestr-fio-result.json' ]; then cat '/dataset/kubestr-fio-result.json'; fi"})
+	readers, err := readStepper.cli.CoreV1().Pods("ns").List(ctx, metav1.ListOptions{LabelSelector: CreatedByFIOLabel})
+	c.Assert(err, IsNil)
+	c.Assert(readers.Items, HasLen, 0)
+
+	c.Assert(stepper.deleteJob(ctx, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "ns"}}), IsNil)
+	_, err = stepper.getJob(ctx, "job", "ns")
+	c.Assert(err, IsNil)
+	c.Assert(stepper.deleteJob(ctx, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "ns", Labels: map[string]string{CreatedByFIOLabel: "true"}}}), IsNil)
+	_, err = stepper.getJob(ctx, "job", "ns")
+	c.Assert(err, NotNil)
+}
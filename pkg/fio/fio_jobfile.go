@@ -0,0 +1,171 @@
+package fio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// globalSection is the section whose options apply to every job that follows it
+const globalSection = "global"
+
+// FioJobFile is a parsed fio job file
+type FioJobFile struct {
+	Sections []FioJobSection
+}
+
+// FioJobSection is a [global] or job section of a fio job file
+type FioJobSection struct {
+	Name    string
+	Line    int
+	Options []FioOption
+}
+
+// FioOption is a key=value or boolean option of a fio job file
+type FioOption struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// FioJob is a job of a job file with the global options that apply to it
+type FioJob struct {
+	FioJobSection
+	Global []FioOption
+}
+
+// ParseFioJobFile parses fio's INI job file format
+func ParseFioJobFile(data string) (*FioJobFile, error) {
+	file := &FioJobFile{}
+	for i, line := range strings.Split(data, "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section (%s)", lineNum, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", lineNum)
+			}
+			file.Sections = append(file.Sections, FioJobSection{Name: name, Line: lineNum})
+			continue
+		}
+		if len(file.Sections) == 0 {
+			return nil, fmt.Errorf("line %d: option (%s) outside of a section", lineNum, line)
+		}
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" || strings.IndexFunc(key, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("line %d: malformed option (%s)", lineNum, line)
+		}
+		section := &file.Sections[len(file.Sections)-1]
+		section.Options = append(section.Options, FioOption{Key: key, Value: strings.TrimSpace(value), Line: lineNum})
+	}
+	return file, nil
+}
+
+// Jobs returns the job sections with the global options preceding each of them
+func (f *FioJobFile) Jobs() []FioJob {
+	var jobs []FioJob
+	var global []FioOption
+	for _, section := range f.Sections {
+		if section.Name == globalSection {
+			global = append(global, section.Options...)
+			continue
+		}
+		jobs = append(jobs, FioJob{FioJobSection: section, Global: append([]FioOption(nil), global...)})
+	}
+	return jobs
+}
+
+// appendJobOptions adds the options returned for every job at the end of its section
+func appendJobOptions(config string, options func(FioJob) []string) (string, error) {
+	file, err := ParseFioJobFile(config)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(config, "\n")
+	jobs := file.Jobs()
+	// insert from the last job on so the line numbers of the earlier ones stay valid
+	for i := len(jobs) - 1; i >= 0; i-- {
+		added := options(jobs[i])
+		if len(added) == 0 {
+			continue
+		}
+		end := sectionEnd(lines, file, jobs[i].Line)
+		lines = append(lines[:end], append(added, lines[end:]...)...)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// sectionEnd returns the index of the line after the last non empty line of the section
+// starting at the given line number
+func sectionEnd(lines []string, file *FioJobFile, line int) int {
+	end := len(lines)
+	for _, section := range file.Sections {
+		if section.Line > line {
+			end = section.Line - 1
+			break
+		}
+	}
+	for end > line && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return end
+}
+
+// Option looks up an option of the job, falling back to the global options
+func (j FioJob) Option(key string) (FioOption, bool) {
+	for _, options := range [][]FioOption{j.Options, j.Global} {
+		for i := len(options) - 1; i >= 0; i-- {
+			if options[i].Key == key {
+				return options[i], true
+			}
+		}
+	}
+	return FioOption{}, false
+}
+
+// JobName is the name fio reports the job with
+func (j FioJob) JobName() string {
+	if name, ok := j.Option("name"); ok && name.Value != "" {
+		return name.Value
+	}
+	return j.Name
+}
+
+// Enabled reports whether a boolean option is set, a bare key counts as set
+func (o FioOption) Enabled() bool {
+	switch strings.ToLower(o.Value) {
+	case "", "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// parseFioSize parses fio sizes such as 4k, 2G or 1GiB into bytes.
+// Like fio, the suffixes are powers of 1024 unless kb_base=1000 is set.
+func parseFioSize(value string, kbBase int64) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "b")
+	s = strings.TrimSuffix(s, "i")
+	multiplier := int64(1)
+	if s != "" {
+		if exp := strings.IndexByte("kmgtp", s[len(s)-1]); exp >= 0 {
+			for i := 0; i <= exp; i++ {
+				multiplier *= kbBase
+			}
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size (%s)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
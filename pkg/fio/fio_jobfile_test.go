@@ -0,0 +1,73 @@
+package fio
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *FIOTestSuite) TestParseFioJobFile(c *C) {
+	file, err := ParseFioJobFile(`; comment
+[global]
+ioengine=libaio
+direct = 1
+# comment
+[job1]
+name=read_iops
+time_based
+[global]
+direct=0
+[job2]
+size=1G
+`)
+	c.Assert(err, IsNil)
+	c.Assert(file.Sections, HasLen, 4)
+	c.Check(file.Sections[0].Options, DeepEquals, []FioOption{
+		{Key: "ioengine", Value: "libaio", Line: 3},
+		{Key: "direct", Value: "1", Line: 4},
+	})
+	jobs := file.Jobs()
+	c.Assert(jobs, HasLen, 2)
+	c.Check(jobs[0].JobName(), Equals, "read_iops")
+	c.Check(jobs[1].JobName(), Equals, "job2")
+	tb, ok := jobs[0].Option("time_based")
+	c.Check(ok, Equals, true)
+	c.Check(tb.Enabled(), Equals, true)
+	direct, _ := jobs[0].Option("direct")
+	c.Check(direct.Value, Equals, "1")
+	// later global sections only apply to the jobs that follow
+	direct, _ = jobs[1].Option("direct")
+	c.Check(direct.Value, Equals, "0")
+	_, ok = jobs[1].Option("time_based")
+	c.Check(ok, Equals, false)
+
+	for _, bad := range []string{
+		"size=1G",
+		"[job1",
+		"[ ]",
+		"[job1]\nbad key=1",
+	} {
+		_, err := ParseFioJobFile(bad)
+		c.Check(err, NotNil, Commentf(bad))
+	}
+}
+
+func (s *FIOTestSuite) TestParseFioSize(c *C) {
+	for _, tc := range []struct {
+		value  string
+		kbBase int64
+		size   int64
+		ok     bool
+	}{
+		{value: "4096", kbBase: 1024, size: 4096, ok: true},
+		{value: "4k", kbBase: 1024, size: 4096, ok: true},
+		{value: "2G", kbBase: 1024, size: 2 << 30, ok: true},
+		{value: "1GiB", kbBase: 1024, size: 1 << 30, ok: true},
+		{value: "1.5m", kbBase: 1024, size: 3 << 19, ok: true},
+		{value: "1g", kbBase: 1000, size: 1e9, ok: true},
+		{value: "big", kbBase: 1024, ok: false},
+		{value: "-1k", kbBase: 1024, ok: false},
+	} {
+		size, err := parseFioSize(tc.value, tc.kbBase)
+		c.Check(err == nil, Equals, tc.ok, Commentf(tc.value))
+		c.Check(size, Equals, tc.size, Commentf(tc.value))
+	}
+}
@@ -1,8 +1,82 @@
 package fio
 
-var fioJobs = map[string]string{
-	DefaultFIOJob: testJob1,
-	"randrw":      randReadWrite,
+import "sort"
+
+// fioJob is a predefined kubestr fio test
+type fioJob struct {
+	description string
+	config      string
+}
+
+// FioTest describes a predefined kubestr fio test
+type FioTest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var fioJobs = map[string]fioJob{
+	DefaultFIOJob: {
+		description: "4K random read and write IOPS and 128K random read and write bandwidth, without latencies",
+		config:      testJob1,
+	},
+	CanaryFIOJob: {
+		description: "Canary: short 4K random 75/25 read/write mix with latencies that fits a 1Gi PVC",
+		config:      canaryJob,
+	},
+	"randrw": {
+		description: "4K random 75/25 read/write mix",
+		config:      randReadWrite,
+	},
+	"latency": {
+		description: "4K random read and write latency at queue depth 1",
+		config:      latencyJob,
+	},
+	"randrw-lat": {
+		description: "4K random 75/25 read/write mix latency at queue depth 16",
+		config:      randReadWriteLatency,
+	},
+	"etcd": {
+		description: "etcd WAL: small sequential writes, each followed by fdatasync",
+		config:      etcdJob,
+	},
+	"oltp-8k": {
+		description: "PostgreSQL OLTP: 8K random 70/30 read/write mix from 4 workers",
+		config:      oltp8kJob,
+	},
+	"oltp-16k": {
+		description: "MySQL InnoDB OLTP: 16K random 70/30 read/write mix from 4 workers",
+		config:      oltp16kJob,
+	},
+	IntegrityFIOJob: {
+		description: "Data integrity: sequential and random writes read back and checked with crc32c",
+		config:      integrityJob,
+	},
+	"kafka": {
+		description: "Kafka: buffered 1M sequential appends with a consumer reading sequentially",
+		config:      kafkaJob,
+	},
+	"es-merge": {
+		description: "Elasticsearch segment merge: 256K sequential reads and writes alongside 4K random search reads",
+		config:      esMergeJob,
+	},
+	"object-store": {
+		description: "Object store: random reads and writes spread over thousands of small files",
+		config:      objectStoreJob,
+	},
+	"vm-boot-storm": {
+		description: "VM boot storm: 8 workers of mostly random reads with mixed 4K-64K blocks",
+		config:      vmBootStormJob,
+	},
+}
+
+// ListFioTests returns the predefined kubestr fio tests ordered by name
+func ListFioTests() []FioTest {
+	var tests []FioTest
+	for name, job := range fioJobs {
+		tests = append(tests, FioTest{Name: name, Description: job.description})
+	}
+	sort.Slice(tests, func(i, j int) bool { return tests[i].Name < tests[j].Name })
+	return tests
 }
 
 var testJob1 = `[global]
@@ -66,3 +140,228 @@ time_based
 ramp_time=2s
 runtime=15s
 `
+
+// latencyJob measures the latency of single outstanding I/Os.
+// gtod_reduce is left off so fio collects latencies and percentiles.
+var latencyJob = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+percentile_list=50:90:99:99.9:99.99
+[job1]
+name=read_lat
+bs=4K
+iodepth=1
+size=2G
+readwrite=randread
+time_based
+ramp_time=2s
+runtime=15s
+[job2]
+name=write_lat
+bs=4K
+iodepth=1
+size=2G
+readwrite=randwrite
+time_based
+ramp_time=2s
+runtime=15s
+`
+
+// randReadWriteLatency measures latencies of a mixed workload under moderate load
+var randReadWriteLatency = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+percentile_list=50:90:99:99.9:99.99
+[job1]
+name=rand_readwrite_lat
+bs=4K
+iodepth=16
+size=4G
+readwrite=randrw
+rwmixread=75
+time_based
+ramp_time=2s
+runtime=15s
+`
+
+// canaryJob is short and small enough to run on every cycle of the storage canary
+var canaryJob = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+percentile_list=50:90:99:99.9
+[job1]
+name=canary
+bs=4K
+iodepth=16
+size=256M
+readwrite=randrw
+rwmixread=75
+time_based
+ramp_time=2s
+runtime=10s
+`
+
+// etcdJob follows the fio check recommended for etcd, fdatasync latency is reported in the sync stats
+var etcdJob = `[global]
+randrepeat=0
+verify=0
+ioengine=sync
+fdatasync=1
+[job1]
+name=etcd_wal
+bs=2300
+size=22m
+readwrite=write
+`
+
+var integrityJob = `[global]
+randrepeat=0
+ioengine=libaio
+direct=1
+verify=crc32c
+do_verify=1
+[job1]
+name=seq_write_verify
+bs=128K
+iodepth=16
+size=1G
+readwrite=write
+[job2]
+name=rand_write_verify
+bs=4K
+iodepth=16
+size=1G
+readwrite=randwrite
+verify_backlog=4096
+stonewall
+`
+
+var oltp8kJob = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+percentile_list=50:90:99:99.9
+[job1]
+name=oltp_8k
+bs=8K
+iodepth=16
+numjobs=4
+size=4G
+readwrite=randrw
+rwmixread=70
+time_based
+ramp_time=2s
+runtime=30s
+`
+
+var oltp16kJob = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+percentile_list=50:90:99:99.9
+[job1]
+name=oltp_16k
+bs=16K
+iodepth=16
+numjobs=4
+size=4G
+readwrite=randrw
+rwmixread=70
+time_based
+ramp_time=2s
+runtime=30s
+`
+
+var kafkaJob = `[global]
+randrepeat=0
+verify=0
+ioengine=psync
+direct=0
+time_based
+ramp_time=2s
+runtime=30s
+[job1]
+name=producer_append
+bs=1M
+size=8G
+readwrite=write
+fsync=64
+[job2]
+name=consumer_read
+bs=1M
+size=8G
+readwrite=read
+`
+
+var esMergeJob = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+time_based
+ramp_time=2s
+runtime=30s
+[job1]
+name=merge_read
+bs=256K
+iodepth=8
+size=4G
+readwrite=read
+[job2]
+name=merge_write
+bs=256K
+iodepth=8
+size=4G
+readwrite=write
+[job3]
+name=search_read
+bs=4K
+iodepth=4
+size=4G
+readwrite=randread
+`
+
+var objectStoreJob = `[global]
+randrepeat=0
+verify=0
+ioengine=psync
+direct=0
+[job1]
+name=small_objects
+bssplit=4K/50:64K/40:256K/10
+nrfiles=4000
+filesize=4K-256K
+file_service_type=random
+size=1G
+readwrite=randrw
+rwmixread=60
+time_based
+ramp_time=2s
+runtime=30s
+`
+
+var vmBootStormJob = `[global]
+randrepeat=0
+verify=0
+ioengine=libaio
+direct=1
+[job1]
+name=boot_storm
+bssplit=4K/60:16K/25:64K/15
+iodepth=8
+numjobs=8
+size=1G
+readwrite=randrw
+rwmixread=90
+time_based
+ramp_time=2s
+runtime=30s
+`
@@ -0,0 +1,368 @@
+package fio
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kastenhq/kubestr/pkg/common"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// LintSeverity is the severity of a lint issue. The values match the kubestr status codes.
+type LintSeverity string
+
+const (
+	// LintWarning means the job file may not test what was intended
+	LintWarning = LintSeverity("Warning")
+	// LintError means the job file would fail or would not test the volume
+	LintError = LintSeverity("Error")
+)
+
+// FioLintIssue is a problem found in a fio job file
+type FioLintIssue struct {
+	Line     int          `json:"line,omitempty"`
+	Section  string       `json:"section,omitempty"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+func (i FioLintIssue) String() string {
+	var location []string
+	if i.Line > 0 {
+		location = append(location, fmt.Sprintf("line %d", i.Line))
+	}
+	if i.Section != "" {
+		location = append(location, fmt.Sprintf("[%s]", i.Section))
+	}
+	if len(location) == 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(location, " "), i.Message)
+}
+
+// imageIOEngines are the I/O engines available in the default kubestr image
+var imageIOEngines = map[string]bool{
+	"sync": true, "psync": true, "vsync": true, "pvsync": true, "pvsync2": true,
+	"libaio": true, "io_uring": true, "posixaio": true, "mmap": true, "splice": true,
+	"sg": true, "null": true, "net": true, "netsplice": true, "cpuio": true,
+	"filecreate": true, "filestat": true, "filedelete": true, "dircreate": true,
+	"dirstat": true, "dirdelete": true, "ftruncate": true, "falloc": true,
+	"e4defrag": true, "exec": true,
+}
+
+// fioRWValues are the valid values of rw and readwrite
+var fioRWValues = map[string]bool{
+	"read": true, "write": true, "trim": true, "randread": true, "randwrite": true,
+	"randtrim": true, "rw": true, "readwrite": true, "randrw": true, "trimwrite": true,
+	"randtrimwrite": true,
+}
+
+// knownFioOptions are the fio options kubestr recognises, others are reported as warnings
+var knownFioOptions = map[string]bool{
+	"name": true, "description": true, "wait_for": true, "stonewall": true, "new_group": true,
+	"numjobs": true, "group_reporting": true, "thread": true, "directory": true, "filename": true,
+	"filename_format": true, "unique_filename": true, "lockfile": true, "nrfiles": true,
+	"openfiles": true, "file_service_type": true, "fallocate": true, "fadvise_hint": true,
+	"fsync": true, "fdatasync": true, "sync": true, "write_barrier": true, "sync_file_range": true,
+	"overwrite": true, "end_fsync": true, "fsync_on_close": true, "rw": true, "readwrite": true,
+	"rw_sequencer": true, "unified_rw_reporting": true, "randrepeat": true, "randseed": true,
+	"random_generator": true, "random_distribution": true, "percentage_random": true,
+	"norandommap": true, "softrandommap": true, "allrandrepeat": true, "rwmixread": true,
+	"rwmixwrite": true, "size": true, "io_size": true, "io_limit": true, "filesize": true,
+	"file_append": true, "fill_device": true, "fill_fs": true, "offset": true,
+	"offset_increment": true, "number_ios": true, "bs": true, "blocksize": true, "bsrange": true,
+	"blocksize_range": true, "bssplit": true, "blocksize_unaligned": true, "bs_unaligned": true,
+	"ba": true, "blockalign": true, "bs_is_seq_rand": true, "zero_buffers": true,
+	"refill_buffers": true, "scramble_buffers": true, "buffer_compress_percentage": true,
+	"buffer_compress_chunk": true, "dedupe_percentage": true, "buffer_pattern": true,
+	"ioengine": true, "iodepth": true, "iodepth_batch": true, "iodepth_batch_submit": true,
+	"iodepth_batch_complete": true, "iodepth_batch_complete_min": true,
+	"iodepth_batch_complete_max": true, "iodepth_low": true, "io_submit_mode": true,
+	"direct": true, "buffered": true, "atomic": true, "hipri": true, "fixedbufs": true,
+	"registerfiles": true, "sqthread_poll": true, "userspace_reap": true, "thinktime": true,
+	"thinktime_spin": true, "thinktime_blocks": true, "rate": true, "rate_min": true,
+	"rate_iops": true, "rate_iops_min": true, "rate_process": true, "rate_ignore_thinktime": true,
+	"rate_cycle": true, "latency_target": true, "latency_window": true,
+	"latency_percentile": true, "max_latency": true, "runtime": true, "timeout": true,
+	"time_based": true, "ramp_time": true, "startdelay": true, "clocksource": true,
+	"gtod_reduce": true, "gtod_cpu": true, "disable_lat": true, "disable_clat": true,
+	"disable_slat": true, "disable_bw_measurement": true, "cpus_allowed": true, "cpumask": true,
+	"cpus_allowed_policy": true, "nice": true, "prio": true, "prioclass": true, "mem": true,
+	"iomem": true, "iomem_align": true, "mem_align": true, "hugepage-size": true,
+	"lat_percentiles": true, "clat_percentiles": true, "slat_percentiles": true,
+	"percentile_list": true, "write_bw_log": true, "write_lat_log": true, "write_iops_log": true,
+	"write_hist_log": true, "log_avg_msec": true, "log_hist_msec": true, "log_max_value": true,
+	"log_offset": true, "log_compression": true, "per_job_logs": true, "verify": true,
+	"do_verify": true, "verify_offset": true, "verify_interval": true, "verify_pattern": true,
+	"verify_fatal": true, "verify_dump": true, "verify_async": true, "verify_backlog": true,
+	"verify_backlog_batch": true, "verify_state_save": true, "verify_state_load": true,
+	"verify_only": true, "trim_percentage": true, "trim_verify_zero": true, "trim_backlog": true,
+	"experimental_verify": true, "continue_on_error": true, "ignore_error": true,
+	"error_dump": true, "exitall": true, "exitall_on_error": true, "unlink": true,
+	"unlink_each_loop": true, "loops": true, "create_serialize": true, "create_fsync": true,
+	"create_on_open": true, "create_only": true, "allow_file_create": true,
+	"allow_mounted_write": true, "pre_read": true, "invalidate": true, "kb_base": true,
+	"unit_base": true, "steadystate": true, "ss": true, "steadystate_duration": true,
+	"ss_dur": true, "steadystate_ramp_time": true, "ss_ramp": true, "zonemode": true,
+	"zonesize": true, "zonerange": true, "zoneskip": true, "serialize_overlap": true,
+	"significant_figures": true, "disk_util": true, "exec_prerun": true, "exec_postrun": true,
+	"ioscheduler": true, "replay_no_stall": true, "read_iolog": true, "write_iolog": true,
+	"iolog": true, "stats": true, "fsync_range": true, "uid": true, "gid": true,
+	"flow": true, "flow_id": true, "flow_watermark": true, "flow_sleep": true,
+}
+
+// LintFioJobFile checks a fio job file against the arguments it will be run with
+func LintFioJobFile(config string, args *RunFIOArgs) []FioLintIssue {
+	file, err := ParseFioJobFile(config)
+	if err != nil {
+		return []FioLintIssue{{Severity: LintError, Message: err.Error()}}
+	}
+	jobs := file.Jobs()
+	if len(jobs) == 0 {
+		return []FioLintIssue{{Severity: LintError, Message: "no jobs defined"}}
+	}
+	var issues []FioLintIssue
+	for _, section := range file.Sections {
+		issues = append(issues, lintSection(section)...)
+		for _, o := range section.Options {
+			if !knownFioOptions[o.Key] {
+				issues = append(issues, FioLintIssue{Line: o.Line, Section: section.Name, Severity: LintWarning,
+					Message: fmt.Sprintf("unknown option (%s)", o.Key)})
+			}
+		}
+	}
+	var total int64
+	for _, job := range jobs {
+		issues = append(issues, lintJob(job, args)...)
+		size, err := jobSize(job)
+		if err != nil {
+			issues = append(issues, FioLintIssue{Line: job.Line, Section: job.Name, Severity: LintError, Message: err.Error()})
+		}
+		total += size
+	}
+	// every pod has a memory reference of its own, the jobs are shrunk to fit unless they are too many
+	if limit := args.memoryReferenceSize(); args.hasReference(ReferenceMemory) && total > limit.Value() {
+		if refSize, err := memoryReferenceSize(config, limit.Value()); err == nil && refSize > limit.Value() {
+			issues = append(issues, FioLintIssue{Severity: LintError,
+				Message: fmt.Sprintf("the jobs need %s on the memory reference even when shrunk, more than its size limit (%s)", resource.NewQuantity(refSize, resource.BinarySI), limit.String())})
+		}
+	}
+	if !args.SharedFile {
+		total *= int64(args.clientCount())
+	}
+	if volumeSize, ok := lintVolumeSize(args); ok && total > volumeSize {
+		issues = append(issues, FioLintIssue{Severity: LintError,
+			Message: fmt.Sprintf("the jobs need %s, more than the volume size (%s)", resource.NewQuantity(total, resource.BinarySI), args.Size)})
+	}
+	sortIssues(issues)
+	return issues
+}
+
+// lintSection flags sections that look like a misspelled [global], fio would run them as a job
+func lintSection(section FioJobSection) []FioLintIssue {
+	if section.Name == globalSection {
+		return nil
+	}
+	if strings.EqualFold(section.Name, globalSection) || editDistance(strings.ToLower(section.Name), globalSection) <= 2 {
+		return []FioLintIssue{{Line: section.Line, Section: section.Name, Severity: LintError,
+			Message: fmt.Sprintf("unknown section (%s), did you mean [%s]? fio would run it as a job", section.Name, globalSection)}}
+	}
+	return nil
+}
+
+// editDistance is the number of single character edits that turn a into b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// LintFailed reports whether any issue is an error
+func LintFailed(issues []FioLintIssue) bool {
+	for _, i := range issues {
+		if i.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+func lintJob(job FioJob, args *RunFIOArgs) []FioLintIssue {
+	var issues []FioLintIssue
+	issue := func(o FioOption, severity LintSeverity, format string, a ...interface{}) {
+		line := o.Line
+		if line == 0 {
+			line = job.Line
+		}
+		issues = append(issues, FioLintIssue{Line: line, Section: job.Name, Severity: severity, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if engine, ok := job.Option("ioengine"); ok && !imageIOEngines[engine.Value] {
+		if args.Image == "" || args.Image == common.DefaultPodImage {
+			issue(engine, LintError, "ioengine (%s) is not available in the image (%s)", engine.Value, common.DefaultPodImage)
+		} else {
+			issue(engine, LintWarning, "ioengine (%s) may not be available in the image (%s)", engine.Value, args.Image)
+		}
+	}
+	for _, key := range []string{"rw", "readwrite"} {
+		if rw, ok := job.Option(key); ok {
+			mode, _, _ := strings.Cut(rw.Value, ":")
+			if !fioRWValues[mode] {
+				issue(rw, LintError, "invalid %s (%s)", key, rw.Value)
+			}
+		}
+	}
+
+	dir, hasDir := job.Option("directory")
+	filename, hasFilename := job.Option("filename")
+	switch {
+	case args.isBlock() && hasFilename && filename.Value != VolumeDevicePath:
+		issue(filename, LintError, "filename (%s) overrides the block device (%s), FIO would not test the volume", filename.Value, VolumeDevicePath)
+	case args.isBlock() && hasDir:
+		issue(dir, LintWarning, "directory (%s) is ignored in Block mode", dir.Value)
+	case hasDir && !underVolume(dir.Value):
+		issue(dir, LintError, "directory (%s) is outside the volume mounted at (%s), FIO would not test the volume", dir.Value, VolumeMountPath)
+	case hasFilename && path.IsAbs(filename.Value) && !underVolume(filename.Value):
+		issue(filename, LintError, "filename (%s) is outside the volume mounted at (%s), FIO would not test the volume", filename.Value, VolumeMountPath)
+	case hasFilename && args.SharedFile:
+		issue(filename, LintWarning, "filename (%s) overrides the file shared by the clients", filename.Value)
+	case hasDir && args.clientCount() > 1:
+		issue(dir, LintWarning, "directory (%s) overrides the directory of each client", dir.Value)
+	}
+	if hasDir && hasFilename && path.IsAbs(filename.Value) {
+		issue(dir, LintWarning, "directory (%s) is ignored because filename (%s) is an absolute path", dir.Value, filename.Value)
+	}
+
+	_, hasSize := job.Option("size")
+	_, hasFilesize := job.Option("filesize")
+	if !hasSize && !hasFilesize && !args.isBlock() {
+		if args.PVC != "" {
+			issue(FioOption{}, LintWarning, "no size set, the files must already exist on the volume")
+		} else {
+			issue(FioOption{}, LintError, "no size set, FIO needs a size to lay out its files")
+		}
+	}
+
+	if gtod, ok := job.Option("gtod_reduce"); ok && gtod.Enabled() {
+		for _, t := range args.Thresholds {
+			if t.isLatency() && t.matchesJob(job.JobName()) {
+				issue(gtod, LintError, "gtod_reduce disables the latencies the threshold %s is evaluated against", t)
+			}
+		}
+	}
+	return issues
+}
+
+// memoryReferenceSize is the space the files of the jobs take up on the memory reference
+func memoryReferenceSize(config string, limit int64) (int64, error) {
+	reference, err := memoryReferenceFioJob(config, limit)
+	if err != nil {
+		return 0, err
+	}
+	file, err := ParseFioJobFile(reference)
+	if err != nil {
+		return 0, err
+	}
+	return jobsSize(file.Jobs())
+}
+
+// jobsSize is the space the files of the jobs take up
+func jobsSize(jobs []FioJob) (int64, error) {
+	var total int64
+	for _, job := range jobs {
+		size, err := jobSize(job)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// jobInstances is the number of instances fio runs of a job, 1 unless numjobs is valid
+func jobInstances(job FioJob) int64 {
+	if numjobs, ok := job.Option("numjobs"); ok {
+		if n, err := strconv.ParseInt(numjobs.Value, 10, 64); err == nil && n > 1 {
+			return n
+		}
+	}
+	return 1
+}
+
+// jobSize is the space the files of a job take up
+func jobSize(job FioJob) (int64, error) {
+	kbBase := int64(1024)
+	if base, ok := job.Option("kb_base"); ok && base.Value == "1000" {
+		kbBase = 1000
+	}
+	size, ok := job.Option("size")
+	if !ok || strings.HasSuffix(size.Value, "%") {
+		return 0, nil
+	}
+	bytes, err := parseFioSize(size.Value, kbBase)
+	if err != nil {
+		return 0, err
+	}
+	if numjobs, ok := job.Option("numjobs"); ok {
+		n, err := strconv.ParseInt(numjobs.Value, 10, 64)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid numjobs (%s)", numjobs.Value)
+		}
+		bytes *= n
+	}
+	return bytes, nil
+}
+
+// lintVolumeSize is the size of the volume the jobs run against, if known
+func lintVolumeSize(args *RunFIOArgs) (int64, bool) {
+	if args.Size == "" {
+		return 0, false
+	}
+	size, err := resource.ParseQuantity(args.Size)
+	if err != nil {
+		return 0, false
+	}
+	return size.Value(), true
+}
+
+func underVolume(p string) bool {
+	p = path.Clean(p)
+	return p == VolumeMountPath || strings.HasPrefix(p, VolumeMountPath+"/")
+}
+
+// isLatency reports whether the metric is a latency
+func (t Threshold) isLatency() bool {
+	return t.Metric != ThresholdIOPS && t.Metric != ThresholdBW
+}
+
+// matchesJob reports whether the selector matches any direction of the job
+func (t Threshold) matchesJob(job string) bool {
+	for _, direction := range fioDirections {
+		if t.matches(job, direction) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortIssues orders issues by line, issues about the whole file first
+func sortIssues(issues []FioLintIssue) {
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+}
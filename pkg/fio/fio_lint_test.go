@@ -0,0 +1,200 @@
+package fio
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func (s *FIOTestSuite) TestLintBuiltinJobs(c *C) {
+	for name, job := range fioJobs {
+		issues := LintFioJobFile(job.config, &RunFIOArgs{Size: DefaultPVCSize})
+		c.Check(issues, HasLen, 0, Commentf(name))
+	}
+}
+
+func (s *FIOTestSuite) TestLintFioJobFile(c *C) {
+	for _, tc := range []struct {
+		config   string
+		args     *RunFIOArgs
+		failed   bool
+		contains string
+	}{
+		{ // parse error
+			config:   "size=1G",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "outside of a section",
+		},
+		{ // no jobs
+			config:   "[global]\nsize=1G",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "no jobs defined",
+		},
+		{ // unknown option
+			config:   "[job]\nsize=1G\nsizee=1G",
+			args:     &RunFIOArgs{},
+			failed:   false,
+			contains: "line 3 [job]: unknown option (sizee)",
+		},
+		{ // jobs larger than the volume
+			config:   "[global]\nsize=2G\n[a]\n[b]\nnumjobs=2",
+			args:     &RunFIOArgs{Size: "5Gi"},
+			failed:   true,
+			contains: "the jobs need 6Gi, more than the volume size (5Gi)",
+		},
+		{ // jobs larger than the memory reference are shrunk to fit
+			config: "[a]\nsize=2G",
+			args:   &RunFIOArgs{Size: "5Gi", Clients: 2, References: []ReferenceMedium{ReferenceMemory}},
+			failed: false,
+		},
+		{ // jobs that can't be shrunk to fit the memory reference
+			config:   "[a]\nsize=2G\nnumjobs=2",
+			args:     &RunFIOArgs{Size: "5Gi", References: []ReferenceMedium{ReferenceMemory}, ReferenceSize: "1Mi"},
+			failed:   true,
+			contains: "the jobs need 2Mi on the memory reference even when shrunk, more than its size limit (1Mi)",
+		},
+		{ // jobs that fit the memory reference of every client
+			config: "[a]\nsize=2G",
+			args:   &RunFIOArgs{Size: "5Gi", Clients: 2, References: []ReferenceMedium{ReferenceMemory}, ReferenceSize: "2Gi"},
+			failed: false,
+		},
+		{ // every client lays out its own files
+			config:   "[a]\nsize=2G",
+			args:     &RunFIOArgs{Size: "5Gi", Clients: 3},
+			failed:   true,
+			contains: "the jobs need 6Gi",
+		},
+		{ // unless they share a file
+			config: "[a]\nsize=2G",
+			args:   &RunFIOArgs{Size: "5Gi", Clients: 3, SharedFile: true},
+			failed: false,
+		},
+		{ // invalid numjobs
+			config:   "[a]\nsize=2G\nnumjobs=x",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "invalid numjobs (x)",
+		},
+		{ // missing size
+			config:   "[a]\nrw=read",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "no size set",
+		},
+		{ // missing size is fine when the files exist
+			config:   "[a]\nrw=read",
+			args:     &RunFIOArgs{PVC: "pvc"},
+			failed:   false,
+			contains: "the files must already exist",
+		},
+		{ // block devices have a size
+			config: "[a]\nrw=read",
+			args:   &RunFIOArgs{VolumeMode: v1.PersistentVolumeBlock},
+			failed: false,
+		},
+		{ // directory outside the volume
+			config:   "[a]\nsize=1G\ndirectory=/tmp",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "directory (/tmp) is outside the volume",
+		},
+		{ // directory in the volume
+			config: "[a]\nsize=1G\ndirectory=/dataset/sub",
+			args:   &RunFIOArgs{},
+			failed: false,
+		},
+		{ // absolute filename outside the volume
+			config:   "[a]\nsize=1G\nfilename=/tmp/file",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "filename (/tmp/file) is outside the volume",
+		},
+		{ // directory and absolute filename
+			config:   "[a]\nsize=1G\ndirectory=/dataset/a\nfilename=/dataset/file",
+			args:     &RunFIOArgs{},
+			failed:   false,
+			contains: "directory (/dataset/a) is ignored because filename",
+		},
+		{ // filename overrides the block device
+			config:   "[a]\nfilename=/dev/sda",
+			args:     &RunFIOArgs{VolumeMode: v1.PersistentVolumeBlock},
+			failed:   true,
+			contains: "overrides the block device",
+		},
+		{ // filename overrides the shared file
+			config:   "[a]\nsize=1G\nfilename=file",
+			args:     &RunFIOArgs{Clients: 2, SharedFile: true},
+			failed:   false,
+			contains: "overrides the file shared by the clients",
+		},
+		{ // engine missing from the image
+			config:   "[global]\nioengine=rbd\n[a]\nsize=1G",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "line 2 [a]: ioengine (rbd) is not available",
+		},
+		{ // engine may be in a custom image
+			config:   "[a]\nsize=1G\nioengine=rbd",
+			args:     &RunFIOArgs{Image: "custom/fio"},
+			failed:   false,
+			contains: "ioengine (rbd) may not be available in the image (custom/fio)",
+		},
+		{ // invalid rw
+			config:   "[a]\nsize=1G\nrw=randomread",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "invalid rw (randomread)",
+		},
+		{ // gtod_reduce with a latency threshold of the job
+			config:   "[global]\ngtod_reduce=1\nsize=1G\n[a]\nname=read_lat",
+			args:     &RunFIOArgs{Thresholds: []Threshold{{Metric: ThresholdLatP99, Selector: "read_lat.read", Max: "1ms"}}},
+			failed:   true,
+			contains: "gtod_reduce disables the latencies",
+		},
+		{ // gtod_reduce with a latency threshold of another job
+			config: "[a]\ngtod_reduce=1\nsize=1G\n[b]\nsize=1G",
+			args:   &RunFIOArgs{Thresholds: []Threshold{{Metric: ThresholdLatMean, Selector: "b", Max: "1ms"}}},
+			failed: false,
+		},
+		{ // misspelled global section
+			config:   "[globl]\nsize=1G\n[a]\nrw=read",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "line 1 [globl]: unknown section (globl), did you mean [global]?",
+		},
+		{ // global section in the wrong case
+			config:   "[Global]\nsize=1G\n[a]\nsize=1G",
+			args:     &RunFIOArgs{},
+			failed:   true,
+			contains: "unknown section (Global)",
+		},
+		{ // a job named like global
+			config: "[global]\nsize=1G\n[globalization]\nrw=read",
+			args:   &RunFIOArgs{},
+			failed: false,
+		},
+		{ // gtod_reduce with an IOPS threshold
+			config: "[a]\ngtod_reduce=1\nsize=1G",
+			args:   &RunFIOArgs{Thresholds: []Threshold{{Metric: ThresholdIOPS, Min: "100"}}},
+			failed: false,
+		},
+		{ // gtod_reduce turned off
+			config: "[a]\ngtod_reduce=0\nsize=1G",
+			args:   &RunFIOArgs{Thresholds: []Threshold{{Metric: ThresholdLatP99, Max: "1ms"}}},
+			failed: false,
+		},
+	} {
+		issues := LintFioJobFile(tc.config, tc.args)
+		c.Check(LintFailed(issues), Equals, tc.failed, Commentf("%s: %v", tc.config, issues))
+		if tc.contains != "" {
+			var messages []string
+			for _, issue := range issues {
+				messages = append(messages, issue.String())
+			}
+			c.Check(strings.Contains(strings.Join(messages, "\n"), tc.contains), Equals, true, Commentf("%s: %v", tc.config, messages))
+		}
+	}
+}
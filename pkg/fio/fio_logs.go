@@ -0,0 +1,306 @@
+package fio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// LogDirPath is the directory in the pod FIO writes its time series logs to
+	LogDirPath = "/tmp/kubestr-fio-logs"
+	// logPrefix prefixes the log files, fio names them <prefix>_<metric>.<job number>.log
+	logPrefix = "kubestr"
+	// SustainedDropFraction is how far throughput has to fall below its baseline to count as a drop
+	SustainedDropFraction = 0.3
+	// minDropSamples is the fewest samples a series needs before drops are detected
+	minDropSamples = 8
+)
+
+// fioLogDirections maps the direction column of fio logs to a direction name
+var fioLogDirections = map[string]string{"0": "read", "1": "write", "2": "trim", "3": "sync"}
+
+// FioLogSeries is the time series of a single metric of a job in one direction.
+// Bandwidth is in KiB/s and latencies are in ns, as fio logs them.
+type FioLogSeries struct {
+	Pod       string         `json:"pod,omitempty"`
+	Job       string         `json:"job"`
+	Metric    string         `json:"metric"`
+	Direction string         `json:"direction"`
+	Samples   []FioLogSample `json:"samples"`
+	Summary   FioLogSummary  `json:"summary"`
+	Drop      *FioLogDrop    `json:"drop,omitempty"`
+}
+
+// FioLogSample is a single interval of a time series
+type FioLogSample struct {
+	TimeMS int64 `json:"time_ms"`
+	Value  int64 `json:"value"`
+}
+
+// FioLogSummary holds the statistics of a time series across all intervals
+type FioLogSummary struct {
+	Min    int64   `json:"min"`
+	Max    int64   `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+}
+
+// FioLogDrop describes throughput that stayed below its baseline until the end of the run,
+// e.g. when a cloud disk runs out of burst credits
+type FioLogDrop struct {
+	StartMS  int64   `json:"start_ms"`
+	Baseline float64 `json:"baseline"`
+	Mean     float64 `json:"mean"`
+	Percent  float64 `json:"percent"`
+}
+
+// Name identifies the series by pod, job, direction and metric
+func (s FioLogSeries) Name() string {
+	name := fmt.Sprintf("%s %s %s", s.Job, s.Direction, s.Metric)
+	if s.Pod != "" {
+		name = fmt.Sprintf("%s %s", s.Pod, name)
+	}
+	return name
+}
+
+func (s FioLogSeries) Print() string {
+	return fmt.Sprintf("  %s: samples=%d min=%d max=%d avg=%.2f stdev=%.2f", s.Name(), len(s.Samples),
+		s.Summary.Min, s.Summary.Max, s.Summary.Mean, s.Summary.StdDev)
+}
+
+func (d FioLogDrop) Print() string {
+	return fmt.Sprintf("sustained drop of %.1f%% from %s (baseline %.2f, after %.2f)",
+		d.Percent, time.Duration(d.StartMS)*time.Millisecond, d.Baseline, d.Mean)
+}
+
+// logFioArgs returns the fio arguments that write time series logs into dir
+func logFioArgs(dir string, interval time.Duration) []string {
+	prefix := fmt.Sprintf("%s/%s", dir, logPrefix)
+	return []string{
+		"--write_bw_log=" + prefix,
+		"--write_iops_log=" + prefix,
+		"--write_lat_log=" + prefix,
+		fmt.Sprintf("--log_avg_msec=%d", interval.Milliseconds()),
+	}
+}
+
+// parseFioLogs turns the log files of a client, keyed by file name, into time series.
+// Job numbers in the file names are resolved to the job names of the job file.
+func parseFioLogs(files map[string]string, config string, jobs []FioJobs, pod string) ([]FioLogSeries, error) {
+	names := logJobNames(config, jobs)
+	var series []FioLogSeries
+	for fileName, data := range files {
+		metric, jobNum, ok := parseLogFileName(fileName)
+		if !ok {
+			continue
+		}
+		job, ok := names[jobNum]
+		if !ok {
+			job = strconv.Itoa(jobNum)
+		}
+		samples, err := parseFioLog(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse fio log (%s)", fileName)
+		}
+		for direction, dirSamples := range samples {
+			s := FioLogSeries{
+				Pod:       pod,
+				Job:       job,
+				Metric:    metric,
+				Direction: direction,
+				Samples:   dirSamples,
+				Summary:   summariseSamples(dirSamples),
+			}
+			if metric == "bw" || metric == "iops" {
+				s.Drop = detectSustainedDrop(dirSamples)
+			}
+			series = append(series, s)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Name() < series[j].Name() })
+	return series, nil
+}
+
+// logJobNames maps the numbers fio gives the logs of every job instance to job names. Instances
+// of a job with numjobs log separately, even when group_reporting merges them in the result, so
+// they are numbered from the job file and named like fio names their files, <job>.<instance>.
+// The jobs of the result are only used when the job file can't be parsed.
+func logJobNames(config string, jobs []FioJobs) map[int]string {
+	names := map[int]string{}
+	file, err := ParseFioJobFile(config)
+	if err != nil || len(file.Jobs()) == 0 {
+		for i, job := range jobs {
+			names[i+1] = job.name()
+		}
+		return names
+	}
+	for _, job := range file.Jobs() {
+		instances := jobInstances(job)
+		for i := int64(0); i < instances; i++ {
+			name := job.JobName()
+			if instances > 1 {
+				name = fmt.Sprintf("%s.%d", name, i)
+			}
+			names[len(names)+1] = name
+		}
+	}
+	return names
+}
+
+// parseLogFileName extracts the metric and job number from names like kubestr_bw.1.log
+func parseLogFileName(fileName string) (string, int, bool) {
+	name, found := strings.CutPrefix(fileName, logPrefix+"_")
+	if !found {
+		return "", 0, false
+	}
+	name, found = strings.CutSuffix(name, ".log")
+	if !found {
+		return "", 0, false
+	}
+	metric, num, found := strings.Cut(name, ".")
+	if !found {
+		return "", 0, false
+	}
+	jobNum, err := strconv.Atoi(num)
+	if err != nil {
+		return "", 0, false
+	}
+	return metric, jobNum, true
+}
+
+// parseFioLog parses a fio log, lines are "time (ms), value, direction, block size, offset[, priority]"
+func parseFioLog(data string) (map[string][]FioLogSample, error) {
+	samples := map[string][]FioLogSample{}
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("malformed log line (%s)", line)
+		}
+		t, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid time in log line (%s)", line)
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value in log line (%s)", line)
+		}
+		direction, ok := fioLogDirections[strings.TrimSpace(fields[2])]
+		if !ok {
+			return nil, fmt.Errorf("invalid direction in log line (%s)", line)
+		}
+		samples[direction] = append(samples[direction], FioLogSample{TimeMS: t, Value: value})
+	}
+	return samples, nil
+}
+
+func summariseSamples(samples []FioLogSample) FioLogSummary {
+	if len(samples) == 0 {
+		return FioLogSummary{}
+	}
+	summary := FioLogSummary{Min: samples[0].Value, Max: samples[0].Value}
+	for _, s := range samples {
+		summary.Min = min(summary.Min, s.Value)
+		summary.Max = max(summary.Max, s.Value)
+		summary.Mean += float64(s.Value)
+	}
+	summary.Mean /= float64(len(samples))
+	for _, s := range samples {
+		summary.StdDev += math.Pow(float64(s.Value)-summary.Mean, 2)
+	}
+	summary.StdDev = math.Sqrt(summary.StdDev / float64(len(samples)))
+	return summary
+}
+
+// detectSustainedDrop compares the samples against the mean of the first quarter of the run.
+// A drop is sustained when a window of samples and everything after it stay below the baseline
+// by at least SustainedDropFraction.
+func detectSustainedDrop(samples []FioLogSample) *FioLogDrop {
+	if len(samples) < minDropSamples {
+		return nil
+	}
+	baselineCount := len(samples) / 4
+	baseline := meanSamples(samples[:baselineCount])
+	if baseline <= 0 {
+		return nil
+	}
+	limit := baseline * (1 - SustainedDropFraction)
+	window := max(3, len(samples)/10)
+	for i := baselineCount; i+window <= len(samples); i++ {
+		if !allBelow(samples[i:i+window], limit) {
+			continue
+		}
+		after := meanSamples(samples[i:])
+		if after >= limit {
+			continue
+		}
+		return &FioLogDrop{
+			StartMS:  samples[i].TimeMS,
+			Baseline: baseline,
+			Mean:     after,
+			Percent:  100 * (baseline - after) / baseline,
+		}
+	}
+	return nil
+}
+
+func meanSamples(samples []FioLogSample) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s.Value)
+	}
+	return sum / float64(len(samples))
+}
+
+func allBelow(samples []FioLogSample, limit float64) bool {
+	for _, s := range samples {
+		if float64(s.Value) >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteLogsCSV writes every series into its own CSV file in dir and returns the file paths
+func WriteLogsCSV(dir string, series []FioLogSeries) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "unable to create log directory (%s)", dir)
+	}
+	var paths []string
+	for _, s := range series {
+		path := filepath.Join(dir, strings.ReplaceAll(s.Name(), " ", "_")+".csv")
+		if err := writeLogCSV(path, s); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func writeLogCSV(path string, s FioLogSeries) error {
+	records := [][]string{{"time_ms", s.Metric}}
+	for _, sample := range s.Samples {
+		records = append(records, []string{strconv.FormatInt(sample.TimeMS, 10), strconv.FormatInt(sample.Value, 10)})
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create CSV file (%s)", path)
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "unable to write CSV file (%s)", path)
+	}
+	return f.Close()
+}
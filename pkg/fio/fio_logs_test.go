@@ -0,0 +1,124 @@
+package fio
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FIOTestSuite) TestLogFioArgs(c *C) {
+	c.Check(logFioArgs("/logs", 500*time.Millisecond), DeepEquals, []string{
+		"--write_bw_log=/logs/kubestr",
+		"--write_iops_log=/logs/kubestr",
+		"--write_lat_log=/logs/kubestr",
+		"--log_avg_msec=500",
+	})
+}
+
+func (s *FIOTestSuite) TestParseLogFileName(c *C) {
+	for _, tc := range []struct {
+		name   string
+		metric string
+		job    int
+		ok     bool
+	}{
+		{name: "kubestr_bw.1.log", metric: "bw", job: 1, ok: true},
+		{name: "kubestr_clat.12.log", metric: "clat", job: 12, ok: true},
+		{name: "other_bw.1.log", ok: false},
+		{name: "kubestr_bw.1.txt", ok: false},
+		{name: "kubestr_bw.log", ok: false},
+		{name: "kubestr_bw.x.log", ok: false},
+	} {
+		metric, job, ok := parseLogFileName(tc.name)
+		c.Check(ok, Equals, tc.ok)
+		c.Check(metric, Equals, tc.metric)
+		c.Check(job, Equals, tc.job)
+	}
+}
+
+func (s *FIOTestSuite) TestParseFioLog(c *C) {
+	samples, err := parseFioLog("1000, 10, 0, 4096, 0\n1000, 20, 1, 4096, 0, 0\n2000, 30, 0, 4096, 0\n\n")
+	c.Assert(err, IsNil)
+	c.Check(samples, DeepEquals, map[string][]FioLogSample{
+		"read":  {{TimeMS: 1000, Value: 10}, {TimeMS: 2000, Value: 30}},
+		"write": {{TimeMS: 1000, Value: 20}},
+	})
+	for _, data := range []string{"1000, 10", "x, 10, 0", "1000, x, 0", "1000, 10, 9"} {
+		_, err = parseFioLog(data)
+		c.Check(err, NotNil)
+	}
+}
+
+func (s *FIOTestSuite) TestParseFioLogs(c *C) {
+	jobs := []FioJobs{{JobOptions: FioJobOptions{Name: "read_iops"}}}
+	series, err := parseFioLogs(map[string]string{
+		"kubestr_bw.1.log":  "1000, 10, 0, 4096, 0\n2000, 30, 0, 4096, 0\n",
+		"kubestr_lat.2.log": "1000, 5000, 1, 4096, 0\n",
+		"fio.json":          "{}",
+	}, "", jobs, "pod")
+	c.Assert(err, IsNil)
+	c.Assert(series, HasLen, 2)
+	c.Check(series[0].Name(), Equals, "pod 2 write lat")
+	c.Check(series[1].Name(), Equals, "pod read_iops read bw")
+	c.Check(series[1].Summary, DeepEquals, FioLogSummary{Min: 10, Max: 30, Mean: 20, StdDev: 10})
+
+	_, err = parseFioLogs(map[string]string{"kubestr_bw.1.log": "garbage"}, "", jobs, "")
+	c.Check(err, NotNil)
+
+	// every instance logs separately, group_reporting merges them in the result
+	series, err = parseFioLogs(map[string]string{
+		"kubestr_bw.2.log": "1000, 10, 0, 4096, 0\n",
+		"kubestr_bw.3.log": "1000, 10, 1, 4096, 0\n",
+	}, "[global]\ngroup_reporting\n[a]\nnumjobs=2\n[b]\n", []FioJobs{{JobName: "a"}, {JobName: "b"}}, "")
+	c.Assert(err, IsNil)
+	c.Assert(series, HasLen, 2)
+	c.Check(series[0].Name(), Equals, "a.1 read bw")
+	c.Check(series[1].Name(), Equals, "b write bw")
+}
+
+func (s *FIOTestSuite) TestLogJobNames(c *C) {
+	c.Check(logJobNames("[a]\nnumjobs=3\n[b]\nname=c\n", nil), DeepEquals, map[int]string{1: "a.0", 2: "a.1", 3: "a.2", 4: "c"})
+	c.Check(logJobNames("", []FioJobs{{JobName: "a"}}), DeepEquals, map[int]string{1: "a"})
+}
+
+func (s *FIOTestSuite) TestDetectSustainedDrop(c *C) {
+	series := func(values ...int64) []FioLogSample {
+		var samples []FioLogSample
+		for i, v := range values {
+			samples = append(samples, FioLogSample{TimeMS: int64(i+1) * 1000, Value: v})
+		}
+		return samples
+	}
+	// burst credits run out after 6s
+	drop := detectSustainedDrop(series(100, 100, 100, 100, 100, 100, 40, 40, 40, 40, 40, 40))
+	c.Assert(drop, NotNil)
+	c.Check(drop.StartMS, Equals, int64(7000))
+	c.Check(drop.Baseline, Equals, float64(100))
+	c.Check(drop.Mean, Equals, float64(40))
+	c.Check(drop.Percent, Equals, float64(60))
+	c.Check(drop.Print(), Equals, "sustained drop of 60.0% from 7s (baseline 100.00, after 40.00)")
+
+	// a short dip that recovers is not sustained
+	c.Check(detectSustainedDrop(series(100, 100, 100, 40, 40, 40, 100, 100, 100, 100, 100, 100)), IsNil)
+	// steady throughput
+	c.Check(detectSustainedDrop(series(100, 90, 110, 100, 95, 105, 100, 100, 100)), IsNil)
+	// too few samples
+	c.Check(detectSustainedDrop(series(100, 100, 10, 10)), IsNil)
+}
+
+func (s *FIOTestSuite) TestWriteLogsCSV(c *C) {
+	dir := filepath.Join(c.MkDir(), "logs")
+	paths, err := WriteLogsCSV(dir, []FioLogSeries{{
+		Job:       "read_iops",
+		Metric:    "iops",
+		Direction: "read",
+		Samples:   []FioLogSample{{TimeMS: 1000, Value: 10}, {TimeMS: 2000, Value: 20}},
+	}})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{filepath.Join(dir, "read_iops_read_iops.csv")})
+	data, err := os.ReadFile(paths[0])
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "time_ms,iops\n1000,10\n2000,20\n")
+}
@@ -0,0 +1,192 @@
+package fio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// PodOptions customise the scheduling, resources and metadata of the FIO pods
+type PodOptions struct {
+	Tolerations        []v1.Toleration
+	Affinity           *v1.Affinity
+	Resources          v1.ResourceRequirements
+	PriorityClassName  string
+	RuntimeClassName   string
+	Annotations        map[string]string
+	ServiceAccountName string
+	ImagePullSecrets   []string
+	Overlay            []byte // strategic merge patch applied to the generated pod, as JSON
+}
+
+// apply sets the options on a generated pod, the overlay is merged last so it can override anything
+func (o PodOptions) apply(pod *v1.Pod) (*v1.Pod, error) {
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, o.Tolerations...)
+	if o.Affinity != nil {
+		pod.Spec.Affinity = mergeAffinity(o.Affinity.DeepCopy(), pod.Spec.Affinity)
+	}
+	for i := range pod.Spec.Containers {
+		pod.Spec.Containers[i].Resources = o.Resources
+	}
+	pod.Spec.PriorityClassName = o.PriorityClassName
+	if o.RuntimeClassName != "" {
+		pod.Spec.RuntimeClassName = &o.RuntimeClassName
+	}
+	if len(o.Annotations) > 0 {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		for k, v := range o.Annotations {
+			pod.Annotations[k] = v
+		}
+	}
+	pod.Spec.ServiceAccountName = o.ServiceAccountName
+	for _, secret := range o.ImagePullSecrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, v1.LocalObjectReference{Name: secret})
+	}
+	if len(o.Overlay) == 0 {
+		return pod, nil
+	}
+	original, err := json.Marshal(pod)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal pod")
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, o.Overlay, v1.Pod{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to apply pod overlay")
+	}
+	res := &v1.Pod{}
+	if err := json.Unmarshal(patched, res); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal patched pod")
+	}
+	return res, nil
+}
+
+// mergeAffinity adds the anti affinity that spreads the clients of a run to the requested affinity
+func mergeAffinity(affinity, clients *v1.Affinity) *v1.Affinity {
+	if clients == nil || clients.PodAntiAffinity == nil {
+		return affinity
+	}
+	if affinity.PodAntiAffinity == nil {
+		affinity.PodAntiAffinity = &v1.PodAntiAffinity{}
+	}
+	affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		clients.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	return affinity
+}
+
+// LoadPodOverlayFile reads a partial pod manifest in YAML or JSON to merge into the FIO pods
+func LoadPodOverlayFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "file reading error")
+	}
+	var pod v1.Pod
+	if err := yaml.UnmarshalStrict(data, &pod); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse pod overlay file (%s)", path)
+	}
+	overlay, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse pod overlay file (%s)", path)
+	}
+	return overlay, nil
+}
+
+// ParseToleration parses a toleration in the taint syntax of kubectl, key[=value][:effect].
+// Without a value the toleration matches any value of the key.
+func ParseToleration(s string) (v1.Toleration, error) {
+	spec, effect, _ := strings.Cut(s, ":")
+	key, value, hasValue := strings.Cut(spec, "=")
+	t := v1.Toleration{
+		Key:      key,
+		Operator: v1.TolerationOpExists,
+		Effect:   v1.TaintEffect(effect),
+	}
+	if hasValue {
+		t.Operator = v1.TolerationOpEqual
+		t.Value = value
+	}
+	if key == "" {
+		return t, fmt.Errorf("invalid toleration (%s), missing key", s)
+	}
+	switch t.Effect {
+	case "", v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+	default:
+		return t, fmt.Errorf("invalid toleration (%s), unsupported effect (%s)", s, effect)
+	}
+	return t, nil
+}
+
+// RequiredAffinity builds an affinity that requires nodes with the given labels and
+// nodes already running pods with the given labels. Node label values are a list
+// separated by "|", an empty value only requires the label to exist.
+func RequiredAffinity(nodeLabels, podLabels map[string]string) *v1.Affinity {
+	if len(nodeLabels) == 0 && len(podLabels) == 0 {
+		return nil
+	}
+	affinity := &v1.Affinity{}
+	if len(nodeLabels) > 0 {
+		term := v1.NodeSelectorTerm{}
+		for key, values := range nodeLabels {
+			req := v1.NodeSelectorRequirement{Key: key, Operator: v1.NodeSelectorOpExists}
+			if values != "" {
+				req.Operator = v1.NodeSelectorOpIn
+				req.Values = strings.Split(values, "|")
+			}
+			term.MatchExpressions = append(term.MatchExpressions, req)
+		}
+		sort.Slice(term.MatchExpressions, func(i, j int) bool { return term.MatchExpressions[i].Key < term.MatchExpressions[j].Key })
+		affinity.NodeAffinity = &v1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{term},
+			},
+		}
+	}
+	if len(podLabels) > 0 {
+		affinity.PodAffinity = &v1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: podLabels},
+				TopologyKey:   v1.LabelHostname,
+			}},
+		}
+	}
+	return affinity
+}
+
+// ResourceRequirements parses the requests and limits of the FIO container, e.g. cpu=1 and memory=1Gi
+func ResourceRequirements(requests, limits map[string]string) (v1.ResourceRequirements, error) {
+	var res v1.ResourceRequirements
+	var err error
+	if res.Requests, err = resourceList(requests); err != nil {
+		return res, errors.Wrap(err, "invalid resource requests")
+	}
+	if res.Limits, err = resourceList(limits); err != nil {
+		return res, errors.Wrap(err, "invalid resource limits")
+	}
+	return res, nil
+}
+
+func resourceList(resources map[string]string) (v1.ResourceList, error) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	list := v1.ResourceList{}
+	for name, value := range resources {
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid quantity (%s=%s)", name, value)
+		}
+		list[v1.ResourceName(name)] = q
+	}
+	return list, nil
+}
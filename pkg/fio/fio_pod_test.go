@@ -0,0 +1,117 @@
+package fio
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (s *FIOTestSuite) TestParseToleration(c *C) {
+	for _, tc := range []struct {
+		in         string
+		out        v1.Toleration
+		errChecker Checker
+	}{
+		{in: "dedicated=storage:NoSchedule", out: v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "storage", Effect: v1.TaintEffectNoSchedule}, errChecker: IsNil},
+		{in: "dedicated:NoExecute", out: v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute}, errChecker: IsNil},
+		{in: "dedicated=storage", out: v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "storage"}, errChecker: IsNil},
+		{in: "dedicated", out: v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpExists}, errChecker: IsNil},
+		{in: "=storage:NoSchedule", errChecker: NotNil},
+		{in: "dedicated:Sometimes", errChecker: NotNil},
+	} {
+		t, err := ParseToleration(tc.in)
+		c.Check(err, tc.errChecker)
+		if err == nil {
+			c.Check(t, DeepEquals, tc.out)
+		}
+	}
+}
+
+func (s *FIOTestSuite) TestRequiredAffinity(c *C) {
+	c.Assert(RequiredAffinity(nil, nil), IsNil)
+	affinity := RequiredAffinity(map[string]string{"zone": "a|b", "storage": ""}, map[string]string{"app": "db"})
+	c.Assert(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms, DeepEquals, []v1.NodeSelectorTerm{{
+		MatchExpressions: []v1.NodeSelectorRequirement{
+			{Key: "storage", Operator: v1.NodeSelectorOpExists},
+			{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a", "b"}},
+		},
+	}})
+	c.Assert(affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, DeepEquals, []v1.PodAffinityTerm{{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+		TopologyKey:   v1.LabelHostname,
+	}})
+}
+
+func (s *FIOTestSuite) TestResourceRequirements(c *C) {
+	res, err := ResourceRequirements(map[string]string{"cpu": "500m"}, map[string]string{"memory": "1Gi"})
+	c.Assert(err, IsNil)
+	c.Assert(res.Requests.Cpu().Equal(resource.MustParse("500m")), Equals, true)
+	c.Assert(res.Limits.Memory().Equal(resource.MustParse("1Gi")), Equals, true)
+	_, err = ResourceRequirements(map[string]string{"cpu": "lots"}, nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestPodOptionsApply(c *C) {
+	runtimeClass := "kata"
+	opts := PodOptions{
+		Tolerations:        []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpExists}},
+		Affinity:           RequiredAffinity(map[string]string{"storage": ""}, nil),
+		Resources:          v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+		PriorityClassName:  "high",
+		RuntimeClassName:   runtimeClass,
+		Annotations:        map[string]string{"sidecar.istio.io/inject": "false"},
+		ServiceAccountName: "fio",
+		ImagePullSecrets:   []string{"registry"},
+		Overlay:            []byte(`{"metadata":{"labels":{"team":"storage"}},"spec":{"containers":[{"name":"kubestr-fio","env":[{"name":"A","value":"b"}]}]}}`),
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{FIORunLabel: "cm"}},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: ContainerName, Image: "image"}},
+			Affinity:   clientAntiAffinity("cm"),
+		},
+	}
+	pod, err := opts.apply(pod)
+	c.Assert(err, IsNil)
+	c.Assert(pod.Spec.Tolerations, DeepEquals, opts.Tolerations)
+	c.Assert(pod.Spec.Affinity.NodeAffinity, DeepEquals, opts.Affinity.NodeAffinity)
+	c.Assert(pod.Spec.Affinity.PodAntiAffinity, DeepEquals, clientAntiAffinity("cm").PodAntiAffinity)
+	c.Assert(pod.Spec.Containers[0].Resources.Requests.Cpu().Equal(resource.MustParse("1")), Equals, true)
+	c.Assert(pod.Spec.PriorityClassName, Equals, "high")
+	c.Assert(*pod.Spec.RuntimeClassName, Equals, runtimeClass)
+	c.Assert(pod.Annotations, DeepEquals, opts.Annotations)
+	c.Assert(pod.Spec.ServiceAccountName, Equals, "fio")
+	c.Assert(pod.Spec.ImagePullSecrets, DeepEquals, []v1.LocalObjectReference{{Name: "registry"}})
+	// the overlay is merged, not replacing the generated pod
+	c.Assert(pod.Labels, DeepEquals, map[string]string{FIORunLabel: "cm", "team": "storage"})
+	c.Assert(pod.Spec.Containers[0].Image, Equals, "image")
+	c.Assert(pod.Spec.Containers[0].Env, DeepEquals, []v1.EnvVar{{Name: "A", Value: "b"}})
+
+	_, err = PodOptions{Overlay: []byte(`not json`)}.apply(&v1.Pod{})
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestLoadPodOverlayFile(c *C) {
+	file, err := os.CreateTemp("", "overlay")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Check(os.Remove(file.Name()), IsNil)
+	}()
+	_, err = file.WriteString(`spec:
+  hostNetwork: true
+`)
+	c.Assert(err, IsNil)
+	overlay, err := LoadPodOverlayFile(file.Name())
+	c.Assert(err, IsNil)
+	c.Assert(string(overlay), Equals, `{"spec":{"hostNetwork":true}}`)
+
+	_, err = file.WriteString("  unknownField: true\n")
+	c.Assert(err, IsNil)
+	_, err = LoadPodOverlayFile(file.Name())
+	c.Assert(err, NotNil)
+	_, err = LoadPodOverlayFile("nonexistantfile")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,77 @@
+package fio
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PreconditionFileName is the job file that fills the test files before the test runs
+	PreconditionFileName = "kubestr-precondition.fio"
+	// preconditionJobPrefix is prepended to the name of every precondition job
+	preconditionJobPrefix = "precondition-"
+)
+
+// preconditionLayoutOptions are the job options that decide which files a job uses,
+// copying them makes the precondition job write the files of the test job
+var preconditionLayoutOptions = []string{
+	"directory", "filename", "filename_format", "unique_filename", "nrfiles", "filesize",
+	"size", "offset", "offset_increment", "numjobs",
+}
+
+// preconditionFioJob returns a job file that sequentially fills the files of every job
+// of the test. Precondition jobs run one after the other with their own name, the
+// filename format keeps the files of the test job.
+func preconditionFioJob(config string) (string, error) {
+	file, err := ParseFioJobFile(config)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{
+		"[global]",
+		"ioengine=libaio",
+		"direct=1",
+		"rw=write",
+		"bs=1M",
+		"iodepth=32",
+		"stonewall",
+	}
+	for _, job := range file.Jobs() {
+		name := job.JobName()
+		lines = append(lines, fmt.Sprintf("[%s%s]", preconditionJobPrefix, name))
+		for _, key := range preconditionLayoutOptions {
+			if option, ok := job.Option(key); ok {
+				option.Value = strings.ReplaceAll(option.Value, "$jobname", name)
+				lines = append(lines, optionLine(option))
+			}
+		}
+		_, hasFormat := job.Option("filename_format")
+		if _, hasFilename := job.Option("filename"); !hasFormat && !hasFilename {
+			lines = append(lines, fmt.Sprintf("filename_format=%s.$jobnum.$filenum", name))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// optionLine renders an option as a line of a job file
+func optionLine(option FioOption) string {
+	if option.Value == "" {
+		return option.Key
+	}
+	return fmt.Sprintf("%s=%s", option.Key, option.Value)
+}
+
+// preconditionClients returns the clients filling the volume before the test, without the
+// log options of the test. Clients sharing a file or a block device fill it once.
+func preconditionClients(clients []fioClient, args *RunFIOArgs) []fioClient {
+	count := len(clients)
+	if args.SharedFile || args.isBlock() {
+		count = 1
+	}
+	pre := make([]fioClient, 0, count)
+	for i, client := range clients[:count] {
+		fioArgs, _ := clientFioArgs(args, i, false)
+		pre = append(pre, fioClient{pod: client.pod, fioArgs: fioArgs})
+	}
+	return pre
+}
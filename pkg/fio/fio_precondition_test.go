@@ -0,0 +1,77 @@
+package fio
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func (s *FIOTestSuite) TestPreconditionFioJob(c *C) {
+	config, err := preconditionFioJob(`[global]
+size=2G
+direct=1
+[job1]
+name=read_iops
+rw=randread
+time_based
+runtime=15s
+[shared]
+filename=data
+rw=randwrite
+[formatted]
+filename_format=$jobname/$filenum
+nrfiles=4
+numjobs=2
+rw=write
+`)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, `[global]
+ioengine=libaio
+direct=1
+rw=write
+bs=1M
+iodepth=32
+stonewall
+[precondition-read_iops]
+size=2G
+filename_format=read_iops.$jobnum.$filenum
+[precondition-shared]
+filename=data
+size=2G
+[precondition-formatted]
+filename_format=formatted/$filenum
+nrfiles=4
+size=2G
+numjobs=2
+`)
+
+	_, err = preconditionFioJob("[job\nrw=write")
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestPreconditionClients(c *C) {
+	clients := []fioClient{
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, fioArgs: []string{"--directory", "/dataset/client-0", "--write_bw_log"}},
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b"}}, fioArgs: []string{"--directory", "/dataset/client-1", "--write_bw_log"}},
+	}
+	pre := preconditionClients(clients, &RunFIOArgs{Clients: 2})
+	c.Assert(pre, HasLen, 2)
+	c.Assert(pre[0].fioArgs, DeepEquals, []string{"--directory", VolumeMountPath + "/client-0"})
+	c.Assert(pre[1].fioArgs, DeepEquals, []string{"--directory", VolumeMountPath + "/client-1"})
+
+	pre = preconditionClients(clients, &RunFIOArgs{Clients: 2, VolumeMode: v1.PersistentVolumeBlock})
+	c.Assert(pre, HasLen, 1)
+	c.Assert(pre[0].pod.Name, Equals, "a")
+	c.Assert(pre[0].fioArgs, DeepEquals, []string{"--filename", VolumeDevicePath})
+}
+
+func (s *FIOTestSuite) TestLoadConfigMapPrecondition(c *C) {
+	stepper := &fioStepper{cli: fake.NewSimpleClientset()}
+	cm, err := stepper.loadConfigMap(context.Background(), &RunFIOArgs{Namespace: "ns", FIOJobName: "latency", Precondition: true})
+	c.Assert(err, IsNil)
+	c.Assert(cm.Data, HasLen, 2)
+	c.Assert(cm.Data[PreconditionFileName], Matches, "(?s)\\[global\\].*\\[precondition-.*")
+}
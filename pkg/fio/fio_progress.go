@@ -0,0 +1,110 @@
+package fio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FioProgress is a snapshot of a running FIO job, taken every status interval
+type FioProgress struct {
+	Pod  string           `json:"pod,omitempty"`
+	Jobs []FioJobProgress `json:"jobs"`
+}
+
+// FioJobProgress is the throughput of a job so far. Bandwidth is in KiB/s.
+type FioJobProgress struct {
+	Name      string        `json:"name"`
+	ReadIOPS  float64       `json:"readIOPS"`
+	ReadBW    int64         `json:"readBW"`
+	WriteIOPS float64       `json:"writeIOPS"`
+	WriteBW   int64         `json:"writeBW"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Eta       time.Duration `json:"eta"`
+}
+
+// newFioProgress builds a progress snapshot from an intermediate fio result
+func newFioProgress(pod string, result FioResult) FioProgress {
+	progress := FioProgress{Pod: pod}
+	for _, job := range result.Jobs {
+		progress.Jobs = append(progress.Jobs, FioJobProgress{
+			Name:      job.name(),
+			ReadIOPS:  job.Read.Iops,
+			ReadBW:    job.Read.BW,
+			WriteIOPS: job.Write.Iops,
+			WriteBW:   job.Write.BW,
+			Elapsed:   time.Duration(job.Elapsed) * time.Second,
+			Eta:       time.Duration(job.Eta) * time.Second,
+		})
+	}
+	return progress
+}
+
+func (p FioProgress) Print() string {
+	var jobs []string
+	for _, job := range p.Jobs {
+		jobs = append(jobs, job.Print())
+	}
+	res := strings.Join(jobs, "; ")
+	if p.Pod != "" {
+		res = fmt.Sprintf("[%s] %s", p.Pod, res)
+	}
+	return res
+}
+
+func (j FioJobProgress) Print() string {
+	return fmt.Sprintf("%s: read IOPS=%.0f BW(KiB/s)=%d, write IOPS=%.0f BW(KiB/s)=%d, elapsed=%s eta=%s",
+		j.Name, j.ReadIOPS, j.ReadBW, j.WriteIOPS, j.WriteBW, j.Elapsed, j.Eta)
+}
+
+// NewProgressPrinter returns a progress callback that renders every snapshot as a line of w
+func NewProgressPrinter(w io.Writer) func(FioProgress) {
+	var mu sync.Mutex
+	return func(p FioProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = fmt.Fprintln(w, p.Print())
+	}
+}
+
+// NewProgressEventWriter returns a progress callback that writes every snapshot to w as a line of JSON
+func NewProgressEventWriter(w io.Writer) func(FioProgress) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(p FioProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(p)
+	}
+}
+
+// decodeFioStream decodes the JSON results fio writes to its output one after another.
+// Every result is passed to progress and the last one is returned. The reader is drained
+// on error so the writer never blocks.
+func decodeFioStream(r io.Reader, progress func(FioResult)) (FioResult, error) {
+	var last FioResult
+	found := false
+	dec := json.NewDecoder(r)
+	for {
+		var out FioResult
+		err := dec.Decode(&out)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_, _ = io.Copy(io.Discard, r)
+			return last, err
+		}
+		last, found = out, true
+		if progress != nil {
+			progress(out)
+		}
+	}
+	if !found {
+		return last, fmt.Errorf("no fio output")
+	}
+	return last, nil
+}
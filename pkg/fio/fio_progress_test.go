@@ -0,0 +1,72 @@
+package fio
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (s *FIOTestSuite) TestDecodeFioStream(c *C) {
+	stream := `{"jobs":[{"jobname":"a","eta":8,"elapsed":2,"read":{"iops":10,"bw":40}}]}
+{"jobs":[{"jobname":"a","eta":0,"elapsed":10,"read":{"iops":12,"bw":48}}]}`
+	var progress []FioProgress
+	out, err := decodeFioStream(strings.NewReader(stream), func(r FioResult) {
+		progress = append(progress, newFioProgress("", r))
+	})
+	c.Assert(err, IsNil)
+	c.Assert(out.Jobs[0].Read.BW, Equals, int64(48))
+	c.Assert(progress, DeepEquals, []FioProgress{
+		{Jobs: []FioJobProgress{{Name: "a", ReadIOPS: 10, ReadBW: 40, Elapsed: 2 * time.Second, Eta: 8 * time.Second}}},
+		{Jobs: []FioJobProgress{{Name: "a", ReadIOPS: 12, ReadBW: 48, Elapsed: 10 * time.Second}}},
+	})
+
+	_, err = decodeFioStream(strings.NewReader(""), nil)
+	c.Assert(err, NotNil)
+	_, err = decodeFioStream(strings.NewReader(`{"jobs":[]} not json`), nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestProgressPrint(c *C) {
+	p := FioProgress{
+		Pod:  "pod-1",
+		Jobs: []FioJobProgress{{Name: "a", ReadIOPS: 10, ReadBW: 40, Elapsed: 2 * time.Second, Eta: 8 * time.Second}},
+	}
+	c.Check(p.Print(), Equals, "[pod-1] a: read IOPS=10 BW(KiB/s)=40, write IOPS=0 BW(KiB/s)=0, elapsed=2s eta=8s")
+
+	var buf bytes.Buffer
+	NewProgressPrinter(&buf)(p)
+	c.Check(buf.String(), Equals, p.Print()+"\n")
+	buf.Reset()
+	NewProgressEventWriter(&buf)(p)
+	c.Check(buf.String(), Equals, `{"pod":"pod-1","jobs":[{"name":"a","readIOPS":10,"readBW":40,"writeIOPS":0,"writeBW":0,"elapsed":2000000000,"eta":8000000000}]}`+"\n")
+}
+
+func (s *FIOTestSuite) TestRunClientsProgress(c *C) {
+	stepper := &fakeFioStepper{rFIOout: FioResult{Jobs: []FioJobs{{JobName: "a"}}}}
+	runner := &FIOrunner{fioSteps: stepper}
+	var progress []FioProgress
+	args := &RunFIOArgs{
+		Namespace:      "ns",
+		StatusInterval: time.Second,
+		OnProgress:     func(p FioProgress) { progress = append(progress, p) },
+	}
+	clients := []fioClient{
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1"}}},
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p2"}}},
+	}
+	_, err := runner.runClients(context.Background(), clients, "job", args)
+	c.Assert(err, IsNil)
+	c.Assert(progress, HasLen, 2)
+	pods := []string{progress[0].Pod, progress[1].Pod}
+	sort.Strings(pods)
+	c.Assert(pods, DeepEquals, []string{"p1", "p2"})
+	for _, opts := range stepper.rFIOOpts {
+		c.Assert(opts.statusInterval, Equals, time.Second)
+	}
+}
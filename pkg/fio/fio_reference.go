@@ -0,0 +1,211 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ReferenceMedium is a node local volume the test runs against, in the same pods, to tell
+// the performance of the storage apart from the performance of the node
+type ReferenceMedium string
+
+const (
+	// ReferenceDisk is an emptyDir on the ephemeral disk of the node
+	ReferenceDisk ReferenceMedium = "disk"
+	// ReferenceMemory is a memory backed emptyDir
+	ReferenceMemory ReferenceMedium = "memory"
+	// ReferenceMountPathFmt is the format of the path the reference volumes are mounted at
+	ReferenceMountPathFmt = "/reference-%s"
+	// DefaultMemoryReferenceSize is the size limit of the memory reference unless another is given
+	DefaultMemoryReferenceSize = "1Gi"
+	// MemoryReferenceFileName is the job file run against the memory reference, the test
+	// with direct I/O turned off since memory backed volumes may not support it
+	MemoryReferenceFileName = "kubestr-memory-reference.fio"
+)
+
+func (m ReferenceMedium) Validate() error {
+	switch m {
+	case ReferenceDisk, ReferenceMemory:
+		return nil
+	}
+	return fmt.Errorf("unsupported reference (%s), options(%s, %s)", m, ReferenceDisk, ReferenceMemory)
+}
+
+func (m ReferenceMedium) volumeName() string {
+	return "reference-" + string(m)
+}
+
+func (m ReferenceMedium) mountPath() string {
+	return fmt.Sprintf(ReferenceMountPathFmt, m)
+}
+
+// hasReference reports whether the test also runs against the reference medium
+func (a *RunFIOArgs) hasReference(medium ReferenceMedium) bool {
+	for _, m := range a.References {
+		if m == medium {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryReferenceSize is the size limit of the memory reference, it was checked by Validate
+func (a *RunFIOArgs) memoryReferenceSize() resource.Quantity {
+	if size, err := resource.ParseQuantity(a.ReferenceSize); err == nil {
+		return size
+	}
+	return resource.MustParse(DefaultMemoryReferenceSize)
+}
+
+// memoryReferenceFioJob returns the test with direct I/O turned off for every job. Jobs whose
+// files don't fit in the limit are shrunk, each in proportion to its size.
+func memoryReferenceFioJob(config string, limit int64) (string, error) {
+	file, err := ParseFioJobFile(config)
+	if err != nil {
+		return "", err
+	}
+	total, err := jobsSize(file.Jobs())
+	if err != nil {
+		return "", err
+	}
+	return appendJobOptions(config, func(job FioJob) []string {
+		options := []string{"direct=0"}
+		if size, _ := jobSize(job); total > limit && size > 0 {
+			options = append(options, fmt.Sprintf("size=%d", shrunkJobSize(size, total, limit, jobInstances(job))))
+		}
+		return options
+	})
+}
+
+// shrunkJobSize is the size of every instance of a job taking up size of total, once the total
+// is shrunk to the limit. It is rounded down to whole MiB and is at least 1MiB.
+func shrunkJobSize(size, total, limit, instances int64) int64 {
+	const mib = 1 << 20
+	shrunk := int64(float64(size) / float64(total) * float64(limit) / float64(instances))
+	return max(shrunk/mib*mib, mib)
+}
+
+// addReferenceVolumes mounts an emptyDir for every reference medium into the FIO container,
+// the memory reference is limited to the size the jobs were checked against
+func addReferenceVolumes(pod *v1.Pod, args *RunFIOArgs) {
+	for _, medium := range args.References {
+		source := &v1.EmptyDirVolumeSource{}
+		if medium == ReferenceMemory {
+			size := args.memoryReferenceSize()
+			source.Medium = v1.StorageMediumMemory
+			source.SizeLimit = &size
+		}
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name:         medium.volumeName(),
+			VolumeSource: v1.VolumeSource{EmptyDir: source},
+		})
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name != ContainerName {
+				continue
+			}
+			pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, v1.VolumeMount{
+				Name:      medium.volumeName(),
+				MountPath: medium.mountPath(),
+			})
+		}
+	}
+}
+
+// FioReference is the result of the test against a node local volume, with the result
+// of the PVC relative to it
+type FioReference struct {
+	Medium ReferenceMedium     `json:"medium"`
+	Result FioResult           `json:"result,omitempty"`
+	Ratios []FioReferenceRatio `json:"ratios,omitempty"`
+	Error  string              `json:"error,omitempty"`
+}
+
+func (r FioReference) Print() string {
+	res := fmt.Sprintf("Node local %s reference:\n", r.Medium)
+	if r.Error != "" {
+		return res + fmt.Sprintf("  failed: %s\n", r.Error)
+	}
+	for _, ratio := range r.Ratios {
+		res += fmt.Sprintf("  %s\n", ratio.Print())
+	}
+	return res
+}
+
+// FioReferenceRatio is the throughput of a job on the PVC divided by its throughput on the
+// reference, a ratio is 0 when the reference didn't measure it
+type FioReferenceRatio struct {
+	Job       string  `json:"job"`
+	ReadIOPS  float64 `json:"readIOPS,omitempty"`
+	ReadBW    float64 `json:"readBW,omitempty"`
+	WriteIOPS float64 `json:"writeIOPS,omitempty"`
+	WriteBW   float64 `json:"writeBW,omitempty"`
+}
+
+func (r FioReferenceRatio) Print() string {
+	res := r.Job + ":"
+	if r.ReadIOPS != 0 || r.ReadBW != 0 {
+		res += fmt.Sprintf(" read IOPS=%.2fx BW=%.2fx", r.ReadIOPS, r.ReadBW)
+	}
+	if r.WriteIOPS != 0 || r.WriteBW != 0 {
+		res += fmt.Sprintf(" write IOPS=%.2fx BW=%.2fx", r.WriteIOPS, r.WriteBW)
+	}
+	return res
+}
+
+// referenceRatios divides the throughput of every job on the PVC by its throughput on the reference
+func referenceRatios(pvc, reference FioResult) []FioReferenceRatio {
+	refJobs := map[string]FioJobs{}
+	for _, job := range reference.Grouped().Jobs {
+		refJobs[job.name()] = job
+	}
+	var ratios []FioReferenceRatio
+	for _, job := range pvc.Grouped().Jobs {
+		ref, ok := refJobs[job.name()]
+		if !ok {
+			continue
+		}
+		ratios = append(ratios, FioReferenceRatio{
+			Job:       job.name(),
+			ReadIOPS:  ratio(job.Read.Iops, ref.Read.Iops),
+			ReadBW:    ratio(float64(job.Read.BW), float64(ref.Read.BW)),
+			WriteIOPS: ratio(job.Write.Iops, ref.Write.Iops),
+			WriteBW:   ratio(float64(job.Write.BW), float64(ref.Write.BW)),
+		})
+	}
+	return ratios
+}
+
+func ratio(value, reference float64) float64 {
+	if reference == 0 {
+		return 0
+	}
+	return value / reference
+}
+
+// runReference runs the test in the pods of the clients against a reference volume. A failing
+// reference doesn't fail the run, the error is reported with the reference.
+func (f *FIOrunner) runReference(ctx context.Context, clients []fioClient, testFileName string, medium ReferenceMedium, pvcResult FioResult, args *RunFIOArgs) FioReference {
+	fmt.Printf("Running FIO test (%s) on the node local %s reference\n", testFileName, medium)
+	reference := FioReference{Medium: medium}
+	refClients := make([]fioClient, 0, len(clients))
+	for _, client := range clients {
+		refClients = append(refClients, fioClient{pod: client.pod, fioArgs: []string{"--directory", medium.mountPath()}})
+	}
+	if medium == ReferenceMemory {
+		testFileName = MemoryReferenceFileName
+	}
+	results, err := f.runClients(ctx, refClients, testFileName, args)
+	if err != nil {
+		reference.Error = err.Error()
+		return reference
+	}
+	reference.Result = results[0].Result
+	if len(results) > 1 {
+		reference.Result = aggregateFioResults(results)
+	}
+	reference.Ratios = referenceRatios(pvcResult, reference.Result)
+	return reference
+}
@@ -0,0 +1,97 @@
+package fio
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func (s *FIOTestSuite) TestReferenceMediumValidate(c *C) {
+	c.Assert(ReferenceDisk.Validate(), IsNil)
+	c.Assert(ReferenceMemory.Validate(), IsNil)
+	c.Assert(ReferenceMedium("ssd").Validate(), NotNil)
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", References: []ReferenceMedium{"ssd"}}).Validate(), NotNil)
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", References: []ReferenceMedium{ReferenceMemory}, ReferenceSize: "4Gi"}).Validate(), IsNil)
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", References: []ReferenceMedium{ReferenceMemory}, ReferenceSize: "0"}).Validate(), NotNil)
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", References: []ReferenceMedium{ReferenceDisk}, VolumeMode: v1.PersistentVolumeBlock}).Validate(), NotNil)
+}
+
+func (s *FIOTestSuite) TestMemoryReferenceFioJob(c *C) {
+	config, err := memoryReferenceFioJob("[global]\ndirect=1\nsize=1G\n[a]\nrw=read\n\n[b]\nrw=write\n", 4<<30)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, "[global]\ndirect=1\nsize=1G\n[a]\nrw=read\ndirect=0\n\n[b]\nrw=write\ndirect=0\n")
+
+	// jobs that don't fit are shrunk in proportion to their size
+	config, err = memoryReferenceFioJob("[global]\nsize=1G\n[a]\nnumjobs=2\n\n[b]\nsize=2G\n", 1<<30)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, "[global]\nsize=1G\n[a]\nnumjobs=2\ndirect=0\nsize=268435456\n\n[b]\nsize=2G\ndirect=0\nsize=536870912\n")
+	size, err := memoryReferenceSize("[global]\nsize=1G\n[a]\nnumjobs=2\n\n[b]\nsize=2G\n", 1<<30)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(1<<30))
+
+	cm, err := (&fioStepper{cli: fake.NewSimpleClientset()}).loadConfigMap(context.Background(), &RunFIOArgs{
+		FIOJobName: DefaultFIOJob,
+		References: []ReferenceMedium{ReferenceMemory},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cm.Data[MemoryReferenceFileName], Matches, "(?s).*direct=0.*")
+	limit := resource.MustParse(DefaultMemoryReferenceSize)
+	size, err = memoryReferenceSize(cm.Data[DefaultFIOJob], limit.Value())
+	c.Assert(err, IsNil)
+	c.Assert(size <= limit.Value(), Equals, true)
+	name, err := fioTestFilename(cm.Data)
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, DefaultFIOJob)
+}
+
+func (s *FIOTestSuite) TestAddReferenceVolumes(c *C) {
+	pod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "sidecar"}, {Name: ContainerName}}}}
+	addReferenceVolumes(pod, &RunFIOArgs{References: []ReferenceMedium{ReferenceDisk, ReferenceMemory}})
+	limit := resource.MustParse(DefaultMemoryReferenceSize)
+	c.Assert(pod.Spec.Volumes, DeepEquals, []v1.Volume{
+		{Name: "reference-disk", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		{Name: "reference-memory", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumMemory, SizeLimit: &limit}}},
+	})
+	c.Assert(pod.Spec.Containers[0].VolumeMounts, HasLen, 0)
+	c.Assert(pod.Spec.Containers[1].VolumeMounts, DeepEquals, []v1.VolumeMount{
+		{Name: "reference-disk", MountPath: "/reference-disk"},
+		{Name: "reference-memory", MountPath: "/reference-memory"},
+	})
+}
+
+func (s *FIOTestSuite) TestRunReference(c *C) {
+	stepper := &fakeFioStepper{rFIOout: FioResult{Jobs: []FioJobs{{JobName: "read", Read: FioStats{Iops: 1000}}}}}
+	runner := &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	clients := []fioClient{{pod: &v1.Pod{}}}
+	args := &RunFIOArgs{References: []ReferenceMedium{ReferenceDisk, ReferenceMemory}}
+	disk := runner.runReference(context.Background(), clients, "testfile.fio", ReferenceDisk, FioResult{}, args)
+	c.Assert(disk.Error, Equals, "")
+	memory := runner.runReference(context.Background(), clients, "testfile.fio", ReferenceMemory, FioResult{}, args)
+	c.Assert(memory.Error, Equals, "")
+	// the memory reference runs the test without direct I/O
+	c.Assert(stepper.rFIOExpFNs, DeepEquals, []string{"testfile.fio", MemoryReferenceFileName})
+}
+
+func (s *FIOTestSuite) TestReferenceRatios(c *C) {
+	pvc := FioResult{Jobs: []FioJobs{
+		{JobName: "read", Read: FioStats{Iops: 500, BW: 2000}},
+		{JobName: "write", Write: FioStats{Iops: 100, BW: 400}},
+		{JobName: "pvc-only", Write: FioStats{Iops: 100, BW: 400}},
+	}}
+	reference := FioResult{Jobs: []FioJobs{
+		{JobName: "read", Read: FioStats{Iops: 1000, BW: 4000}},
+		{JobName: "write", Write: FioStats{Iops: 400, BW: 1600}},
+	}}
+	ratios := referenceRatios(pvc, reference)
+	c.Assert(ratios, DeepEquals, []FioReferenceRatio{
+		{Job: "read", ReadIOPS: 0.5, ReadBW: 0.5},
+		{Job: "write", WriteIOPS: 0.25, WriteBW: 0.25},
+	})
+	c.Assert(FioReference{Medium: ReferenceDisk, Ratios: ratios}.Print(), Equals,
+		"Node local disk reference:\n  read: read IOPS=0.50x BW=0.50x\n  write: write IOPS=0.25x BW=0.25x\n")
+	c.Assert(FioReference{Medium: ReferenceMemory, Error: "no space left"}.Print(), Equals,
+		"Node local memory reference:\n  failed: no space left\n")
+}
@@ -0,0 +1,148 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxCV is the coefficient of variation above which a metric of repeated runs is flagged
+const DefaultMaxCV = 0.1
+
+// repeatMetrics are the metrics summarised over repeated runs
+var repeatMetrics = []ThresholdMetric{ThresholdIOPS, ThresholdBW, ThresholdLatMean, ThresholdLatP99}
+
+// FioRepeats is the result of every run of a repeated test and a summary of their metrics
+type FioRepeats struct {
+	Iterations []FioResult      `json:"iterations"`
+	Stats      []FioMetricStats `json:"stats"`
+	MaxCV      float64          `json:"maxCV"`
+}
+
+func (r FioRepeats) Print() string {
+	res := fmt.Sprintf("Repeated %d times:\n", len(r.Iterations))
+	for _, s := range r.Stats {
+		res += fmt.Sprintf("  %s\n", s.Print())
+	}
+	return res
+}
+
+// HighVariance returns the metrics whose coefficient of variation exceeds MaxCV
+func (r FioRepeats) HighVariance() []FioMetricStats {
+	var stats []FioMetricStats
+	for _, s := range r.Stats {
+		if s.CV > r.MaxCV {
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
+
+// FioMetricStats summarises a metric of a job and direction over repeated runs.
+// Values are in the units thresholds use: IOPS, bytes per second and nanoseconds.
+type FioMetricStats struct {
+	Job       string          `json:"job"`
+	Direction string          `json:"direction"`
+	Metric    ThresholdMetric `json:"metric"`
+	Samples   int             `json:"samples"`
+	Mean      float64         `json:"mean"`
+	StdDev    float64         `json:"stddev"`
+	Min       float64         `json:"min"`
+	Max       float64         `json:"max"`
+	CV        float64         `json:"cv"` // standard deviation relative to the mean
+}
+
+func (s FioMetricStats) Print() string {
+	t := Threshold{Metric: s.Metric}
+	return fmt.Sprintf("%s %s %s: mean=%s stddev=%s min=%s max=%s cv=%.1f%%", s.Job, s.Direction, s.Metric,
+		t.formatValue(s.Mean), t.formatValue(s.StdDev), t.formatValue(s.Min), t.formatValue(s.Max), s.CV*100)
+}
+
+// repeatStats summarises every metric of every job and direction of the first run over all runs,
+// clones of a job with numjobs are merged first
+func repeatStats(iterations []FioResult) []FioMetricStats {
+	if len(iterations) == 0 {
+		return nil
+	}
+	grouped := make([]FioResult, 0, len(iterations))
+	for _, it := range iterations {
+		grouped = append(grouped, it.Grouped())
+	}
+	var stats []FioMetricStats
+	for _, job := range grouped[0].Jobs {
+		for _, direction := range fioDirections {
+			if !job.stats(direction).active() {
+				continue
+			}
+			for _, metric := range repeatMetrics {
+				if !reported(metric, direction) {
+					continue
+				}
+				var values []float64
+				for _, it := range grouped {
+					if v, ok := metricValue(it, job.name(), direction, metric); ok {
+						values = append(values, v)
+					}
+				}
+				if len(values) == 0 {
+					continue
+				}
+				s := summarise(values)
+				s.Job, s.Direction, s.Metric = job.name(), direction, metric
+				stats = append(stats, s)
+			}
+		}
+	}
+	return stats
+}
+
+// metricValue looks up a metric of a job and direction in a grouped result
+func metricValue(result FioResult, jobName, direction string, metric ThresholdMetric) (float64, bool) {
+	for _, job := range result.Jobs {
+		if job.name() == jobName {
+			return Threshold{Metric: metric}.value(job.stats(direction))
+		}
+	}
+	return 0, false
+}
+
+// summarise computes the mean, sample standard deviation, range and coefficient of variation of values
+func summarise(values []float64) FioMetricStats {
+	s := FioMetricStats{Samples: len(values), Min: values[0], Max: values[0]}
+	for _, v := range values {
+		s.Mean += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	s.Mean /= float64(len(values))
+	if len(values) > 1 {
+		var sq float64
+		for _, v := range values {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(sq / float64(len(values)-1))
+	}
+	if s.Mean != 0 {
+		s.CV = s.StdDev / s.Mean
+	}
+	return s
+}
+
+// pause waits between repeated runs, returning early when the run is cancelled
+func pause(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	fmt.Printf("Pausing for %s\n", d)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "FIO was interrupted")
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package fio
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func (s *FIOTestSuite) TestSummarise(c *C) {
+	stats := summarise([]float64{90, 100, 110})
+	c.Assert(stats.Samples, Equals, 3)
+	c.Assert(stats.Mean, Equals, 100.0)
+	c.Assert(stats.StdDev, Equals, 10.0)
+	c.Assert(stats.Min, Equals, 90.0)
+	c.Assert(stats.Max, Equals, 110.0)
+	c.Assert(stats.CV, Equals, 0.1)
+
+	stats = summarise([]float64{0})
+	c.Assert(stats.StdDev, Equals, 0.0)
+	c.Assert(stats.CV, Equals, 0.0)
+}
+
+func (s *FIOTestSuite) TestRepeatStats(c *C) {
+	iteration := func(readIOPS float64, readBW int64) FioResult {
+		return FioResult{Jobs: []FioJobs{{
+			JobName: "read",
+			Read:    FioStats{Iops: readIOPS, BWBytes: readBW, ClatNs: FioNS{N: 1, Mean: 1000, Percentile: map[string]float64{"99.000000": 5000}}},
+		}}}
+	}
+	repeats := FioRepeats{
+		Iterations: []FioResult{iteration(1000, 4096000), iteration(1000, 4096000), iteration(1600, 4096000)},
+		MaxCV:      DefaultMaxCV,
+	}
+	repeats.Stats = repeatStats(repeats.Iterations)
+	c.Assert(repeats.Stats, HasLen, 4)
+	c.Assert(repeats.Stats[0].Job, Equals, "read")
+	c.Assert(repeats.Stats[0].Direction, Equals, "read")
+	c.Assert(repeats.Stats[0].Metric, Equals, ThresholdIOPS)
+	c.Assert(repeats.Stats[0].Mean, Equals, 1200.0)
+	c.Assert(repeats.Stats[0].Min, Equals, 1000.0)
+	c.Assert(repeats.Stats[0].Max, Equals, 1600.0)
+	c.Assert(repeats.Stats[1].Metric, Equals, ThresholdBW)
+	c.Assert(repeats.Stats[1].CV, Equals, 0.0)
+	c.Assert(repeats.Stats[3].Metric, Equals, ThresholdLatP99)
+	c.Assert(repeats.Stats[3].Mean, Equals, 5000.0)
+
+	high := repeats.HighVariance()
+	c.Assert(high, HasLen, 1)
+	c.Assert(high[0].Metric, Equals, ThresholdIOPS)
+	c.Assert(high[0].Print(), Equals, "read read iops: mean=1200.00 stddev=346.41 min=1000.00 max=1600.00 cv=28.9%")
+	c.Assert(repeatStats(nil), IsNil)
+
+	// fio only reports the latency of syncs
+	fsync := FioResult{Jobs: []FioJobs{{
+		JobName: "fsync",
+		Sync:    FioStats{TotalIos: 10, LatNs: FioNS{N: 10, Mean: 2000}},
+	}}}
+	stats := repeatStats([]FioResult{fsync, fsync})
+	c.Assert(stats, HasLen, 1)
+	c.Assert(stats[0].Direction, Equals, "sync")
+	c.Assert(stats[0].Metric, Equals, ThresholdLatMean)
+}
+
+func (s *FIOTestSuite) TestPause(c *C) {
+	c.Assert(pause(context.Background(), 0), IsNil)
+	c.Assert(pause(context.Background(), time.Millisecond), IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(pause(ctx, time.Hour), NotNil)
+}
+
+func (s *FIOTestSuite) TestRunFioHelperRepeat(c *C) {
+	stepper := &fakeFioStepper{
+		lcmConfigMap: &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "CM1"},
+			Data:       map[string]string{"testfile.fio": "testfiledata"},
+		},
+		cPVC:    &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "PVC"}},
+		cPod:    &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "Pod"}},
+		rFIOout: FioResult{Jobs: []FioJobs{{JobName: "read", Read: FioStats{Iops: 1000, BW: 4000}}}},
+	}
+	runner := &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	res, err := runner.RunFioHelper(context.Background(), &RunFIOArgs{
+		StorageClass: "sc",
+		Size:         "100Gi",
+		Namespace:    "foo",
+		Repeat:       3,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CPOD", "RFIOC", "RFIOC", "RFIOC", "DPOD", "DPVC", "DCM"})
+	c.Assert(res.Repeats, NotNil)
+	c.Assert(res.Repeats.Iterations, HasLen, 3)
+	c.Assert(res.Repeats.MaxCV, Equals, DefaultMaxCV)
+	c.Assert(res.Repeats.HighVariance(), HasLen, 0)
+	c.Assert(res.Result, DeepEquals, stepper.rFIOout)
+
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", Repeat: -1}).Validate(), NotNil)
+
+	// an explicit 0 flags any variation
+	zero, negative := 0.0, -0.1
+	c.Assert((&RunFIOArgs{RepeatMaxCV: &zero}).maxCV(), Equals, 0.0)
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", RepeatMaxCV: &zero}).Validate(), IsNil)
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", RepeatMaxCV: &negative}).Validate(), NotNil)
+}
@@ -0,0 +1,112 @@
+package fio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// SavedRun is a FIO run read back from the output of an earlier run
+type SavedRun struct {
+	TestName string
+	Result   *RunFIOResult
+}
+
+// savedTestOutput is the part of a kubestr test output a saved run is read from
+type savedTestOutput struct {
+	TestName string
+	Raw      json.RawMessage
+}
+
+// LoadSavedRuns reads the runs saved with kubestr fio -o json. The file holds the test outputs
+// kubestr wrote, outputs without fio results such as a comparison summary are skipped.
+// A single run result object is accepted as well.
+func LoadSavedRuns(path string) ([]SavedRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read saved results (%s)", path)
+	}
+	runs, err := parseSavedRuns(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse saved results (%s)", path)
+	}
+	return runs, nil
+}
+
+func parseSavedRuns(data []byte) ([]SavedRun, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		result := &RunFIOResult{}
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, err
+		}
+		if !result.hasJobs() {
+			return nil, fmt.Errorf("no fio jobs found")
+		}
+		return []SavedRun{{TestName: "FIO test results", Result: result}}, nil
+	}
+	var outputs []savedTestOutput
+	if err := json.Unmarshal(data, &outputs); err != nil {
+		return nil, err
+	}
+	var runs []SavedRun
+	for _, output := range outputs {
+		if len(output.Raw) == 0 || output.Raw[0] != '{' {
+			continue
+		}
+		result := &RunFIOResult{}
+		if err := json.Unmarshal(output.Raw, result); err != nil {
+			return nil, errors.Wrapf(err, "test (%s)", output.TestName)
+		}
+		if result.hasJobs() {
+			runs = append(runs, SavedRun{TestName: output.TestName, Result: result})
+		}
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no fio results found")
+	}
+	return runs, nil
+}
+
+// LoadFioOutput reads the JSON output of a plain fio run, e.g. from a bare-metal host, into a run result
+func LoadFioOutput(path string) (*RunFIOResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read fio output (%s)", path)
+	}
+	// fio prints notes and warnings before the report when stderr and stdout were captured together
+	if i := bytes.IndexByte(data, '{'); i >= 0 {
+		data = data[i:]
+	}
+	out, err := decodeFioStream(bytes.NewReader(data), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse fio output (%s)", path)
+	}
+	result := &RunFIOResult{Result: out}
+	if !result.hasJobs() {
+		return nil, fmt.Errorf("no fio jobs found in (%s)", path)
+	}
+	return result, nil
+}
+
+// ApplyThresholds evaluates the thresholds against the saved result, replacing the thresholds it was
+// evaluated against when it ran. Without thresholds the saved evaluation is kept.
+func (r *RunFIOResult) ApplyThresholds(thresholds []Threshold) error {
+	if len(thresholds) == 0 {
+		return nil
+	}
+	for _, t := range thresholds {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	r.Thresholds = EvaluateThresholds(r.Result, thresholds)
+	return nil
+}
+
+func (r *RunFIOResult) hasJobs() bool {
+	return len(r.Result.Jobs) > 0
+}
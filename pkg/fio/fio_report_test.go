@@ -0,0 +1,77 @@
+package fio
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+	sv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (s *FIOTestSuite) TestLoadSavedRuns(c *C) {
+	var parsed FioResult
+	c.Assert(json.Unmarshal([]byte(parsableFioOutput), &parsed), IsNil)
+	run := &RunFIOResult{Size: "10Gi", StorageClass: &sv1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}}, Result: parsed}
+	raw, err := json.Marshal(run)
+	c.Assert(err, IsNil)
+
+	dir := c.MkDir()
+	// the output of a comparison has a summary without fio results
+	saved := `[{"TestName": "FIO test results (fast)", "Status": [], "Raw": ` + string(raw) + `},
+		{"TestName": "FIO comparison", "Status": []}]`
+	path := filepath.Join(dir, "result.json")
+	c.Assert(os.WriteFile(path, []byte(saved), 0644), IsNil)
+	runs, err := LoadSavedRuns(path)
+	c.Assert(err, IsNil)
+	c.Assert(runs, HasLen, 1)
+	c.Check(runs[0].TestName, Equals, "FIO test results (fast)")
+	c.Check(runs[0].Result.Size, Equals, "10Gi")
+	c.Check(runs[0].Result.Print(), Equals, run.Print())
+
+	// a single run result
+	c.Assert(os.WriteFile(path, raw, 0644), IsNil)
+	runs, err = LoadSavedRuns(path)
+	c.Assert(err, IsNil)
+	c.Assert(runs, HasLen, 1)
+
+	// a failed run saves no fio results
+	c.Assert(os.WriteFile(path, []byte(`[{"TestName": "FIO test results", "Status": [], "Raw": {"size": "10Gi"}}]`), 0644), IsNil)
+	_, err = LoadSavedRuns(path)
+	c.Assert(err, ErrorMatches, ".*no fio results found")
+
+	_, err = LoadSavedRuns(filepath.Join(dir, "missing.json"))
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestLoadFioOutput(c *C) {
+	path := filepath.Join(c.MkDir(), "raw.json")
+	c.Assert(os.WriteFile(path, []byte("note: both iodepth >= 1 and synchronous I/O engine are selected\n"+parsableFioOutput), 0644), IsNil)
+	result, err := LoadFioOutput(path)
+	c.Assert(err, IsNil)
+	c.Check(result.Result.Jobs, Not(HasLen), 0)
+
+	c.Assert(os.WriteFile(path, []byte(`{"fio version": "fio-3.36", "jobs": []}`), 0644), IsNil)
+	_, err = LoadFioOutput(path)
+	c.Assert(err, NotNil)
+	c.Assert(os.WriteFile(path, []byte("fio: failed to open"), 0644), IsNil)
+	_, err = LoadFioOutput(path)
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestApplyThresholds(c *C) {
+	result := &RunFIOResult{
+		Result:     FioResult{Jobs: []FioJobs{{JobName: "read", Read: FioStats{TotalIos: 10, Iops: 100}}}},
+		Thresholds: []ThresholdResult{{Status: ThresholdOK, Message: "saved"}},
+	}
+	c.Assert(result.ApplyThresholds(nil), IsNil)
+	c.Assert(result.Thresholds[0].Message, Equals, "saved")
+
+	c.Assert(result.ApplyThresholds([]Threshold{{Metric: ThresholdIOPS, Min: "1000"}}), IsNil)
+	c.Assert(result.Thresholds, HasLen, 1)
+	c.Assert(result.Thresholds[0].Status, Equals, ThresholdError)
+	c.Assert(ThresholdsFailed(result.Thresholds), Equals, true)
+
+	c.Assert(result.ApplyThresholds([]Threshold{{Metric: ThresholdIOPS}}), NotNil)
+}
@@ -0,0 +1,270 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/kastenhq/kubestr/pkg/common"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// FioServerPort is the port fio --server listens on in the client pods
+	FioServerPort = 8765
+	// ServiceGenerateName is the name to generate for the headless Service of the fio servers
+	ServiceGenerateName = "kubestr-fio-servers-"
+	// ControllerPodGenerateName is the name to generate for the pod that drives the fio servers
+	ControllerPodGenerateName = "kubestr-fio-controller-"
+	// AllClientsJobName is the job fio reports the aggregate of every server as
+	AllClientsJobName = "All clients"
+	// ServerDiscoveryInterval is how often the endpoints of the fio servers are checked
+	ServerDiscoveryInterval = 2 * time.Second
+	// clientJobFileFmt is the job file of a server, written in the controller pod
+	clientJobFileFmt = "/tmp/kubestr-client-%d.fio"
+)
+
+// serverContainer runs fio --server instead of idling, it is ready once the server listens
+func serverContainer(container *v1.Container) {
+	container.Args = []string{"-c", fmt.Sprintf("fio --server=,%d", FioServerPort)}
+	container.Ports = []v1.ContainerPort{{Name: "fio", ContainerPort: FioServerPort}}
+	container.ReadinessProbe = &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{
+			TCPSocket: &v1.TCPSocketAction{Port: intstr.FromInt32(FioServerPort)},
+		},
+		PeriodSeconds: 2,
+	}
+}
+
+// fioServerService is the headless Service that selects the client pods of a run
+func fioServerService(runName, namespace string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: ServiceGenerateName,
+			Namespace:    namespace,
+			Labels:       map[string]string{CreatedByFIOLabel: "true", FIORunLabel: runName},
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Selector:  map[string]string{FIORunLabel: runName},
+			Ports:     []v1.ServicePort{{Name: "fio", Port: FioServerPort}},
+		},
+	}
+}
+
+// fioControllerPod runs fio --client against the servers, it only mounts the job file
+func fioControllerPod(configMapName string, args *RunFIOArgs) (*v1.Pod, error) {
+	image := args.Image
+	if image == "" {
+		image = common.DefaultPodImage
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: ControllerPodGenerateName,
+			Namespace:    args.Namespace,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:         ContainerName,
+				Command:      []string{"/bin/sh"},
+				Args:         []string{"-c", "tail -f /dev/null"},
+				VolumeMounts: []v1.VolumeMount{{Name: "config-map", MountPath: ConfigMapMountPath}},
+				Image:        image,
+			}},
+			Volumes: []v1.Volume{{
+				Name: "config-map",
+				VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+					},
+				},
+			}},
+			NodeSelector: args.NodeSelector,
+		},
+	}
+	return args.Pod.apply(pod)
+}
+
+// jobFileOptions turns fio command line arguments into job file options, e.g. --directory /x into directory=/x
+func jobFileOptions(fioArgs []string) []string {
+	var options []string
+	for i := 0; i < len(fioArgs); i++ {
+		key, value, found := strings.Cut(strings.TrimPrefix(fioArgs[i], "--"), "=")
+		if !found && i+1 < len(fioArgs) && !strings.HasPrefix(fioArgs[i+1], "--") {
+			value, found = fioArgs[i+1], true
+			i++
+		}
+		if found {
+			key += "=" + value
+		}
+		options = append(options, key)
+	}
+	return options
+}
+
+// clientServerScript writes the job file of every server, pointed at the volume of its pod, and
+// runs them all from a single fio --client so they start together
+func clientServerScript(clients []fioClient, servers map[string]string, testFileName, outputFormat string) string {
+	jobFile := fmt.Sprintf("%s/%s", ConfigMapMountPath, testFileName)
+	steps := make([]string, 0, len(clients)+1)
+	command := []string{"fio", "--output-format=" + outputFormat}
+	for i, client := range clients {
+		clientJobFile := fmt.Sprintf(clientJobFileFmt, i)
+		lines := append([]string{"[global]"}, jobFileOptions(client.fioArgs)...)
+		steps = append(steps, fmt.Sprintf("{ printf '%%s\\n' %s; cat %s; } > %s", shellJoin(lines), shellQuote(jobFile), shellQuote(clientJobFile)))
+		command = append(command, "--client="+servers[client.pod.Name], clientJobFile)
+	}
+	steps = append(steps, shellJoin(command))
+	return strings.Join(steps, " && ")
+}
+
+// runClientServer starts fio --client in the controller pod against the server of every client
+// and splits the report fio returns per client. fio's own aggregate is returned as well.
+func (f *FIOrunner) runClientServer(ctx context.Context, controller *v1.Pod, clients []fioClient, servers map[string]string, testFileName string, args *RunFIOArgs) ([]FioClientResult, *FioJobs, error) {
+	script := clientServerScript(clients, servers, testFileName, args.outputFormat())
+	timestart := time.Now()
+	spin := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
+	spin.Start()
+	out, err := f.fioSteps.runFIOClients(ctx, controller.Name, ContainerName, args.Namespace, script)
+	spin.Stop()
+	fmt.Println("Elapsed time-", time.Since(timestart))
+	if err != nil {
+		return nil, nil, err
+	}
+	return splitClientStats(out, clients)
+}
+
+// splitClientStats groups the jobs fio reported per server by the client pod they ran in,
+// the server reports the name of its pod as its hostname
+func splitClientStats(out FioResult, clients []fioClient) ([]FioClientResult, *FioJobs, error) {
+	var all *FioJobs
+	jobs := map[string][]FioJobs{}
+	for _, job := range out.ClientStats {
+		if job.JobName == AllClientsJobName {
+			all = &job
+			continue
+		}
+		jobs[job.Hostname] = append(jobs[job.Hostname], job)
+	}
+	results := make([]FioClientResult, 0, len(clients))
+	for _, client := range clients {
+		clientJobs, ok := jobs[client.pod.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("fio reported no jobs for client (%s)", client.pod.Name)
+		}
+		results = append(results, FioClientResult{
+			Pod:  client.pod.Name,
+			Node: client.pod.Spec.NodeName,
+			Result: FioResult{
+				FioVersion:    out.FioVersion,
+				Timestamp:     out.Timestamp,
+				TimestampMS:   out.TimestampMS,
+				Time:          out.Time,
+				GlobalOptions: out.GlobalOptions,
+				Jobs:          clientJobs,
+			},
+		})
+	}
+	return results, all, nil
+}
+
+// printAllClients prints the aggregate fio reported for every server
+func printAllClients(all FioJobs, clients int) string {
+	return fmt.Sprintf("  fio aggregate of %d clients: read IOPS=%f BW(KiB/s)=%d, write IOPS=%f BW(KiB/s)=%d\n",
+		clients, all.Read.Iops, all.Read.BW, all.Write.Iops, all.Write.BW)
+}
+
+func (s *fioStepper) createService(ctx context.Context, svc *v1.Service) (*v1.Service, error) {
+	return s.cli.CoreV1().Services(svc.Namespace).Create(ctx, svc, metav1.CreateOptions{})
+}
+
+// deleteService only deletes a Service if it has the label
+func (s *fioStepper) deleteService(ctx context.Context, svc *v1.Service) error {
+	if val, ok := svc.Labels[CreatedByFIOLabel]; !ok || val != "true" {
+		return nil
+	}
+	return s.cli.CoreV1().Services(svc.Namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{})
+}
+
+// discoverServers waits for the headless Service to have a ready endpoint for each of the servers
+// and returns their addresses keyed by pod name
+func (s *fioStepper) discoverServers(ctx context.Context, serviceName, namespace string, count int) (map[string]string, error) {
+	var servers map[string]string
+	err := wait.PollUntilContextCancel(ctx, ServerDiscoveryInterval, true, func(ctx context.Context) (bool, error) {
+		slices, err := s.cli.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: discoveryv1.LabelServiceName + "=" + serviceName,
+		})
+		if err != nil {
+			return false, err
+		}
+		servers = map[string]string{}
+		for _, slice := range slices.Items {
+			for _, endpoint := range slice.Endpoints {
+				ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+				if !ready || endpoint.TargetRef == nil || len(endpoint.Addresses) == 0 {
+					continue
+				}
+				address := endpoint.Addresses[0]
+				if slice.AddressType == discoveryv1.AddressTypeIPv6 {
+					address = "ip6:" + address
+				}
+				servers[endpoint.TargetRef.Name] = address
+			}
+		}
+		return len(servers) >= count, nil
+	})
+	if err != nil {
+		pods := make([]string, 0, len(servers))
+		for pod := range servers {
+			pods = append(pods, pod)
+		}
+		sort.Strings(pods)
+		return nil, errors.Wrapf(err, "found %d of %d fio servers (%s)", len(servers), count, strings.Join(pods, ", "))
+	}
+	return servers, nil
+}
+
+func (s *fioStepper) createControllerPod(ctx context.Context, configMapName string, args *RunFIOArgs) (*v1.Pod, error) {
+	pod, err := fioControllerPod(configMapName, args)
+	if err != nil {
+		return nil, err
+	}
+	podRes, err := s.cli.CoreV1().Pods(args.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return podRes, err
+	}
+	if err := s.podReady.waitForPodReady(ctx, args.Namespace, podRes.Name); err != nil {
+		return nil, err
+	}
+	return podRes, nil
+}
+
+// runFIOClients runs the client/server script in the controller pod and parses the report of fio
+func (s *fioStepper) runFIOClients(ctx context.Context, podName, containerName, namespace, script string) (FioResult, error) {
+	command := []string{"sh", "-c", script}
+	stdout, stderr, err := s.kubeExecutor.exec(ctx, namespace, podName, containerName, command)
+	if ctx.Err() != nil {
+		s.stopFIO(ctx, podName, containerName, namespace)
+		return FioResult{}, errors.Wrap(ctx.Err(), "FIO was interrupted")
+	}
+	// fio --client prints the servers it connects to before the report
+	if i := strings.Index(stdout, "{"); i >= 0 {
+		stdout = stdout[i:]
+	}
+	fioOut, decodeErr := decodeFioStream(strings.NewReader(stdout), nil)
+	if decodeErr == nil && len(fioOut.ClientStats) > 0 {
+		return fioOut, nil
+	}
+	if err == nil {
+		err = decodeErr
+	}
+	return fioOut, errors.Wrapf(err, "error running fio client/server, stderr:(%s)", stderr)
+}
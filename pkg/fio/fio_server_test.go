@@ -0,0 +1,162 @@
+package fio
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func (s *FIOTestSuite) TestJobFileOptions(c *C) {
+	c.Check(jobFileOptions([]string{"--directory", "/dataset/client-1"}), DeepEquals, []string{"directory=/dataset/client-1"})
+	c.Check(jobFileOptions([]string{"--filename", "/dev/xvda", "--readonly"}), DeepEquals, []string{"filename=/dev/xvda", "readonly"})
+	c.Check(jobFileOptions([]string{"--write_bw_log=/logs/fio"}), DeepEquals, []string{"write_bw_log=/logs/fio"})
+	c.Check(jobFileOptions(nil), IsNil)
+}
+
+func (s *FIOTestSuite) TestClientServerScript(c *C) {
+	clients := []fioClient{
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, fioArgs: []string{"--directory", "/dataset/client-0"}},
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b"}}, fioArgs: []string{"--directory", "/dataset/client-1"}},
+	}
+	servers := map[string]string{"a": "10.0.0.1", "b": "ip6:fd00::2"}
+	c.Assert(clientServerScript(clients, servers, "test.fio", "json"), Equals,
+		`{ printf '%s\n' '[global]' 'directory=/dataset/client-0'; cat '/etc/fio-config/test.fio'; } > '/tmp/kubestr-client-0.fio' && `+
+			`{ printf '%s\n' '[global]' 'directory=/dataset/client-1'; cat '/etc/fio-config/test.fio'; } > '/tmp/kubestr-client-1.fio' && `+
+			`'fio' '--output-format=json' '--client=10.0.0.1' '/tmp/kubestr-client-0.fio' '--client=ip6:fd00::2' '/tmp/kubestr-client-1.fio'`)
+}
+
+func (s *FIOTestSuite) TestSplitClientStats(c *C) {
+	clients := []fioClient{
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a"}, Spec: v1.PodSpec{NodeName: "node-a"}}},
+		{pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b"}}},
+	}
+	out := FioResult{
+		FioVersion: "fio-3.36",
+		ClientStats: []FioJobs{
+			{JobName: "read", Hostname: "a", Read: FioStats{Iops: 100}},
+			{JobName: "write", Hostname: "a", Write: FioStats{Iops: 50}},
+			{JobName: "read", Hostname: "b", Read: FioStats{Iops: 200}},
+			{JobName: AllClientsJobName, Read: FioStats{Iops: 300}, Write: FioStats{Iops: 50}},
+		},
+	}
+	results, all, err := splitClientStats(out, clients)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].Pod, Equals, "a")
+	c.Check(results[0].Node, Equals, "node-a")
+	c.Check(results[0].Result.FioVersion, Equals, "fio-3.36")
+	c.Check(results[0].Result.Jobs, HasLen, 2)
+	c.Check(results[1].Result.Jobs[0].Read.Iops, Equals, float64(200))
+	c.Assert(all, NotNil)
+	c.Check(all.Read.Iops, Equals, float64(300))
+	c.Check(printAllClients(*all, 2), Matches, "(?s)  fio aggregate of 2 clients: read IOPS=300.000000.*")
+
+	out.ClientStats = out.ClientStats[:2]
+	_, _, err = splitClientStats(out, clients)
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestFioServerPod(c *C) {
+	pod, err := fioPod("pvc", "cm", false, &RunFIOArgs{Namespace: "ns", ClientServer: true})
+	c.Assert(err, IsNil)
+	container := pod.Spec.Containers[0]
+	c.Check(container.Args, DeepEquals, []string{"-c", "fio --server=,8765"})
+	c.Check(container.ReadinessProbe.TCPSocket.Port.IntValue(), Equals, FioServerPort)
+
+	svc := fioServerService("cm", "ns")
+	c.Check(svc.Spec.ClusterIP, Equals, v1.ClusterIPNone)
+	c.Check(svc.Spec.Selector, DeepEquals, pod.Labels)
+
+	controller, err := fioControllerPod("cm", &RunFIOArgs{Namespace: "ns"})
+	c.Assert(err, IsNil)
+	c.Check(controller.Labels[FIORunLabel], Equals, "")
+	c.Check(controller.Spec.Volumes, HasLen, 1)
+}
+
+func (s *FIOTestSuite) TestDiscoverServers(c *C) {
+	ready, notReady := true, false
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "svc-abc",
+			Namespace: "ns",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "svc"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, TargetRef: &v1.ObjectReference{Name: "a"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			{Addresses: []string{"10.0.0.2"}, TargetRef: &v1.ObjectReference{Name: "b"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+		},
+	}
+	stepper := &fioStepper{cli: fake.NewSimpleClientset(slice)}
+	servers, err := stepper.discoverServers(context.Background(), "svc", "ns", 1)
+	c.Assert(err, IsNil)
+	c.Check(servers, DeepEquals, map[string]string{"a": "10.0.0.1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = stepper.discoverServers(ctx, "svc", "ns", 2)
+	c.Check(err, ErrorMatches, "found 1 of 2 fio servers \\(a\\).*")
+}
+
+func (s *FIOTestSuite) TestClientServerValidate(c *C) {
+	args := func() *RunFIOArgs {
+		return &RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", Clients: 2, ClientServer: true}
+	}
+	c.Assert(args().Validate(), IsNil)
+	a := args()
+	a.AsJob = true
+	c.Assert(a.Validate(), NotNil)
+	a = args()
+	a.LogInterval = time.Second
+	c.Assert(a.Validate(), NotNil)
+}
+
+func (s *FIOTestSuite) TestRunFioHelperClientServer(c *C) {
+	stepper := &fakeFioStepper{
+		lcmConfigMap: &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "CM1"},
+			Data:       map[string]string{"testfile.fio": "testfiledata"},
+		},
+		cPVC:    &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "PVC"}},
+		cPod:    &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "Pod"}},
+		dSrvOut: map[string]string{"Pod": "10.0.0.1"},
+		rFIOClOut: FioResult{ClientStats: []FioJobs{
+			{JobName: "read", Hostname: "Pod", Read: FioStats{Iops: 100, BW: 400}},
+			{JobName: AllClientsJobName, Read: FioStats{Iops: 200, BW: 800}},
+		}},
+	}
+	runner := &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	res, err := runner.RunFioHelper(context.Background(), &RunFIOArgs{
+		StorageClass: "sc",
+		Size:         "100Gi",
+		Namespace:    "foo",
+		Clients:      2,
+		ClientServer: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(stepper.steps, DeepEquals, []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CSVC", "CPOD", "CPOD", "CSD", "CSD",
+		"DSRV", "CCPOD", "RFIOCL", "DPOD", "DPOD", "DPOD", "DSVC", "DPVC", "DCM"})
+	c.Check(stepper.cSvcExp.Spec.Selector, DeepEquals, map[string]string{FIORunLabel: "CM1"})
+	c.Check(stepper.rFIOClScript, Matches, ".*'--client=10.0.0.1' '/tmp/kubestr-client-1.fio'$")
+	c.Assert(res.Clients, HasLen, 2)
+	c.Check(res.Result.Jobs[0].Read.Iops, Equals, float64(200))
+	c.Assert(res.AllClients, NotNil)
+	c.Check(res.AllClients.Read.BW, Equals, int64(800))
+	c.Check(res.Print(), Matches, "(?s).*fio aggregate of 2 clients: read IOPS=200.000000 BW\\(KiB/s\\)=800.*")
+
+	// a client pod without a server fails the run
+	stepper = &fakeFioStepper{
+		lcmConfigMap: stepper.lcmConfigMap,
+		cPVC:         stepper.cPVC,
+		cPod:         stepper.cPod,
+		dSrvOut:      map[string]string{"Other": "10.0.0.1"},
+	}
+	runner = &FIOrunner{Cli: fake.NewSimpleClientset(), fioSteps: stepper}
+	_, err = runner.RunFioHelper(context.Background(), &RunFIOArgs{StorageClass: "sc", Size: "100Gi", Namespace: "foo", ClientServer: true})
+	c.Assert(err, ErrorMatches, "no FIO server found for pod \\(Pod\\)")
+}
@@ -0,0 +1,107 @@
+package fio
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSteadyStateDuration is the window a steady state criterion has to hold over by default
+const DefaultSteadyStateDuration = time.Minute
+
+// steadyStateMetrics are the metrics fio can detect steady state on
+var steadyStateMetrics = []string{"iops", "iops_slope", "bw", "bw_slope"}
+
+// SteadyState stops every job once its throughput settles, using fio's steadystate options
+type SteadyState struct {
+	Criterion string        // metric and limit, e.g. iops_slope:0.3% or bw:5%
+	Duration  time.Duration // window the criterion has to hold over
+	Ramp      time.Duration // time before fio starts collecting data for the criterion
+}
+
+func (s SteadyState) Validate() error {
+	metric, limit, ok := strings.Cut(s.Criterion, ":")
+	if !ok || !slices.Contains(steadyStateMetrics, metric) {
+		return fmt.Errorf("invalid steady state criterion (%s), expected <metric>:<limit>[%%] with metric one of (%s)", s.Criterion, strings.Join(steadyStateMetrics, ", "))
+	}
+	if v, err := strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64); err != nil || v <= 0 {
+		return fmt.Errorf("invalid steady state limit (%s)", limit)
+	}
+	if s.Duration < time.Second {
+		return fmt.Errorf("invalid steady state duration (%s), it has to be at least a second", s.Duration)
+	}
+	if s.Ramp < 0 {
+		return fmt.Errorf("invalid steady state ramp time (%s)", s.Ramp)
+	}
+	return nil
+}
+
+// fioJob adds the steady state options to every job of the job file
+func (s SteadyState) fioJob(config string) (string, error) {
+	return appendJobOptions(config, func(FioJob) []string {
+		options := []string{
+			"steadystate=" + s.Criterion,
+			fmt.Sprintf("steadystate_duration=%ds", int64(s.Duration/time.Second)),
+		}
+		if s.Ramp > 0 {
+			options = append(options, fmt.Sprintf("steadystate_ramp_time=%ds", int64(s.Ramp/time.Second)))
+		}
+		return options
+	})
+}
+
+// FioSteadyState is fio's report on the steady state detection of a job
+type FioSteadyState struct {
+	Metric       string  `json:"ss,omitempty"`
+	Duration     int64   `json:"duration,omitempty"`
+	Attained     int     `json:"attained"`
+	Criterion    string  `json:"criterion,omitempty"`
+	MaxDeviation float64 `json:"max_deviation,omitempty"`
+	Slope        float64 `json:"slope,omitempty"`
+}
+
+// SteadyStateResult is whether a job reached steady state and how long it ran
+type SteadyStateResult struct {
+	Job       string        `json:"job"`
+	Criterion string        `json:"criterion"`
+	Reached   bool          `json:"reached"`
+	Runtime   time.Duration `json:"runtime"`
+}
+
+func (r SteadyStateResult) String() string {
+	return fmt.Sprintf("job (%s) steady state %s", r.Job, r.Summary())
+}
+
+// Summary is whether steady state was reached and after how long
+func (r SteadyStateResult) Summary() string {
+	if r.Reached {
+		return fmt.Sprintf("reached after %s (%s)", r.Runtime, r.Criterion)
+	}
+	return fmt.Sprintf("not reached within %s (%s)", r.Runtime, r.Criterion)
+}
+
+// steadyStateResult returns the steady state result of the job, if it detected steady state
+func (j FioJobs) steadyStateResult() (SteadyStateResult, bool) {
+	if j.SteadyState == nil {
+		return SteadyStateResult{}, false
+	}
+	return SteadyStateResult{
+		Job:       j.name(),
+		Criterion: fmt.Sprintf("%s %s", strings.ToLower(j.SteadyState.Metric), j.SteadyState.Criterion),
+		Reached:   j.SteadyState.Attained != 0,
+		Runtime:   time.Duration(j.JobRuntime) * time.Millisecond,
+	}, true
+}
+
+// SteadyStates returns the steady state results of the jobs that detected steady state
+func (f FioResult) SteadyStates() []SteadyStateResult {
+	var results []SteadyStateResult
+	for _, job := range f.Jobs {
+		if r, ok := job.steadyStateResult(); ok {
+			results = append(results, r)
+		}
+	}
+	return results
+}
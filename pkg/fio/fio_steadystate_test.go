@@ -0,0 +1,81 @@
+package fio
+
+import (
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FIOTestSuite) TestSteadyStateValidate(c *C) {
+	for _, tc := range []struct {
+		ss         SteadyState
+		errChecker Checker
+	}{
+		{ss: SteadyState{Criterion: "iops_slope:0.3%", Duration: time.Minute}, errChecker: IsNil},
+		{ss: SteadyState{Criterion: "bw:50000", Duration: time.Second, Ramp: 10 * time.Second}, errChecker: IsNil},
+		{ss: SteadyState{Criterion: "lat:1%", Duration: time.Minute}, errChecker: NotNil},
+		{ss: SteadyState{Criterion: "iops", Duration: time.Minute}, errChecker: NotNil},
+		{ss: SteadyState{Criterion: "iops:fast", Duration: time.Minute}, errChecker: NotNil},
+		{ss: SteadyState{Criterion: "iops:-1%", Duration: time.Minute}, errChecker: NotNil},
+		{ss: SteadyState{Criterion: "iops:1%", Duration: time.Millisecond}, errChecker: NotNil},
+		{ss: SteadyState{Criterion: "iops:1%", Duration: time.Minute, Ramp: -time.Second}, errChecker: NotNil},
+	} {
+		c.Check(tc.ss.Validate(), tc.errChecker, Commentf("%+v", tc.ss))
+	}
+	c.Assert((&RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", SteadyState: &SteadyState{Criterion: "iops:1%"}}).Validate(), NotNil)
+}
+
+func (s *FIOTestSuite) TestSteadyStateFioJob(c *C) {
+	config, err := SteadyState{Criterion: "iops_slope:0.3%", Duration: 90 * time.Second, Ramp: 5 * time.Second}.fioJob(`[global]
+direct=1
+[read]
+rw=randread
+
+[write]
+rw=randwrite
+`)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, `[global]
+direct=1
+[read]
+rw=randread
+steadystate=iops_slope:0.3%
+steadystate_duration=90s
+steadystate_ramp_time=5s
+
+[write]
+rw=randwrite
+steadystate=iops_slope:0.3%
+steadystate_duration=90s
+steadystate_ramp_time=5s
+`)
+
+	_, config, err = fioJobConfig(&RunFIOArgs{SteadyState: &SteadyState{Criterion: "bw:5%", Duration: time.Minute}})
+	c.Assert(err, IsNil)
+	file, err := ParseFioJobFile(config)
+	c.Assert(err, IsNil)
+	for _, job := range file.Jobs() {
+		ss, ok := job.Option("steadystate")
+		c.Assert(ok, Equals, true)
+		c.Assert(ss.Value, Equals, "bw:5%")
+		_, ok = job.Option("steadystate_ramp_time")
+		c.Assert(ok, Equals, false)
+	}
+}
+
+func (s *FIOTestSuite) TestSteadyStates(c *C) {
+	var result FioResult
+	err := json.Unmarshal([]byte(`{"jobs": [
+		{"jobname": "settled", "job_runtime": 42000, "steadystate": {"ss": "iops_slope", "duration": 30, "attained": 1, "criterion": "0.300000%", "max_deviation": 12.5, "slope": 0.1}},
+		{"jobname": "noisy", "job_runtime": 120000, "steadystate": {"ss": "bw", "duration": 30, "attained": 0, "criterion": "5.000000%"}},
+		{"jobname": "plain", "job_runtime": 15000}
+	]}`), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result.SteadyStates(), DeepEquals, []SteadyStateResult{
+		{Job: "settled", Criterion: "iops_slope 0.300000%", Reached: true, Runtime: 42 * time.Second},
+		{Job: "noisy", Criterion: "bw 5.000000%", Reached: false, Runtime: 2 * time.Minute},
+	})
+	c.Assert(result.SteadyStates()[0].String(), Equals, "job (settled) steady state reached after 42s (iops_slope 0.300000%)")
+	c.Assert(result.Jobs[1].Print(), Matches, "(?s).*steady state: not reached within 2m0s \\(bw 5.000000%\\).*")
+}
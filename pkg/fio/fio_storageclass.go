@@ -0,0 +1,83 @@
+package fio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	sv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// StorageClassGenerateName is the name to generate for StorageClass variants
+	StorageClassGenerateName = "kubestr-fio-sc-"
+	// BaseStorageClassAnnotation records the StorageClass a variant was cloned from
+	BaseStorageClassAnnotation = "kubestr.io/base-storageclass"
+	// inTreeFSTypeParam is the fsType parameter of in-tree provisioners
+	inTreeFSTypeParam = "fsType"
+	// csiFSTypeParam is the fsType parameter of CSI provisioners
+	csiFSTypeParam = "csi.storage.k8s.io/fstype"
+)
+
+// StorageClassVariant overrides a StorageClass for a single run. The StorageClass of the run
+// is cloned into a temporary class with the overrides, and deleted afterwards.
+type StorageClassVariant struct {
+	Parameters   map[string]string // an empty value removes the parameter
+	MountOptions []string          // added to the mount options of the base class
+	FSType       string
+}
+
+func (v StorageClassVariant) String() string {
+	var overrides []string
+	for key, value := range v.Parameters {
+		overrides = append(overrides, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(overrides)
+	if len(v.MountOptions) > 0 {
+		overrides = append(overrides, "mountOptions="+strings.Join(v.MountOptions, ","))
+	}
+	if v.FSType != "" {
+		overrides = append(overrides, "fsType="+v.FSType)
+	}
+	return strings.Join(overrides, " ")
+}
+
+// storageClassVariant clones the base StorageClass with the overrides of the variant
+func storageClassVariant(base *sv1.StorageClass, variant StorageClassVariant) *sv1.StorageClass {
+	params := map[string]string{}
+	for k, v := range base.Parameters {
+		params[k] = v
+	}
+	if variant.FSType != "" {
+		key := csiFSTypeParam
+		if _, ok := base.Parameters[inTreeFSTypeParam]; ok {
+			key = inTreeFSTypeParam
+		}
+		params[key] = variant.FSType
+	}
+	for k, v := range variant.Parameters {
+		if v == "" {
+			delete(params, k)
+			continue
+		}
+		params[k] = v
+	}
+	// volumes of the variant are always deleted with their PVC, whatever the base class retains
+	reclaimPolicy := v1.PersistentVolumeReclaimDelete
+	return &sv1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: StorageClassGenerateName,
+			Labels:       map[string]string{CreatedByFIOLabel: "true"},
+			Annotations:  map[string]string{BaseStorageClassAnnotation: base.Name},
+		},
+		Provisioner:          base.Provisioner,
+		Parameters:           params,
+		ReclaimPolicy:        &reclaimPolicy,
+		MountOptions:         append(append([]string(nil), base.MountOptions...), variant.MountOptions...),
+		AllowVolumeExpansion: base.AllowVolumeExpansion,
+		VolumeBindingMode:    base.VolumeBindingMode,
+		AllowedTopologies:    base.AllowedTopologies,
+	}
+}
@@ -0,0 +1,62 @@
+package fio
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	sv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func (s *FIOTestSuite) TestStorageClassVariant(c *C) {
+	retain := v1.PersistentVolumeReclaimRetain
+	waitForConsumer := sv1.VolumeBindingWaitForFirstConsumer
+	base := &sv1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: "gp3", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+		Provisioner:       "ebs.csi.aws.com",
+		Parameters:        map[string]string{"type": "gp3", "iops": "3000", "encrypted": "true"},
+		ReclaimPolicy:     &retain,
+		MountOptions:      []string{"discard"},
+		VolumeBindingMode: &waitForConsumer,
+	}
+	variant := StorageClassVariant{
+		Parameters:   map[string]string{"type": "io2", "iops": ""},
+		MountOptions: []string{"noatime"},
+		FSType:       "xfs",
+	}
+	c.Assert(variant.String(), Equals, "iops= type=io2 mountOptions=noatime fsType=xfs")
+	sc := storageClassVariant(base, variant)
+	c.Assert(sc.GenerateName, Equals, StorageClassGenerateName)
+	c.Assert(sc.Labels, DeepEquals, map[string]string{CreatedByFIOLabel: "true"})
+	c.Assert(sc.Annotations, DeepEquals, map[string]string{BaseStorageClassAnnotation: "gp3"})
+	c.Assert(sc.Provisioner, Equals, "ebs.csi.aws.com")
+	c.Assert(sc.Parameters, DeepEquals, map[string]string{"type": "io2", "encrypted": "true", csiFSTypeParam: "xfs"})
+	c.Assert(*sc.ReclaimPolicy, Equals, v1.PersistentVolumeReclaimDelete)
+	c.Assert(sc.MountOptions, DeepEquals, []string{"discard", "noatime"})
+	c.Assert(*sc.VolumeBindingMode, Equals, waitForConsumer)
+	// the base class is left untouched
+	c.Assert(base.Parameters, HasLen, 3)
+	c.Assert(base.MountOptions, DeepEquals, []string{"discard"})
+
+	inTree := storageClassVariant(&sv1.StorageClass{Parameters: map[string]string{inTreeFSTypeParam: "ext4"}}, StorageClassVariant{FSType: "xfs"})
+	c.Assert(inTree.Parameters, DeepEquals, map[string]string{inTreeFSTypeParam: "xfs"})
+}
+
+func (s *FIOTestSuite) TestDeleteStorageClass(c *C) {
+	ctx := context.Background()
+	stepper := &fioStepper{cli: fake.NewSimpleClientset(
+		&sv1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "variant", Labels: map[string]string{CreatedByFIOLabel: "true"}}},
+		&sv1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "gp3"}},
+	)}
+	for _, name := range []string{"variant", "gp3"} {
+		sc, err := stepper.cli.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+		c.Assert(err, IsNil)
+		c.Assert(stepper.deleteStorageClass(ctx, sc), IsNil)
+	}
+	scs, err := stepper.cli.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(scs.Items, HasLen, 1)
+	c.Assert(scs.Items[0].Name, Equals, "gp3")
+}
@@ -0,0 +1,63 @@
+package fio
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// builtinFioVars are the variables every job file template can use
+var builtinFioVars = []string{"Size", "SizeBytes", "MountPath", "Clients", "Namespace", "StorageClass", "PVC", "Block"}
+
+// fioTemplateVars returns the variables of a job file template, the built-in ones
+// describing the run and the ones set by the user
+func fioTemplateVars(args *RunFIOArgs) map[string]interface{} {
+	vars := map[string]interface{}{}
+	for k, v := range args.Vars {
+		vars[k] = v
+	}
+	var sizeBytes int64
+	if q, err := resource.ParseQuantity(args.Size); err == nil {
+		sizeBytes = q.Value()
+	}
+	mountPath := VolumeMountPath
+	if args.isBlock() {
+		mountPath = VolumeDevicePath
+	}
+	vars["Size"] = args.Size
+	vars["SizeBytes"] = sizeBytes
+	vars["MountPath"] = mountPath
+	vars["Clients"] = args.clientCount()
+	vars["Namespace"] = args.Namespace
+	vars["StorageClass"] = args.StorageClass
+	vars["PVC"] = args.PVC
+	vars["Block"] = args.isBlock()
+	return vars
+}
+
+// validateFioVars rejects variables that would shadow a built-in one
+func validateFioVars(vars map[string]string) error {
+	for _, name := range builtinFioVars {
+		if _, ok := vars[name]; ok {
+			return fmt.Errorf("variable (%s) is built in and can't be set", name)
+		}
+	}
+	return nil
+}
+
+// renderFioJob executes a job file as a text/template, e.g. bs={{.bs}} or size={{.SizeBytes}}.
+// Referencing a variable that isn't set is an error.
+func renderFioJob(name, config string, args *RunFIOArgs) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(config)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to parse fio job template (%s)", name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, fioTemplateVars(args)); err != nil {
+		return "", errors.Wrapf(err, "unable to render fio job template (%s)", name)
+	}
+	return buf.String(), nil
+}
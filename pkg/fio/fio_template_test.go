@@ -0,0 +1,67 @@
+package fio
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func (s *FIOTestSuite) TestRenderFioJob(c *C) {
+	args := &RunFIOArgs{
+		Size:    "1Gi",
+		Clients: 2,
+		Vars:    map[string]string{"bs": "8k", "depth": "32"},
+	}
+	config, err := renderFioJob("job.fio", `[job]
+bs={{.bs}}
+iodepth={{.depth}}
+size={{.SizeBytes}}
+directory={{.MountPath}}
+numjobs={{.Clients}}
+`, args)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, `[job]
+bs=8k
+iodepth=32
+size=1073741824
+directory=/dataset
+numjobs=2
+`)
+
+	config, err = renderFioJob("job.fio", "filename={{.MountPath}}\n{{if .Block}}direct=1{{end}}", &RunFIOArgs{VolumeMode: v1.PersistentVolumeBlock})
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, "filename="+VolumeDevicePath+"\ndirect=1")
+
+	// fio's own ${VAR} substitution is left alone
+	config, err = renderFioJob("job.fio", "directory=${DIR}", &RunFIOArgs{})
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, "directory=${DIR}")
+
+	_, err = renderFioJob("job.fio", "bs={{.bs}}", &RunFIOArgs{})
+	c.Assert(err, NotNil)
+	_, err = renderFioJob("job.fio", "bs={{.bs", &RunFIOArgs{})
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestFioJobConfigTemplate(c *C) {
+	file, err := os.CreateTemp("", "template.fio")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Check(os.Remove(file.Name()), IsNil)
+	}()
+	_, err = file.WriteString("[job]\nbs={{.bs}}\n")
+	c.Assert(err, IsNil)
+	_, config, err := fioJobConfig(&RunFIOArgs{FIOJobFilepath: file.Name(), Vars: map[string]string{"bs": "4k"}})
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, "[job]\nbs=4k\n")
+}
+
+func (s *FIOTestSuite) TestValidateFioVars(c *C) {
+	args := RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", Vars: map[string]string{"bs": "4k"}}
+	c.Assert(args.Validate(), NotNil)
+	args.FIOJobFilepath = "job.fio"
+	c.Assert(args.Validate(), IsNil)
+	args.Vars["Size"] = "2Gi"
+	c.Assert(args.Validate(), NotNil)
+}
@@ -4,14 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/kastenhq/kubestr/pkg/common"
 	"github.com/pkg/errors"
 	. "gopkg.in/check.v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -47,6 +54,12 @@ func (s *FIOTestSuite) TestRunFioHelper(c *C) {
 		expectedSize  string
 		expectedTFN   string
 		expectedPVC   string
+		expectedVM    v1.PersistentVolumeMode
+		expectedArgs  [][]string
+		expectedRO    bool
+		expectedAM    v1.PersistentVolumeAccessMode
+		expectedDirs  []string
+		expectedLogs  int
 	}{
 		{ // invalid args (storageclass)
 			cli:     fake.NewSimpleClientset(),
@@ -144,6 +157,496 @@ func (s *FIOTestSuite) TestRunFioHelper(c *C) {
 			expectedTFN:   "testfile.fio",
 			expectedCM:    "CM1",
 			expectedPVC:   "PVC",
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath}},
+			expectedAM:    v1.ReadWriteOnce,
+		},
+		{ // success, preconditioned
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio":       "testfiledata",
+						PreconditionFileName: "preconditiondata",
+					},
+				},
+				cPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "PVC",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				Precondition: true,
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CPOD", "RFIOC", "RFIOC", "DPOD", "DPVC", "DCM"},
+			expectedSC:    "sc",
+			expectedSize:  DefaultPVCSize,
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "PVC",
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath}, {"--directory", VolumeMountPath}},
+			expectedAM:    v1.ReadWriteOnce,
+		},
+		{ // success, with node local references
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "testfiledata",
+					},
+				},
+				cPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "PVC",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				References:   []ReferenceMedium{ReferenceDisk, ReferenceMemory},
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CPOD", "RFIOC", "RFIOC", "RFIOC", "DPOD", "DPVC", "DCM"},
+			expectedSC:    "sc",
+			expectedSize:  DefaultPVCSize,
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "PVC",
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath}, {"--directory", "/reference-disk"}, {"--directory", "/reference-memory"}},
+			expectedAM:    v1.ReadWriteOnce,
+		},
+		{ // success, StorageClass variant
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				sceSC: &storagev1.StorageClass{
+					ObjectMeta:  metav1.ObjectMeta{Name: "sc"},
+					Provisioner: "ebs.csi.aws.com",
+				},
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "testfiledata",
+					},
+				},
+				cPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "PVC",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				Variant:      &StorageClassVariant{FSType: "xfs"},
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "CSC", "LCM", "CPVC", "CPOD", "RFIOC", "DPOD", "DPVC", "DCM", "DSC"},
+			expectedSC:    "variant",
+			expectedSize:  DefaultPVCSize,
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "PVC",
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath}},
+			expectedAM:    v1.ReadWriteOnce,
+		},
+		{ // StorageClass variant creation fails
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				sceSC:  &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "sc"}},
+				cSCErr: fmt.Errorf("create sc error"),
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				Variant:      &StorageClassVariant{FSType: "xfs"},
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "CSC"},
+		},
+		{ // StorageClass variant with an existing PVC
+			cli:     fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+				Variant:   &StorageClassVariant{FSType: "xfs"},
+			},
+			checker: NotNil,
+		},
+		{ // success, multiple clients
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "testfiledata",
+					},
+				},
+				cPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "PVC",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				Clients:      2,
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CPOD", "CPOD", "CSD", "CSD", "RFIOC", "RFIOC", "DPOD", "DPOD", "DPVC", "DCM"},
+			expectedSC:    "sc",
+			expectedSize:  DefaultPVCSize,
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "PVC",
+			expectedAM:    v1.ReadWriteMany,
+			expectedDirs:  []string{VolumeMountPath + "/client-0", VolumeMountPath + "/client-1"},
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath + "/client-0"}, {"--directory", VolumeMountPath + "/client-1"}},
+		},
+		{ // success, time series logs
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "testfiledata",
+					},
+				},
+				cPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "PVC",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+				cLogFiles: map[string]string{
+					"kubestr_bw.1.log":   "1000, 4096, 0, 4096, 0\n2000, 4000, 0, 4096, 0\n",
+					"kubestr_iops.1.log": "1000, 1024, 0, 4096, 0\n1000, 10, 1, 4096, 0\n",
+				},
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				LogInterval:  time.Second,
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CPOD", "CSD", "RFIOC", "CLOG", "DPOD", "DPVC", "DCM"},
+			expectedSC:    "sc",
+			expectedSize:  DefaultPVCSize,
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "PVC",
+			expectedAM:    v1.ReadWriteOnce,
+			expectedDirs:  []string{LogDirPath},
+			expectedArgs: [][]string{{"--directory", VolumeMountPath,
+				"--write_bw_log=" + LogDirPath + "/kubestr",
+				"--write_iops_log=" + LogDirPath + "/kubestr",
+				"--write_lat_log=" + LogDirPath + "/kubestr",
+				"--log_avg_msec=1000",
+			}},
+			expectedLogs: 3,
+		},
+		{ // job file fails lint, jobs don't fit the volume
+			cli:     fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "1Gi",
+				Namespace:    "foo",
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS"},
+		},
+		{ // lint errors are warnings when lint is skipped
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				sceErr: fmt.Errorf("storageclass Err"),
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "1Gi",
+				Namespace:    "foo",
+				SkipLint:     true,
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS", "SCE"},
+		},
+		{ // invalid log interval
+			cli:     fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				LogInterval:  -time.Second,
+			},
+			checker: NotNil,
+		},
+		{ // existing PVC can't be shared by multiple clients
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				gPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "existing",
+					},
+					Spec: v1.PersistentVolumeClaimSpec{
+						AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+					},
+				},
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "[job]\nrw=randwrite",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+				Clients:   2,
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS", "GPVC", "LCM", "DCM"},
+		},
+		{ // success, block mode
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "[job]\nrw=randwrite",
+					},
+				},
+				cPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "PVC",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				VolumeMode:   v1.PersistentVolumeBlock,
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "SCE", "LCM", "CPVC", "CPOD", "RFIOC", "DPOD", "DPVC", "DCM"},
+			expectedSC:    "sc",
+			expectedSize:  DefaultPVCSize,
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "PVC",
+			expectedVM:    v1.PersistentVolumeBlock,
+			expectedArgs:  [][]string{{"--filename", VolumeDevicePath}},
+			expectedAM:    v1.ReadWriteOnce,
+		},
+		{ // existing PVC, writes in a scratch directory
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				gPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "existing",
+					},
+				},
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "[job]\nrw=randwrite",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "GPVC", "LCM", "CPOD", "CSD", "RFIOC", "DSD", "DPOD", "DCM"},
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "existing",
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath + "/" + ScratchDirName}},
+			expectedDirs:  []string{VolumeMountPath + "/" + ScratchDirName},
+		},
+		{ // existing PVC, read only job
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				gPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "existing",
+					},
+					Spec: v1.PersistentVolumeClaimSpec{
+						StorageClassName: &[]string{"sc"}[0],
+					},
+				},
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "[job]\nrw=randread\nfilename=data",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+			},
+			checker:       IsNil,
+			expectedSteps: []string{"VN", "VNS", "GPVC", "SCE", "LCM", "CPOD", "RFIOC", "DPOD", "DCM"},
+			expectedTFN:   "testfile.fio",
+			expectedCM:    "CM1",
+			expectedPVC:   "existing",
+			expectedArgs:  [][]string{{"--directory", VolumeMountPath, "--readonly"}},
+			expectedRO:    true,
+		},
+		{ // existing PVC, read only job that does not name its files
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				gPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "existing",
+					},
+					Spec: v1.PersistentVolumeClaimSpec{
+						StorageClassName: &[]string{"sc"}[0],
+					},
+				},
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "[job]\nrw=randread",
+					},
+				},
+				cPod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "Pod",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS", "GPVC", "SCE", "LCM", "DCM"},
+		},
+		{ // existing block PVC, refuses to write
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				gPVC: &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "existing",
+					},
+					Spec: v1.PersistentVolumeClaimSpec{
+						VolumeMode: &[]v1.PersistentVolumeMode{v1.PersistentVolumeBlock}[0],
+					},
+				},
+				lcmConfigMap: &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "CM1",
+					},
+					Data: map[string]string{
+						"testfile.fio": "[job]\nrw=randwrite",
+					},
+				},
+			},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS", "GPVC", "LCM", "DCM"},
+		},
+		{ // existing PVC not found
+			cli: fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{
+				gPVCErr: fmt.Errorf("pvc Err"),
+			},
+			args: &RunFIOArgs{
+				PVC:       "existing",
+				Namespace: "foo",
+			},
+			checker:       NotNil,
+			expectedSteps: []string{"VN", "VNS", "GPVC"},
+		},
+		{ // invalid volume mode
+			cli:     fake.NewSimpleClientset(),
+			stepper: &fakeFioStepper{},
+			args: &RunFIOArgs{
+				StorageClass: "sc",
+				Size:         "100Gi",
+				Namespace:    "foo",
+				VolumeMode:   "Raw",
+			},
+			checker: NotNil,
 		},
 		{ // fio test error
 			cli: fake.NewSimpleClientset(),
@@ -268,7 +771,7 @@ func (s *FIOTestSuite) TestRunFioHelper(c *C) {
 			Cli:      tc.cli,
 			fioSteps: tc.stepper,
 		}
-		_, err := fio.RunFioHelper(ctx, tc.args)
+		res, err := fio.RunFioHelper(ctx, tc.args)
 		c.Check(err, tc.checker)
 		c.Assert(tc.stepper.steps, DeepEquals, tc.expectedSteps)
 		if err == nil {
@@ -277,11 +780,21 @@ func (s *FIOTestSuite) TestRunFioHelper(c *C) {
 			c.Assert(tc.expectedTFN, Equals, tc.stepper.cPodExpFN)
 			c.Assert(tc.expectedCM, Equals, tc.stepper.cPodExpCM)
 			c.Assert(tc.expectedPVC, Equals, tc.stepper.cPodExpPVC)
+			c.Assert(tc.expectedVM, Equals, tc.stepper.cPVCExpVM)
+			sort.Slice(tc.stepper.rFIOExpArgs, func(i, j int) bool {
+				return strings.Join(tc.stepper.rFIOExpArgs[i], " ") < strings.Join(tc.stepper.rFIOExpArgs[j], " ")
+			})
+			c.Assert(tc.expectedArgs, DeepEquals, tc.stepper.rFIOExpArgs)
+			c.Assert(tc.expectedRO, Equals, tc.stepper.cPodExpRO)
+			c.Assert(tc.expectedAM, Equals, tc.stepper.cPVCExpAM)
+			c.Assert(tc.expectedDirs, DeepEquals, tc.stepper.cSDExpDirs)
+			c.Assert(res.Logs, HasLen, tc.expectedLogs)
 		}
 	}
 }
 
 type fakeFioStepper struct {
+	mu    sync.Mutex
 	steps []string
 
 	vnErr error
@@ -291,11 +804,19 @@ type fakeFioStepper struct {
 	sceSC  *storagev1.StorageClass
 	sceErr error
 
+	cSCExp *storagev1.StorageClass
+	cSCErr error
+
 	lcmConfigMap *v1.ConfigMap
 	lcmErr       error
 
+	gPVC    *v1.PersistentVolumeClaim
+	gPVCErr error
+
 	cPVCExpSC   string
 	cPVCExpSize string
+	cPVCExpVM   v1.PersistentVolumeMode
+	cPVCExpAM   v1.PersistentVolumeAccessMode
 	cPVC        *v1.PersistentVolumeClaim
 	cPVCErr     error
 
@@ -304,13 +825,47 @@ type fakeFioStepper struct {
 	cPodExpFN  string
 	cPodExpCM  string
 	cPodExpPVC string
+	cPodExpRO  bool
 	cPod       *v1.Pod
 	cPodErr    error
 
 	dPodErr error
 
-	rFIOout FioResult
-	rFIOErr error
+	cSDExpDirs []string
+
+	cLogFiles map[string]string
+	cLogErr   error
+
+	rFIOExpArgs [][]string
+	rFIOExpFNs  []string
+	rFIOOpts    []fioRunOptions
+	rFIOout     FioResult
+	rFIOErr     error
+
+	cJobExp *batchv1.Job
+	cJobErr error
+
+	gJob    *batchv1.Job
+	gJobErr error
+
+	wJobOut       string
+	wJobSucceeded bool
+	wJobErr       error
+
+	rJobOut string
+	rJobErr error
+
+	cSvcExp *v1.Service
+	cSvcErr error
+
+	dSrvOut map[string]string
+	dSrvErr error
+
+	cCPodErr error
+
+	rFIOClScript string
+	rFIOClOut    FioResult
+	rFIOClErr    error
 }
 
 func (f *fakeFioStepper) validateNamespace(ctx context.Context, namespace string) error {
@@ -325,22 +880,40 @@ func (f *fakeFioStepper) storageClassExists(ctx context.Context, storageClass st
 	f.steps = append(f.steps, "SCE")
 	return f.sceSC, f.sceErr
 }
+func (f *fakeFioStepper) createStorageClass(ctx context.Context, sc *storagev1.StorageClass) (*storagev1.StorageClass, error) {
+	f.steps = append(f.steps, "CSC")
+	f.cSCExp = sc
+	created := sc.DeepCopy()
+	created.Name = "variant"
+	return created, f.cSCErr
+}
+func (f *fakeFioStepper) deleteStorageClass(ctx context.Context, sc *storagev1.StorageClass) error {
+	f.steps = append(f.steps, "DSC")
+	return nil
+}
 func (f *fakeFioStepper) loadConfigMap(ctx context.Context, args *RunFIOArgs) (*v1.ConfigMap, error) {
 	f.steps = append(f.steps, "LCM")
 	return f.lcmConfigMap, f.lcmErr
 }
-func (f *fakeFioStepper) createPVC(ctx context.Context, storageclass, size, namespace string) (*v1.PersistentVolumeClaim, error) {
+func (f *fakeFioStepper) getPVC(ctx context.Context, pvcName, namespace string) (*v1.PersistentVolumeClaim, error) {
+	f.steps = append(f.steps, "GPVC")
+	return f.gPVC, f.gPVCErr
+}
+func (f *fakeFioStepper) createPVC(ctx context.Context, storageclass, size, namespace string, volumeMode v1.PersistentVolumeMode, accessMode v1.PersistentVolumeAccessMode) (*v1.PersistentVolumeClaim, error) {
 	f.steps = append(f.steps, "CPVC")
+	f.cPVCExpAM = accessMode
 	f.cPVCExpSC = storageclass
 	f.cPVCExpSize = size
+	f.cPVCExpVM = volumeMode
 	return f.cPVC, f.cPVCErr
 }
 func (f *fakeFioStepper) deletePVC(ctx context.Context, pvcName, namespace string) error {
 	f.steps = append(f.steps, "DPVC")
 	return f.dPVCErr
 }
-func (f *fakeFioStepper) createPod(ctx context.Context, pvcName, configMapName, testFileName, namespace string, nodeSelector map[string]string, image string) (*v1.Pod, error) {
+func (f *fakeFioStepper) createPod(ctx context.Context, pvcName, configMapName, testFileName string, readOnly bool, args *RunFIOArgs) (*v1.Pod, error) {
 	f.steps = append(f.steps, "CPOD")
+	f.cPodExpRO = readOnly
 	f.cPodExpCM = configMapName
 	f.cPodExpFN = testFileName
 	f.cPodExpPVC = pvcName
@@ -350,14 +923,82 @@ func (f *fakeFioStepper) deletePod(ctx context.Context, podName, namespace strin
 	f.steps = append(f.steps, "DPOD")
 	return f.dPodErr
 }
-func (f *fakeFioStepper) runFIOCommand(ctx context.Context, podName, containerName, testFileName, namespace string) (FioResult, error) {
+func (f *fakeFioStepper) createScratchDir(ctx context.Context, podName, containerName, namespace, dir string) error {
+	f.steps = append(f.steps, "CSD")
+	f.cSDExpDirs = append(f.cSDExpDirs, dir)
+	return nil
+}
+func (f *fakeFioStepper) deleteScratchDir(ctx context.Context, podName, containerName, namespace, dir string) error {
+	f.steps = append(f.steps, "DSD")
+	return nil
+}
+func (f *fakeFioStepper) collectLogs(ctx context.Context, podName, containerName, namespace, dir string) (map[string]string, error) {
+	f.steps = append(f.steps, "CLOG")
+	return f.cLogFiles, f.cLogErr
+}
+func (f *fakeFioStepper) runFIOCommand(ctx context.Context, podName, containerName, testFileName, namespace string, fioArgs []string, opts fioRunOptions) (FioResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.steps = append(f.steps, "RFIOC")
+	f.rFIOExpArgs = append(f.rFIOExpArgs, fioArgs)
+	f.rFIOExpFNs = append(f.rFIOExpFNs, testFileName)
+	f.rFIOOpts = append(f.rFIOOpts, opts)
+	if opts.progress != nil {
+		opts.progress(f.rFIOout)
+	}
 	return f.rFIOout, f.rFIOErr
 }
+func (f *fakeFioStepper) createJob(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error) {
+	f.steps = append(f.steps, "CJOB")
+	f.cJobExp = job
+	created := job.DeepCopy()
+	created.Name = "job"
+	return created, f.cJobErr
+}
+func (f *fakeFioStepper) getJob(ctx context.Context, name, namespace string) (*batchv1.Job, error) {
+	f.steps = append(f.steps, "GJOB")
+	return f.gJob, f.gJobErr
+}
+func (f *fakeFioStepper) waitForJob(ctx context.Context, name, namespace string) (string, bool, error) {
+	f.steps = append(f.steps, "WJOB")
+	return f.wJobOut, f.wJobSucceeded, f.wJobErr
+}
+func (f *fakeFioStepper) readJobResult(ctx context.Context, job *batchv1.Job, path string) (string, error) {
+	f.steps = append(f.steps, "RJOB")
+	return f.rJobOut, f.rJobErr
+}
+func (f *fakeFioStepper) deleteJob(ctx context.Context, job *batchv1.Job) error {
+	f.steps = append(f.steps, "DJOB")
+	return nil
+}
 func (f *fakeFioStepper) deleteConfigMap(ctx context.Context, configMap *v1.ConfigMap, namespace string) error {
 	f.steps = append(f.steps, "DCM")
 	return nil
 }
+func (f *fakeFioStepper) createService(ctx context.Context, svc *v1.Service) (*v1.Service, error) {
+	f.steps = append(f.steps, "CSVC")
+	f.cSvcExp = svc
+	created := svc.DeepCopy()
+	created.Name = "svc"
+	return created, f.cSvcErr
+}
+func (f *fakeFioStepper) deleteService(ctx context.Context, svc *v1.Service) error {
+	f.steps = append(f.steps, "DSVC")
+	return nil
+}
+func (f *fakeFioStepper) discoverServers(ctx context.Context, serviceName, namespace string, count int) (map[string]string, error) {
+	f.steps = append(f.steps, "DSRV")
+	return f.dSrvOut, f.dSrvErr
+}
+func (f *fakeFioStepper) createControllerPod(ctx context.Context, configMapName string, args *RunFIOArgs) (*v1.Pod, error) {
+	f.steps = append(f.steps, "CCPOD")
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "controller"}}, f.cCPodErr
+}
+func (f *fakeFioStepper) runFIOClients(ctx context.Context, podName, containerName, namespace, script string) (FioResult, error) {
+	f.steps = append(f.steps, "RFIOCL")
+	f.rFIOClScript = script
+	return f.rFIOClOut, f.rFIOClErr
+}
 
 func (s *FIOTestSuite) TestStorageClassExists(c *C) {
 	ctx := context.Background()
@@ -535,6 +1176,7 @@ func (s *FIOTestSuite) TestCreatePVC(c *C) {
 		cli          kubernetes.Interface
 		storageclass string
 		size         string
+		volumeMode   v1.PersistentVolumeMode
 		errChecker   Checker
 		pvcChecker   Checker
 		failCreates  bool
@@ -546,6 +1188,14 @@ func (s *FIOTestSuite) TestCreatePVC(c *C) {
 			errChecker:   IsNil,
 			pvcChecker:   NotNil,
 		},
+		{
+			cli:          fake.NewSimpleClientset(),
+			storageclass: "fakesc",
+			size:         "20Gi",
+			volumeMode:   v1.PersistentVolumeBlock,
+			errChecker:   IsNil,
+			pvcChecker:   NotNil,
+		},
 		{ // Fails to create pvc
 			cli:          fake.NewSimpleClientset(),
 			storageclass: "fakesc",
@@ -568,7 +1218,7 @@ func (s *FIOTestSuite) TestCreatePVC(c *C) {
 				return true, nil, errors.New("Error creating object")
 			})
 		}
-		pvc, err := stepper.createPVC(ctx, tc.storageclass, tc.size, DefaultNS)
+		pvc, err := stepper.createPVC(ctx, tc.storageclass, tc.size, DefaultNS, tc.volumeMode, v1.ReadWriteOnce)
 		c.Check(err, tc.errChecker)
 		c.Check(pvc, tc.pvcChecker)
 		if pvc != nil {
@@ -577,6 +1227,12 @@ func (s *FIOTestSuite) TestCreatePVC(c *C) {
 			value, ok := pvc.Spec.Resources.Requests.Storage().AsInt64()
 			c.Assert(ok, Equals, true)
 			c.Assert(value, Equals, int64(21474836480))
+			c.Assert(pvc.Spec.AccessModes, DeepEquals, []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce})
+			if tc.volumeMode == "" {
+				c.Assert(pvc.Spec.VolumeMode, IsNil)
+			} else {
+				c.Assert(*pvc.Spec.VolumeMode, Equals, tc.volumeMode)
+			}
 		}
 	}
 }
@@ -602,6 +1258,9 @@ func (s *FIOTestSuite) TestCreatPod(c *C) {
 		testFileName  string
 		nodeSelector  map[string]string
 		image         string
+		volumeMode    v1.PersistentVolumeMode
+		readOnly      bool
+		clients       int
 		reactor       []k8stesting.Reactor
 		podReadyErr   error
 		errChecker    Checker
@@ -616,6 +1275,27 @@ func (s *FIOTestSuite) TestCreatPod(c *C) {
 			},
 			errChecker: IsNil,
 		},
+		{
+			pvcName:       "pvc",
+			configMapName: "cm",
+			testFileName:  "testfile",
+			volumeMode:    v1.PersistentVolumeBlock,
+			errChecker:    IsNil,
+		},
+		{
+			pvcName:       "pvc",
+			configMapName: "cm",
+			testFileName:  "testfile",
+			readOnly:      true,
+			errChecker:    IsNil,
+		},
+		{
+			pvcName:       "pvc",
+			configMapName: "cm",
+			testFileName:  "testfile",
+			clients:       3,
+			errChecker:    IsNil,
+		},
 		{
 			pvcName:       "pvc",
 			configMapName: "cm",
@@ -696,7 +1376,13 @@ func (s *FIOTestSuite) TestCreatPod(c *C) {
 		if tc.reactor != nil {
 			stepper.cli.(*fake.Clientset).ReactionChain = tc.reactor
 		}
-		pod, err := stepper.createPod(ctx, tc.pvcName, tc.configMapName, tc.testFileName, DefaultNS, tc.nodeSelector, tc.image)
+		pod, err := stepper.createPod(ctx, tc.pvcName, tc.configMapName, tc.testFileName, tc.readOnly, &RunFIOArgs{
+			Namespace:    DefaultNS,
+			NodeSelector: tc.nodeSelector,
+			Image:        tc.image,
+			VolumeMode:   tc.volumeMode,
+			Clients:      tc.clients,
+		})
 		c.Check(err, tc.errChecker)
 		if err == nil {
 			c.Assert(pod.GenerateName, Equals, PodGenerateName)
@@ -705,6 +1391,7 @@ func (s *FIOTestSuite) TestCreatPod(c *C) {
 				switch vol.Name {
 				case "persistent-storage":
 					c.Assert(vol.PersistentVolumeClaim.ClaimName, Equals, tc.pvcName)
+					c.Assert(vol.PersistentVolumeClaim.ReadOnly, Equals, tc.readOnly)
 				case "config-map":
 					c.Assert(vol.ConfigMap.Name, Equals, tc.configMapName)
 				}
@@ -713,20 +1400,107 @@ func (s *FIOTestSuite) TestCreatPod(c *C) {
 			c.Assert(pod.Spec.Containers[0].Name, Equals, ContainerName)
 			c.Assert(pod.Spec.Containers[0].Command, DeepEquals, []string{"/bin/sh"})
 			c.Assert(pod.Spec.Containers[0].Args, DeepEquals, []string{"-c", "tail -f /dev/null"})
-			c.Assert(pod.Spec.Containers[0].VolumeMounts, DeepEquals, []v1.VolumeMount{
-				{Name: "persistent-storage", MountPath: VolumeMountPath},
-				{Name: "config-map", MountPath: ConfigMapMountPath},
-			})
+			if tc.volumeMode == v1.PersistentVolumeBlock {
+				c.Assert(pod.Spec.Containers[0].VolumeMounts, DeepEquals, []v1.VolumeMount{
+					{Name: "config-map", MountPath: ConfigMapMountPath},
+				})
+				c.Assert(pod.Spec.Containers[0].VolumeDevices, DeepEquals, []v1.VolumeDevice{
+					{Name: "persistent-storage", DevicePath: VolumeDevicePath},
+				})
+			} else {
+				c.Assert(pod.Spec.Containers[0].VolumeMounts, DeepEquals, []v1.VolumeMount{
+					{Name: "persistent-storage", MountPath: VolumeMountPath, ReadOnly: tc.readOnly},
+					{Name: "config-map", MountPath: ConfigMapMountPath},
+				})
+				c.Assert(pod.Spec.Containers[0].VolumeDevices, IsNil)
+			}
 			if tc.image == "" {
 				c.Assert(pod.Spec.Containers[0].Image, Equals, common.DefaultPodImage)
 			} else {
 				c.Assert(pod.Spec.Containers[0].Image, Equals, tc.image)
 			}
 			c.Assert(pod.Spec.NodeSelector, DeepEquals, tc.nodeSelector)
+			c.Assert(pod.Labels[FIORunLabel], Equals, tc.configMapName)
+			if tc.clients > 1 {
+				c.Assert(pod.Spec.Affinity, DeepEquals, clientAntiAffinity(tc.configMapName))
+			} else {
+				c.Assert(pod.Spec.Affinity, IsNil)
+			}
+		}
+	}
+}
+
+func (s *FIOTestSuite) TestUseExistingPVC(c *C) {
+	sc := "sc"
+	block := v1.PersistentVolumeBlock
+	for _, tc := range []struct {
+		pvc        *v1.PersistentVolumeClaim
+		args       *RunFIOArgs
+		errChecker Checker
+		expected   *RunFIOArgs
+	}{
+		{ // capacity is preferred over the request
+			pvc: &v1.PersistentVolumeClaim{
+				Spec: v1.PersistentVolumeClaimSpec{
+					StorageClassName: &sc,
+					Resources: v1.VolumeResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+					},
+				},
+				Status: v1.PersistentVolumeClaimStatus{
+					Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("20Gi")},
+				},
+			},
+			args:       &RunFIOArgs{PVC: "pvc", StorageClass: "ignored"},
+			errChecker: IsNil,
+			expected:   &RunFIOArgs{PVC: "pvc", StorageClass: "sc", Size: "20Gi", VolumeMode: v1.PersistentVolumeFilesystem},
+		},
+		{ // static PV without a StorageClass
+			pvc: &v1.PersistentVolumeClaim{
+				Spec: v1.PersistentVolumeClaimSpec{
+					VolumeMode: &block,
+					Resources: v1.VolumeResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+					},
+				},
+			},
+			args:       &RunFIOArgs{PVC: "pvc", VolumeMode: v1.PersistentVolumeBlock},
+			errChecker: IsNil,
+			expected:   &RunFIOArgs{PVC: "pvc", Size: "10Gi", VolumeMode: v1.PersistentVolumeBlock},
+		},
+		{ // volume mode mismatch
+			pvc:        &v1.PersistentVolumeClaim{},
+			args:       &RunFIOArgs{PVC: "pvc", VolumeMode: v1.PersistentVolumeBlock},
+			errChecker: NotNil,
+		},
+		{ // references can't be compared with a block PVC
+			pvc:        &v1.PersistentVolumeClaim{Spec: v1.PersistentVolumeClaimSpec{VolumeMode: &block}},
+			args:       &RunFIOArgs{PVC: "pvc", References: []ReferenceMedium{ReferenceDisk}},
+			errChecker: NotNil,
+		},
+	} {
+		err := useExistingPVC(tc.args, tc.pvc)
+		c.Check(err, tc.errChecker)
+		if err == nil {
+			c.Assert(tc.args, DeepEquals, tc.expected)
 		}
 	}
 }
 
+func (s *FIOTestSuite) TestScratchDir(c *C) {
+	ctx := context.Background()
+	executor := &fakeKubeExecutor{}
+	stepper := &fioStepper{kubeExecutor: executor}
+	dir := VolumeMountPath + "/" + ScratchDirName
+	c.Assert(stepper.createScratchDir(ctx, "pod", "container", DefaultNS, dir), IsNil)
+	c.Assert(executor.keInCommand, DeepEquals, []string{"mkdir", "-p", dir})
+	c.Assert(stepper.deleteScratchDir(ctx, "pod", "container", DefaultNS, dir), IsNil)
+	c.Assert(executor.keInCommand, DeepEquals, []string{"rm", "-rf", dir})
+	executor.keErr = fmt.Errorf("exec error")
+	c.Assert(stepper.createScratchDir(ctx, "pod", "container", DefaultNS, dir), NotNil)
+	c.Assert(stepper.deleteScratchDir(ctx, "pod", "container", DefaultNS, dir), NotNil)
+}
+
 func (s *FIOTestSuite) TestDeletePod(c *C) {
 	ctx := context.Background()
 	stepper := &fioStepper{cli: fake.NewSimpleClientset(&v1.Pod{
@@ -762,6 +1536,14 @@ func (s *FIOTestSuite) TestFioTestFileName(c *C) {
 			retVal:     "",
 			errChecker: NotNil,
 		},
+		{
+			configMap: map[string]string{
+				"testfile.fio":       "some test data",
+				PreconditionFileName: "precondition data",
+			},
+			retVal:     "testfile.fio",
+			errChecker: IsNil,
+		},
 	} {
 		ret, err := fioTestFilename(tc.configMap)
 		c.Check(err, tc.errChecker)
@@ -835,7 +1617,7 @@ func (s *FIOTestSuite) TestRunFioCommand(c *C) {
 		stepper := &fioStepper{
 			kubeExecutor: tc.executor,
 		}
-		out, err := stepper.runFIOCommand(ctx, tc.podName, tc.containerName, tc.testFileName, DefaultNS)
+		out, err := stepper.runFIOCommand(ctx, tc.podName, tc.containerName, tc.testFileName, DefaultNS, []string{"--directory", VolumeMountPath}, fioRunOptions{outputFormat: OutputFormatJSON})
 		c.Check(err, tc.errChecker)
 		c.Assert(out, DeepEquals, tc.out)
 		c.Assert(tc.executor.keInPodName, Equals, tc.podName)
@@ -846,6 +1628,157 @@ func (s *FIOTestSuite) TestRunFioCommand(c *C) {
 		c.Assert(tc.executor.keInCommand[2], Equals, VolumeMountPath)
 		jobFilePath := fmt.Sprintf("%s/%s", ConfigMapMountPath, tc.testFileName)
 		c.Assert(tc.executor.keInCommand[3], Equals, jobFilePath)
+		c.Assert(tc.executor.keInCommand[4], Equals, "--output-format=json")
+	}
+}
+
+func (s *FIOTestSuite) TestRunFioCommandCancelled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	executor := &fakeKubeExecutor{keErr: context.Canceled}
+	stepper := &fioStepper{kubeExecutor: executor}
+	_, err := stepper.runFIOCommand(ctx, "pod", "container", "tfName", DefaultNS, nil, fioRunOptions{outputFormat: OutputFormatJSON})
+	c.Assert(err, NotNil)
+	c.Assert(errors.Is(err, context.Canceled), Equals, true)
+	// fio is stopped in the pod after the exec is abandoned
+	c.Assert(executor.keInPodName, Equals, "pod")
+	c.Assert(executor.keInCommand, DeepEquals, []string{"sh", "-c", stopFIOScript})
+}
+
+func (s *FIOTestSuite) TestCleanup(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	cleanup(ctx, func(ctx context.Context) error {
+		called = true
+		c.Assert(ctx.Err(), IsNil)
+		_, hasDeadline := ctx.Deadline()
+		c.Assert(hasDeadline, Equals, true)
+		return nil
+	})
+	c.Assert(called, Equals, true)
+}
+
+func (s *FIOTestSuite) TestOutputFormat(c *C) {
+	c.Check((&RunFIOArgs{}).outputFormat(), Equals, OutputFormatJSON)
+	c.Check((&RunFIOArgs{Histogram: true}).outputFormat(), Equals, OutputFormatJSONPlus)
+}
+
+func (s *FIOTestSuite) TestRunFioCommandStatusInterval(c *C) {
+	var parsedout FioResult
+	err := json.Unmarshal([]byte(parsableFioOutput), &parsedout)
+	c.Assert(err, IsNil)
+	executor := &fakeKubeExecutor{keStdOut: parsableFioOutput + "\n" + parsableFioOutput}
+	stepper := &fioStepper{kubeExecutor: executor}
+	var progress []FioResult
+	opts := fioRunOptions{
+		outputFormat:   OutputFormatJSON,
+		statusInterval: 2 * time.Second,
+		progress:       func(r FioResult) { progress = append(progress, r) },
+	}
+	out, err := stepper.runFIOCommand(context.Background(), "pod", "container", "tfName", DefaultNS, nil, opts)
+	c.Assert(err, IsNil)
+	c.Assert(out, DeepEquals, parsedout)
+	c.Assert(progress, HasLen, 2)
+	c.Assert(executor.keInCommand, DeepEquals, []string{"fio", ConfigMapMountPath + "/tfName", "--output-format=json", "--status-interval=2000ms"})
+}
+
+func (s *FIOTestSuite) TestFioStatsPrintLatency(c *C) {
+	stats := FioStats{
+		Iops: 100,
+		ClatNs: FioNS{
+			Min:    1000,
+			Max:    5000000,
+			Mean:   2000,
+			StdDev: 500,
+			N:      100,
+			Percentile: map[string]float64{
+				"50.000000": 1500,
+				"90.000000": 3000,
+				"99.000000": 9000,
+				"99.900000": 4000000,
+				"99.990000": 5000000,
+			},
+			Bins: map[string]int64{
+				"1500":    50,
+				"3000":    40,
+				"20000":   9,
+				"4000000": 1,
+			},
+		},
+	}
+	out := stats.Print()
+	c.Check(strings.Contains(out, "clat(usec): min=1.00 max=5000.00 avg=2.00 stdev=0.50"), Equals, true)
+	c.Check(strings.Contains(out, "clat percentiles(usec): p50=1.50 p90=3.00 p99=9.00 p99.9=4000.00\n"), Equals, true)
+	c.Check(strings.Contains(out, "clat histogram(usec): <10=90.00% <100=9.00% <10000=1.00%"), Equals, true)
+
+	// gtod_reduce results have no latencies to print
+	out = FioStats{Iops: 100}.Print()
+	c.Check(strings.Contains(out, "clat"), Equals, false)
+
+	// sync only reports the total latency
+	out = FioStats{LatNs: FioNS{N: 10, Percentile: map[string]float64{"99.000000": 2000000}}}.Print()
+	c.Check(strings.Contains(out, "clat percentiles(usec): p99=2000.00"), Equals, true)
+}
+
+func (s *FIOTestSuite) TestBinsPercentile(c *C) {
+	n := FioNS{Bins: map[string]int64{"100": 50, "200": 40, "1000": 9, "5000": 1}}
+	for _, tc := range []struct {
+		p     float64
+		value float64
+	}{
+		{p: 50, value: 100},
+		{p: 90, value: 200},
+		{p: 99, value: 1000},
+		{p: 99.9, value: 5000},
+	} {
+		value, ok := n.binsPercentile(tc.p)
+		c.Check(ok, Equals, true)
+		c.Check(value, Equals, tc.value)
+	}
+	_, ok := FioNS{}.binsPercentile(99)
+	c.Check(ok, Equals, false)
+}
+
+func (s *FIOTestSuite) TestCollectLogs(c *C) {
+	ctx := context.Background()
+	executor := &fakeKubeExecutor{keStdOut: "kubestr_bw.1.log"}
+	stepper := &fioStepper{kubeExecutor: executor}
+	files, err := stepper.collectLogs(ctx, "pod", "container", DefaultNS, LogDirPath)
+	c.Assert(err, IsNil)
+	c.Check(files, DeepEquals, map[string]string{"kubestr_bw.1.log": "kubestr_bw.1.log"})
+	c.Check(executor.keInCommand, DeepEquals, []string{"cat", LogDirPath + "/kubestr_bw.1.log"})
+
+	executor = &fakeKubeExecutor{keErr: fmt.Errorf("exec error")}
+	stepper = &fioStepper{kubeExecutor: executor}
+	_, err = stepper.collectLogs(ctx, "pod", "container", DefaultNS, LogDirPath)
+	c.Check(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestListFioTests(c *C) {
+	tests := ListFioTests()
+	c.Assert(tests, HasLen, len(fioJobs))
+	for i, test := range tests {
+		c.Check(test.Description, Not(Equals), "", Commentf(test.Name))
+		if i > 0 {
+			c.Check(tests[i-1].Name < test.Name, Equals, true)
+		}
+	}
+}
+
+func (s *FIOTestSuite) TestFioJobWrites(c *C) {
+	for _, tc := range []struct {
+		config string
+		writes bool
+	}{
+		{config: fioJobs[DefaultFIOJob].config, writes: true},
+		{config: fioJobs["randrw"].config, writes: true},
+		{config: "[job1]\nrw=randread\n[job2]\nreadwrite = read", writes: false},
+		{config: "[job1]\nrw=randread:8", writes: false},
+		{config: "[job1]\nrw=trim", writes: true},
+		{config: "[job1]\nbs=4k", writes: false},
+	} {
+		c.Check(fioJobWrites(tc.config), Equals, tc.writes)
 	}
 }
 
@@ -963,3 +1896,13 @@ func (fk *fakeKubeExecutor) exec(_ context.Context, namespace, podName, containe
 	fk.keInCommand = command
 	return fk.keStdOut, fk.keStrErr, fk.keErr
 }
+
+func (fk *fakeKubeExecutor) execStream(_ context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error {
+	fk.keInNS = namespace
+	fk.keInPodName = podName
+	fk.keInContainerName = containerName
+	fk.keInCommand = command
+	_, _ = io.WriteString(stdout, fk.keStdOut)
+	_, _ = io.WriteString(stderr, fk.keStrErr)
+	return fk.keErr
+}
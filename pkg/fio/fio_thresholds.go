@@ -0,0 +1,389 @@
+package fio
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// ThresholdMetric is the FIO metric a threshold is evaluated against
+type ThresholdMetric string
+
+const (
+	// ThresholdIOPS compares the IOPS of a job
+	ThresholdIOPS = ThresholdMetric("iops")
+	// ThresholdBW compares the bandwidth of a job in bytes per second, e.g. 100Mi
+	ThresholdBW = ThresholdMetric("bw")
+	// ThresholdLatMean compares the mean completion latency of a job, e.g. 2ms
+	ThresholdLatMean = ThresholdMetric("lat_mean")
+	// ThresholdLatP99 compares the 99th percentile completion latency of a job, e.g. 10ms
+	ThresholdLatP99 = ThresholdMetric("lat_p99")
+	// thresholdLatPercentilePrefix prefixes any latency percentile metric, e.g. lat_p99.9
+	thresholdLatPercentilePrefix = "lat_p"
+)
+
+// ThresholdStatus is the outcome of a threshold. The values match the kubestr status codes.
+type ThresholdStatus string
+
+const (
+	// ThresholdOK means the threshold was met
+	ThresholdOK = ThresholdStatus("OK")
+	// ThresholdWarning means the threshold could not be evaluated
+	ThresholdWarning = ThresholdStatus("Warning")
+	// ThresholdError means the threshold was not met
+	ThresholdError = ThresholdStatus("Error")
+)
+
+// fioDirections are the FioJobs stats a threshold can select
+var fioDirections = []string{"read", "write", "trim", "sync"}
+
+// Threshold is a pass/fail criterion for a FIO run.
+// The selector is a job name, a direction (read, write, trim, sync) or both as <job>.<direction>.
+// An empty selector matches every job and direction.
+type Threshold struct {
+	Metric   ThresholdMetric `json:"metric"`
+	Selector string          `json:"selector,omitempty"`
+	Min      string          `json:"min,omitempty"`
+	Max      string          `json:"max,omitempty"`
+}
+
+// ThresholdsFile is the format of a thresholds file
+type ThresholdsFile struct {
+	Thresholds []Threshold `json:"thresholds"`
+}
+
+// ThresholdResult is the outcome of a threshold for a single job and direction
+type ThresholdResult struct {
+	Threshold Threshold       `json:"threshold"`
+	Job       string          `json:"job,omitempty"`
+	Direction string          `json:"direction,omitempty"`
+	Value     float64         `json:"value,omitempty"`
+	Status    ThresholdStatus `json:"status"`
+	Message   string          `json:"message"`
+}
+
+func (t Threshold) Validate() error {
+	if _, err := t.percentile(); err != nil {
+		return err
+	}
+	if t.Min == "" && t.Max == "" {
+		return fmt.Errorf("threshold (%s) requires a min or max value", t.Metric)
+	}
+	for _, value := range []string{t.Min, t.Max} {
+		if value == "" {
+			continue
+		}
+		if _, err := t.parseValue(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t Threshold) String() string {
+	var bounds []string
+	if t.Min != "" {
+		bounds = append(bounds, "min "+t.Min)
+	}
+	if t.Max != "" {
+		bounds = append(bounds, "max "+t.Max)
+	}
+	return fmt.Sprintf("%s (%s)", t.Metric, strings.Join(bounds, ", "))
+}
+
+// percentile returns the latency percentile the metric refers to, 0 for non percentile metrics
+func (t Threshold) percentile() (float64, error) {
+	switch t.Metric {
+	case ThresholdIOPS, ThresholdBW, ThresholdLatMean:
+		return 0, nil
+	}
+	if p, found := strings.CutPrefix(string(t.Metric), thresholdLatPercentilePrefix); found {
+		value, err := strconv.ParseFloat(p, 64)
+		if err == nil && value > 0 && value <= 100 {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported threshold metric (%s)", t.Metric)
+}
+
+// parseValue converts a min or max value into the unit the metric is reported in
+func (t Threshold) parseValue(value string) (float64, error) {
+	switch {
+	case t.Metric == ThresholdIOPS:
+		iops, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid IOPS threshold (%s)", value)
+		}
+		return iops, nil
+	case t.Metric == ThresholdBW:
+		bw, err := resource.ParseQuantity(value)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid bandwidth threshold (%s)", value)
+		}
+		return bw.AsApproximateFloat64(), nil
+	}
+	lat, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid latency threshold (%s)", value)
+	}
+	return float64(lat.Nanoseconds()), nil
+}
+
+// formatValue renders a metric value in the same units the threshold is written in
+func (t Threshold) formatValue(value float64) string {
+	switch {
+	case t.Metric == ThresholdIOPS:
+		return fmt.Sprintf("%.2f", value)
+	case t.Metric == ThresholdBW:
+		return resource.NewQuantity(int64(value), resource.BinarySI).String() + "/s"
+	}
+	return time.Duration(value).String()
+}
+
+// matches reports whether the selector matches the given job and direction
+func (t Threshold) matches(job, direction string) bool {
+	switch t.Selector {
+	case "", job, direction, job + "." + direction:
+		return true
+	}
+	return false
+}
+
+// ThresholdsFromFlags creates thresholds from a map of selectors to values
+func ThresholdsFromFlags(metric ThresholdMetric, isMin bool, values map[string]string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for selector, value := range values {
+		t := Threshold{Metric: metric, Selector: selector}
+		if isMin {
+			t.Min = value
+		} else {
+			t.Max = value
+		}
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// LoadThresholdsFile reads thresholds from a YAML or JSON file
+func LoadThresholdsFile(path string) ([]Threshold, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "file reading error")
+	}
+	var file ThresholdsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse thresholds file (%s)", path)
+	}
+	for _, t := range file.Thresholds {
+		if err := t.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid threshold in file (%s)", path)
+		}
+	}
+	return file.Thresholds, nil
+}
+
+// EvaluateThresholds checks every job and direction of a FIO result against the thresholds,
+// the clones of a job with numjobs are evaluated together
+func EvaluateThresholds(result FioResult, thresholds []Threshold) []ThresholdResult {
+	var results []ThresholdResult
+	for _, t := range thresholds {
+		matched := false
+		for _, job := range result.Grouped().Jobs {
+			for _, direction := range fioDirections {
+				stats := job.stats(direction)
+				if !t.matches(job.name(), direction) || !stats.active() || !reported(t.Metric, direction) {
+					continue
+				}
+				matched = true
+				results = append(results, t.evaluate(job.name(), direction, stats))
+			}
+		}
+		if !matched {
+			results = append(results, ThresholdResult{
+				Threshold: t,
+				Status:    ThresholdWarning,
+				Message:   fmt.Sprintf("%s: no job matched selector (%s)", t, t.Selector),
+			})
+		}
+	}
+	return results
+}
+
+// ThresholdsFailed reports whether any threshold was not met
+func ThresholdsFailed(results []ThresholdResult) bool {
+	for _, r := range results {
+		if r.Status == ThresholdError {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Threshold) evaluate(job, direction string, stats FioStats) ThresholdResult {
+	res := ThresholdResult{
+		Threshold: t,
+		Job:       job,
+		Direction: direction,
+	}
+	value, ok := t.value(stats)
+	if !ok {
+		res.Status = ThresholdWarning
+		res.Message = fmt.Sprintf("%s %s %s: not reported by FIO (latencies need a job without gtod_reduce, percentiles one of percentile_list)", job, direction, t.Metric)
+		return res
+	}
+	res.Value = value
+	res.Status = ThresholdOK
+	// the values were checked by Validate
+	if t.Min != "" {
+		if minValue, _ := t.parseValue(t.Min); value < minValue {
+			res.Status = ThresholdError
+		}
+	}
+	if t.Max != "" {
+		if maxValue, _ := t.parseValue(t.Max); value > maxValue {
+			res.Status = ThresholdError
+		}
+	}
+	res.Message = fmt.Sprintf("%s %s %s=%s, expected %s", job, direction, t.Metric, t.formatValue(value), t)
+	if p, _ := t.percentile(); p > 0 && stats.latency().ApproxPercentile {
+		res.Message += " (approximate: highest of the merged jobs)"
+	}
+	return res
+}
+
+// value extracts the metric from the stats of a single direction
+func (t Threshold) value(stats FioStats) (float64, bool) {
+	switch t.Metric {
+	case ThresholdIOPS:
+		return stats.Iops, true
+	case ThresholdBW:
+		return float64(stats.BWBytes), true
+	}
+	lat := stats.latency()
+	if t.Metric == ThresholdLatMean {
+		return lat.Mean, lat.N > 0
+	}
+	p, err := t.percentile()
+	if err != nil {
+		return 0, false
+	}
+	return lat.percentile(p)
+}
+
+// Metric returns a metric of a direction over every job of the result: IOPS and bandwidth are
+// summed, latencies are the highest of the jobs. The clones of a job with numjobs are merged first.
+func (f FioResult) Metric(direction string, metric ThresholdMetric) (float64, bool) {
+	t := Threshold{Metric: metric}
+	var total float64
+	found := false
+	for _, job := range f.Grouped().Jobs {
+		stats := job.stats(direction)
+		if !stats.active() {
+			continue
+		}
+		value, ok := t.value(stats)
+		if !ok {
+			continue
+		}
+		switch metric {
+		case ThresholdIOPS, ThresholdBW:
+			total += value
+		default:
+			total = math.Max(total, value)
+		}
+		found = true
+	}
+	return total, found
+}
+
+// JobMetric is a metric of a single job and direction
+type JobMetric struct {
+	Job       string
+	Direction string
+	Metric    ThresholdMetric
+	Value     float64
+}
+
+// JobMetrics returns the IOPS, bandwidth and mean and 99th percentile latency of every job and
+// direction of the result. The clones of a job with numjobs are merged first.
+func (f FioResult) JobMetrics() []JobMetric {
+	var jobMetrics []JobMetric
+	for _, job := range f.Grouped().Jobs {
+		for _, direction := range fioDirections {
+			stats := job.stats(direction)
+			if !stats.active() {
+				continue
+			}
+			for _, metric := range []ThresholdMetric{ThresholdIOPS, ThresholdBW, ThresholdLatMean, ThresholdLatP99} {
+				if !reported(metric, direction) {
+					continue
+				}
+				if value, ok := (Threshold{Metric: metric}).value(stats); ok {
+					jobMetrics = append(jobMetrics, JobMetric{Job: job.name(), Direction: direction, Metric: metric, Value: value})
+				}
+			}
+		}
+	}
+	return jobMetrics
+}
+
+// reported reports whether fio reports the metric for the direction, it only reports the latency of syncs
+func reported(metric ThresholdMetric, direction string) bool {
+	return direction != "sync" || (metric != ThresholdIOPS && metric != ThresholdBW)
+}
+
+// percentile looks up a latency percentile in ns, fio reports them with keys like "99.000000"
+func (n FioNS) percentile(p float64) (float64, bool) {
+	for key, value := range n.Percentile {
+		if kp, err := strconv.ParseFloat(key, 64); err == nil && math.Abs(kp-p) < 1e-6 {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// latency returns the completion latency, or the total latency for stats that only report that (sync)
+func (s FioStats) latency() FioNS {
+	if s.ClatNs.N > 0 || len(s.ClatNs.Percentile) > 0 {
+		return s.ClatNs
+	}
+	return s.LatNs
+}
+
+// active reports whether the direction issued any I/O
+func (s FioStats) active() bool {
+	return s.TotalIos > 0 || s.Iops > 0 || s.BW > 0
+}
+
+// stats returns the stats of the given direction
+func (j FioJobs) stats(direction string) FioStats {
+	switch direction {
+	case "read":
+		return j.Read
+	case "write":
+		return j.Write
+	case "trim":
+		return j.Trim
+	case "sync":
+		return j.Sync
+	}
+	return FioStats{}
+}
+
+// name returns the name of the job as set in the job file
+func (j FioJobs) name() string {
+	if j.JobOptions.Name != "" {
+		return j.JobOptions.Name
+	}
+	return j.JobName
+}
@@ -0,0 +1,164 @@
+package fio
+
+import (
+	"encoding/json"
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FIOTestSuite) TestThresholdValidate(c *C) {
+	for _, tc := range []struct {
+		t          Threshold
+		errChecker Checker
+	}{
+		{t: Threshold{Metric: ThresholdIOPS, Min: "5000"}, errChecker: IsNil},
+		{t: Threshold{Metric: ThresholdIOPS, Min: "lots"}, errChecker: NotNil},
+		{t: Threshold{Metric: ThresholdBW, Min: "100Mi"}, errChecker: IsNil},
+		{t: Threshold{Metric: ThresholdBW, Min: "100 MB"}, errChecker: NotNil},
+		{t: Threshold{Metric: ThresholdLatP99, Max: "10ms"}, errChecker: IsNil},
+		{t: Threshold{Metric: "lat_p99.9", Max: "10ms"}, errChecker: IsNil},
+		{t: Threshold{Metric: ThresholdLatMean, Max: "10"}, errChecker: NotNil},
+		{t: Threshold{Metric: "lat_p101", Max: "10ms"}, errChecker: NotNil},
+		{t: Threshold{Metric: "latency", Max: "10ms"}, errChecker: NotNil},
+		{t: Threshold{Metric: ThresholdIOPS}, errChecker: NotNil},
+	} {
+		c.Check(tc.t.Validate(), tc.errChecker, Commentf("%+v", tc.t))
+	}
+}
+
+func (s *FIOTestSuite) TestThresholdsFromFlags(c *C) {
+	thresholds, err := ThresholdsFromFlags(ThresholdLatP99, false, map[string]string{"write": "10ms"})
+	c.Assert(err, IsNil)
+	c.Assert(thresholds, DeepEquals, []Threshold{{Metric: ThresholdLatP99, Selector: "write", Max: "10ms"}})
+	_, err = ThresholdsFromFlags(ThresholdIOPS, true, map[string]string{"read_iops": "many"})
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestLoadThresholdsFile(c *C) {
+	file, err := os.CreateTemp("", "thresholds")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Check(os.Remove(file.Name()), IsNil)
+	}()
+	_, err = file.WriteString(`thresholds:
+- metric: iops
+  selector: read_iops
+  min: "5000"
+- metric: lat_p99
+  selector: write
+  max: 10ms
+`)
+	c.Assert(err, IsNil)
+	thresholds, err := LoadThresholdsFile(file.Name())
+	c.Assert(err, IsNil)
+	c.Assert(thresholds, DeepEquals, []Threshold{
+		{Metric: ThresholdIOPS, Selector: "read_iops", Min: "5000"},
+		{Metric: ThresholdLatP99, Selector: "write", Max: "10ms"},
+	})
+
+	_, err = LoadThresholdsFile("nonexistantfile")
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestEvaluateThresholds(c *C) {
+	var parsed FioResult
+	c.Assert(json.Unmarshal([]byte(parsableFioOutput), &parsed), IsNil)
+	results := EvaluateThresholds(parsed, []Threshold{
+		{Metric: ThresholdIOPS, Selector: "read_iops", Min: "500"},
+		{Metric: ThresholdIOPS, Selector: "write_iops.write", Min: "5000"},
+		{Metric: ThresholdLatP99, Selector: "write", Max: "10ms"},
+		{Metric: ThresholdBW, Selector: "missing", Min: "1Mi"},
+	})
+	c.Assert(results, HasLen, 4)
+	c.Check(results[0].Job, Equals, "read_iops")
+	c.Check(results[0].Direction, Equals, "read")
+	c.Check(results[0].Status, Equals, ThresholdOK)
+	c.Check(results[1].Status, Equals, ThresholdError)
+	// the sample output is of a job with gtod_reduce, latencies are not reported
+	c.Check(results[2].Job, Equals, "write_iops")
+	c.Check(results[2].Status, Equals, ThresholdWarning)
+	c.Check(results[3].Job, Equals, "")
+	c.Check(results[3].Status, Equals, ThresholdWarning)
+	c.Check(ThresholdsFailed(results), Equals, true)
+	c.Check(ThresholdsFailed(results[2:]), Equals, false)
+
+	// fio only reports the latency of syncs, IOPS and bandwidth are checked on the other directions
+	fsync := FioResult{Jobs: []FioJobs{{
+		JobName: "fsync",
+		Write:   FioStats{TotalIos: 10, Iops: 1000},
+		Sync:    FioStats{TotalIos: 10, LatNs: FioNS{N: 10, Mean: 2000}},
+	}}}
+	results = EvaluateThresholds(fsync, []Threshold{
+		{Metric: ThresholdIOPS, Selector: "fsync", Min: "500"},
+		{Metric: ThresholdBW, Selector: "fsync.sync", Min: "1Mi"},
+		{Metric: ThresholdLatMean, Selector: "fsync.sync", Max: "1ms"},
+	})
+	c.Assert(results, HasLen, 3)
+	c.Check(results[0].Direction, Equals, "write")
+	c.Check(results[0].Status, Equals, ThresholdOK)
+	c.Check(results[1].Job, Equals, "")
+	c.Check(results[1].Status, Equals, ThresholdWarning)
+	c.Check(results[2].Direction, Equals, "sync")
+	c.Check(results[2].Status, Equals, ThresholdOK)
+}
+
+func (s *FIOTestSuite) TestThresholdLatency(c *C) {
+	stats := FioStats{
+		TotalIos: 100,
+		ClatNs: FioNS{
+			Mean: 2000000,
+			N:    100,
+			Percentile: map[string]float64{
+				"50.000000": 1000000,
+				"99.000000": 12000000,
+				"99.900000": 20000000,
+			},
+		},
+	}
+	for _, tc := range []struct {
+		t      Threshold
+		status ThresholdStatus
+	}{
+		{t: Threshold{Metric: ThresholdLatP99, Max: "10ms"}, status: ThresholdError},
+		{t: Threshold{Metric: ThresholdLatP99, Max: "15ms"}, status: ThresholdOK},
+		{t: Threshold{Metric: "lat_p99.9", Max: "15ms"}, status: ThresholdError},
+		{t: Threshold{Metric: "lat_p50", Max: "1ms"}, status: ThresholdOK},
+		{t: Threshold{Metric: "lat_p90", Max: "1ms"}, status: ThresholdWarning},
+		{t: Threshold{Metric: ThresholdLatMean, Max: "1ms"}, status: ThresholdError},
+	} {
+		c.Check(tc.t.evaluate("job", "read", stats).Status, Equals, tc.status, Commentf("%s", tc.t))
+	}
+	// sync only reports total latency
+	sync := FioStats{TotalIos: 10, LatNs: FioNS{Percentile: map[string]float64{"99.000000": 5000000}}}
+	c.Check(Threshold{Metric: ThresholdLatP99, Max: "10ms"}.evaluate("job", "sync", sync).Status, Equals, ThresholdOK)
+}
+
+func (s *FIOTestSuite) TestFioResultMetric(c *C) {
+	result := FioResult{Jobs: []FioJobs{
+		{JobName: "a", Read: FioStats{TotalIos: 10, Iops: 100, ClatNs: FioNS{N: 10, Percentile: map[string]float64{"99.000000": 2000}}}},
+		{JobName: "b", Read: FioStats{TotalIos: 10, Iops: 50, ClatNs: FioNS{N: 10, Percentile: map[string]float64{"99.000000": 5000}}}},
+	}}
+	iops, ok := result.Metric("read", ThresholdIOPS)
+	c.Check(ok, Equals, true)
+	c.Check(iops, Equals, float64(150))
+	p99, ok := result.Metric("read", ThresholdLatP99)
+	c.Check(ok, Equals, true)
+	c.Check(p99, Equals, float64(5000))
+	_, ok = result.Metric("write", ThresholdIOPS)
+	c.Check(ok, Equals, false)
+}
+
+func (s *FIOTestSuite) TestFioResultJobMetrics(c *C) {
+	result := FioResult{Jobs: []FioJobs{
+		{JobName: "a", Read: FioStats{TotalIos: 10, Iops: 100, BWBytes: 409600, ClatNs: FioNS{N: 10, Mean: 1000, Percentile: map[string]float64{"99.000000": 2000}}}},
+		{JobName: "b", Sync: FioStats{TotalIos: 10, LatNs: FioNS{N: 10, Mean: 500}}},
+	}}
+	c.Assert(result.JobMetrics(), DeepEquals, []JobMetric{
+		{Job: "a", Direction: "read", Metric: ThresholdIOPS, Value: 100},
+		{Job: "a", Direction: "read", Metric: ThresholdBW, Value: 409600},
+		{Job: "a", Direction: "read", Metric: ThresholdLatMean, Value: 1000},
+		{Job: "a", Direction: "read", Metric: ThresholdLatP99, Value: 2000},
+		{Job: "b", Direction: "sync", Metric: ThresholdLatMean, Value: 500},
+	})
+}
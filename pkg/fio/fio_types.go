@@ -1,6 +1,13 @@
 package fio
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
 
 type FioResult struct {
 	FioVersion    string           `json:"fio version,omitempty"`
@@ -9,6 +16,7 @@ type FioResult struct {
 	Time          string           `json:"time,omitempty"`
 	GlobalOptions FioGlobalOptions `json:"global options,omitempty"`
 	Jobs          []FioJobs        `json:"jobs,omitempty"`
+	ClientStats   []FioJobs        `json:"client_stats,omitempty"` // the jobs of every server when fio runs as a client
 	DiskUtil      []FioDiskUtil    `json:"disk_util,omitempty"`
 }
 
@@ -27,6 +35,51 @@ func (f FioResult) Print() string {
 	return res
 }
 
+// Grouped merges the jobs fio clones for numjobs into one job per name and group, like
+// group_reporting does. Throughput is summed, latencies are combined and CPU usage is averaged.
+// Output that was already reported with group_reporting is left as is.
+func (f FioResult) Grouped() FioResult {
+	type groupKey struct {
+		group int
+		name  string
+	}
+	index := map[groupKey]int{}
+	counts := map[int]int{}
+	var jobs []FioJobs
+	for _, job := range f.Jobs {
+		key := groupKey{group: job.GroupID, name: job.name()}
+		i, found := index[key]
+		if !found {
+			index[key] = len(jobs)
+			counts[len(jobs)] = 1
+			jobs = append(jobs, job)
+			continue
+		}
+		counts[i]++
+		jobs[i] = mergeFioJobs(jobs[i], job, counts[i])
+	}
+	f.Jobs = jobs
+	return f
+}
+
+// mergeFioJobs adds the n-th clone of a job to the merged job
+func mergeFioJobs(merged, clone FioJobs, n int) FioJobs {
+	merged.Read = aggregateFioStats(merged.Read, clone.Read)
+	merged.Write = aggregateFioStats(merged.Write, clone.Write)
+	merged.Trim = aggregateFioStats(merged.Trim, clone.Trim)
+	merged.Sync = aggregateFioStats(merged.Sync, clone.Sync)
+	merged.JobRuntime = max(merged.JobRuntime, clone.JobRuntime)
+	merged.UsrCpu += (clone.UsrCpu - merged.UsrCpu) / float64(n)
+	merged.SysCpu += (clone.SysCpu - merged.SysCpu) / float64(n)
+	merged.Ctx += clone.Ctx
+	merged.MajF += clone.MajF
+	merged.MinF += clone.MinF
+	if merged.Error == 0 {
+		merged.Error = clone.Error
+	}
+	return merged
+}
+
 type FioGlobalOptions struct {
 	Directory  string `json:"directory,omitempty"`
 	RandRepeat string `json:"randrepeat,omitempty"`
@@ -34,6 +87,23 @@ type FioGlobalOptions struct {
 	IOEngine   string `json:"ioengine,omitempty"`
 	Direct     string `json:"direct,omitempty"`
 	GtodReduce string `json:"gtod_reduce,omitempty"`
+	// Options holds every global option, including the ones above
+	Options map[string]string `json:"-"`
+}
+
+func (g *FioGlobalOptions) UnmarshalJSON(data []byte) error {
+	type plain FioGlobalOptions
+	if err := json.Unmarshal(data, (*plain)(g)); err != nil {
+		return err
+	}
+	options, err := decodeFioOptions(data)
+	g.Options = options
+	return err
+}
+
+func (g FioGlobalOptions) MarshalJSON() ([]byte, error) {
+	type plain FioGlobalOptions
+	return marshalFioOptions(plain(g), g.Options)
 }
 
 func (g FioGlobalOptions) Print() string {
@@ -41,43 +111,55 @@ func (g FioGlobalOptions) Print() string {
 }
 
 type FioJobs struct {
-	JobName           string        `json:"jobname,omitempty"`
-	GroupID           int           `json:"groupid,omitempty"`
-	Error             int           `json:"error,omitempty"`
-	Eta               int           `json:"eta,omitempty"`
-	Elapsed           int           `json:"elapsed,omitempty"`
-	JobOptions        FioJobOptions `json:"job options,omitempty"`
-	Read              FioStats      `json:"read,omitempty"`
-	Write             FioStats      `json:"write,omitempty"`
-	Trim              FioStats      `json:"trim,omitempty"`
-	Sync              FioStats      `json:"sync,omitempty"`
-	JobRuntime        int32         `json:"job_runtime,omitempty"`
-	UsrCpu            float32       `json:"usr_cpu,omitempty"`
-	SysCpu            float32       `json:"sys_cpu,omitempty"`
-	Ctx               int32         `json:"ctx,omitempty"`
-	MajF              int32         `json:"majf,omitempty"`
-	MinF              int32         `json:"minf,omitempty"`
-	IoDepthLevel      FioDepth      `json:"iodepth_level,omitempty"`
-	IoDepthSubmit     FioDepth      `json:"iodepth_submit,omitempty"`
-	IoDepthComplete   FioDepth      `json:"iodepth_complete,omitempty"`
-	LatencyNs         FioLatency    `json:"latency_ns,omitempty"`
-	LatencyUs         FioLatency    `json:"latency_us,omitempty"`
-	LatencyMs         FioLatency    `json:"latency_ms,omitempty"`
-	LatencyDepth      int32         `json:"latency_depth,omitempty"`
-	LatencyTarget     int32         `json:"latency_target,omitempty"`
-	LatencyPercentile float32       `json:"latency_percentile,omitempty"`
-	LatencyWindow     int32         `json:"latency_window,omitempty"`
+	JobName           string          `json:"jobname,omitempty"`
+	Hostname          string          `json:"hostname,omitempty"` // the server that ran the job in client/server mode
+	GroupID           int             `json:"groupid,omitempty"`
+	Error             int             `json:"error,omitempty"`
+	Eta               int             `json:"eta,omitempty"`
+	Elapsed           int             `json:"elapsed,omitempty"`
+	JobStart          int64           `json:"job_start,omitempty"`
+	JobOptions        FioJobOptions   `json:"job options,omitempty"`
+	Read              FioStats        `json:"read,omitempty"`
+	Write             FioStats        `json:"write,omitempty"`
+	Trim              FioStats        `json:"trim,omitempty"`
+	Sync              FioStats        `json:"sync,omitempty"`
+	JobRuntime        int64           `json:"job_runtime,omitempty"`
+	UsrCpu            float64         `json:"usr_cpu,omitempty"`
+	SysCpu            float64         `json:"sys_cpu,omitempty"`
+	Ctx               int64           `json:"ctx,omitempty"`
+	MajF              int64           `json:"majf,omitempty"`
+	MinF              int64           `json:"minf,omitempty"`
+	IoDepthLevel      FioDepth        `json:"iodepth_level,omitempty"`
+	IoDepthSubmit     FioDepth        `json:"iodepth_submit,omitempty"`
+	IoDepthComplete   FioDepth        `json:"iodepth_complete,omitempty"`
+	LatencyNs         FioLatency      `json:"latency_ns,omitempty"`
+	LatencyUs         FioLatency      `json:"latency_us,omitempty"`
+	LatencyMs         FioLatency      `json:"latency_ms,omitempty"`
+	LatencyDepth      int64           `json:"latency_depth,omitempty"`
+	LatencyTarget     int64           `json:"latency_target,omitempty"`
+	LatencyPercentile float64         `json:"latency_percentile,omitempty"`
+	LatencyWindow     int64           `json:"latency_window,omitempty"`
+	SteadyState       *FioSteadyState `json:"steadystate,omitempty"`
 }
 
 func (j FioJobs) Print() string {
 	var job string
 	job += fmt.Sprintf("%s\n", j.JobOptions.Print())
+	if j.Error != 0 {
+		job += fmt.Sprintf("error: %d (%s)\n", j.Error, syscall.Errno(j.Error).Error())
+	}
+	if ss, ok := j.steadyStateResult(); ok {
+		job += fmt.Sprintf("steady state: %s\n", ss.Summary())
+	}
 	if j.Read.Iops != 0 || j.Read.BW != 0 {
 		job += fmt.Sprintf("read:\n%s\n", j.Read.Print())
 	}
 	if j.Write.Iops != 0 || j.Write.BW != 0 {
 		job += fmt.Sprintf("write:\n%s\n", j.Write.Print())
 	}
+	if latency := j.Sync.printLatency(); latency != "" {
+		job += fmt.Sprintf("sync:\n%s\n", latency)
+	}
 	return job
 }
 
@@ -89,10 +171,80 @@ type FioJobOptions struct {
 	RW       string `json:"rw,omitempty"`
 	RampTime string `json:"ramp_time,omitempty"`
 	RunTime  string `json:"runtime,omitempty"`
+	// Options holds every option of the job, including the ones above
+	Options map[string]string `json:"-"`
+}
+
+func (o *FioJobOptions) UnmarshalJSON(data []byte) error {
+	type plain FioJobOptions
+	if err := json.Unmarshal(data, (*plain)(o)); err != nil {
+		return err
+	}
+	options, err := decodeFioOptions(data)
+	o.Options = options
+	return err
+}
+
+func (o FioJobOptions) MarshalJSON() ([]byte, error) {
+	type plain FioJobOptions
+	return marshalFioOptions(plain(o), o.Options)
 }
 
 func (o FioJobOptions) Print() string {
-	return fmt.Sprintf("JobName: %s\n  blocksize=%s filesize=%s iodepth=%s rw=%s", o.Name, o.BS, o.Size, o.IoDepth, o.RW)
+	res := fmt.Sprintf("JobName: %s\n  blocksize=%s filesize=%s iodepth=%s rw=%s", o.Name, o.BS, o.Size, o.IoDepth, o.RW)
+	var other []string
+	for key, value := range o.Options {
+		if !printedJobOptions[key] {
+			other = append(other, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	if len(other) > 0 {
+		sort.Strings(other)
+		res += fmt.Sprintf("\n  %s", strings.Join(other, " "))
+	}
+	return res
+}
+
+// printedJobOptions are the job options always shown in the report
+var printedJobOptions = map[string]bool{"name": true, "bs": true, "size": true, "iodepth": true, "rw": true}
+
+// decodeFioOptions decodes an options object of the fio output. fio reports every value as a
+// string, anything else is kept as its JSON text.
+func decodeFioOptions(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	options := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			s = string(value)
+		}
+		options[key] = s
+	}
+	return options, nil
+}
+
+// marshalFioOptions writes the options back as fio reported them, the named fields take
+// precedence over the map in case they were changed
+func marshalFioOptions(named interface{}, options map[string]string) ([]byte, error) {
+	data, err := json.Marshal(named)
+	if err != nil || len(options) == 0 {
+		return data, err
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	merged := make(map[string]string, len(options)+len(fields))
+	for key, value := range options {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return json.Marshal(merged)
 }
 
 type FioStats struct {
@@ -100,7 +252,7 @@ type FioStats struct {
 	IOKBytes    int64   `json:"io_kbytes,omitempty"`
 	BWBytes     int64   `json:"bw_bytes,omitempty"`
 	BW          int64   `json:"bw,omitempty"`
-	Iops        float32 `json:"iops,omitempty"`
+	Iops        float64 `json:"iops,omitempty"`
 	Runtime     int64   `json:"runtime,omitempty"`
 	TotalIos    int64   `json:"total_ios,omitempty"`
 	ShortIos    int64   `json:"short_ios,omitempty"`
@@ -110,15 +262,29 @@ type FioStats struct {
 	LatNs       FioNS   `json:"lat_ns,omitempty"`
 	BwMin       int64   `json:"bw_min,omitempty"`
 	BwMax       int64   `json:"bw_max,omitempty"`
-	BwAgg       float32 `json:"bw_agg,omitempty"`
-	BwMean      float32 `json:"bw_mean,omitempty"`
-	BwDev       float32 `json:"bw_dev,omitempty"`
-	BwSamples   int32   `json:"bw_samples,omitempty"`
-	IopsMin     int32   `json:"iops_min,omitempty"`
-	IopsMax     int32   `json:"iops_max,omitempty"`
-	IopsMean    float32 `json:"iops_mean,omitempty"`
-	IopsStdDev  float32 `json:"iops_stddev,omitempty"`
-	IopsSamples int32   `json:"iops_samples,omitempty"`
+	BwAgg       float64 `json:"bw_agg,omitempty"`
+	BwMean      float64 `json:"bw_mean,omitempty"`
+	BwDev       float64 `json:"bw_dev,omitempty"`
+	BwSamples   int64   `json:"bw_samples,omitempty"`
+	IopsMin     int64   `json:"iops_min,omitempty"`
+	IopsMax     int64   `json:"iops_max,omitempty"`
+	IopsMean    float64 `json:"iops_mean,omitempty"`
+	IopsStdDev  float64 `json:"iops_stddev,omitempty"`
+	IopsSamples int64   `json:"iops_samples,omitempty"`
+}
+
+func (s *FioStats) UnmarshalJSON(data []byte) error {
+	type plain FioStats
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	// older fio versions don't report the number of latency samples
+	for _, lat := range []*FioNS{&s.SlatNs, &s.ClatNs, &s.LatNs} {
+		if lat.N == 0 && lat.Max > 0 {
+			lat.N = s.TotalIos
+		}
+	}
+	return nil
 }
 
 func (s FioStats) Print() string {
@@ -126,42 +292,168 @@ func (s FioStats) Print() string {
 	stats += fmt.Sprintf("  IOPS=%f BW(KiB/s)=%d\n", s.Iops, s.BW)
 	stats += fmt.Sprintf("  iops: min=%d max=%d avg=%f\n", s.IopsMin, s.IopsMax, s.IopsMean)
 	stats += fmt.Sprintf("  bw(KiB/s): min=%d max=%d avg=%f", s.BwMin, s.BwMax, s.BwMean)
+	if latency := s.printLatency(); latency != "" {
+		stats += "\n" + latency
+	}
 	return stats
 }
 
+// printLatency prints the completion latency, its percentiles and histogram when fio reported them
+func (s FioStats) printLatency() string {
+	var lines []string
+	lat := s.latency()
+	if lat.N > 0 {
+		lines = append(lines, fmt.Sprintf("  clat(usec): min=%.2f max=%.2f avg=%.2f stdev=%.2f", nsToUsec(float64(lat.Min)), nsToUsec(float64(lat.Max)), nsToUsec(lat.Mean), nsToUsec(lat.StdDev)))
+	}
+	if percentiles := lat.printPercentiles(); percentiles != "" {
+		label := "clat percentiles(usec)"
+		if lat.ApproxPercentile {
+			label = "clat percentiles(usec, approximate: highest of the merged jobs)"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", label, percentiles))
+	}
+	if histogram := lat.printHistogram(); histogram != "" {
+		lines = append(lines, fmt.Sprintf("  clat histogram(usec): %s", histogram))
+	}
+	return strings.Join(lines, "\n")
+}
+
 type FioNS struct {
-	Min    int64   `json:"min,omitempty"`
-	Max    int64   `json:"max,omitempty"`
-	Mean   float32 `json:"mean,omitempty"`
-	StdDev float32 `json:"stddev,omitempty"`
-	N      int64   `json:"N,omitempty"`
+	Min        int64              `json:"min,omitempty"`
+	Max        int64              `json:"max,omitempty"`
+	Mean       float64            `json:"mean,omitempty"`
+	StdDev     float64            `json:"stddev,omitempty"`
+	N          int64              `json:"N,omitempty"`
+	Percentile map[string]float64 `json:"percentile,omitempty"`
+	Bins       map[string]int64   `json:"bins,omitempty"` // only reported with json+ output
+	// ApproxPercentile is set by kubestr when merged latencies had no bins to recompute the
+	// percentiles from, they are the highest percentiles of the merged latencies instead
+	ApproxPercentile bool `json:"approx_percentile,omitempty"`
+}
+
+// printedPercentiles are the latency percentiles shown in the report
+var printedPercentiles = []float64{50, 90, 99, 99.9}
+
+// histogramBuckets are the upper bounds in ns of the buckets the histogram bins are grouped into
+var histogramBuckets = []int64{10e3, 100e3, 1e6, 10e6, 100e6, 1e9}
+
+func (n FioNS) printPercentiles() string {
+	var res string
+	for _, p := range printedPercentiles {
+		value, ok := n.percentile(p)
+		if !ok {
+			continue
+		}
+		if res != "" {
+			res += " "
+		}
+		res += fmt.Sprintf("p%s=%.2f", strconv.FormatFloat(p, 'f', -1, 64), nsToUsec(value))
+	}
+	return res
+}
+
+// printHistogram groups the histogram bins into decades and prints the share of each
+func (n FioNS) printHistogram() string {
+	bins := n.sortedBins()
+	var total int64
+	for _, b := range bins {
+		total += b.count
+	}
+	if total == 0 {
+		return ""
+	}
+	counts := make([]int64, len(histogramBuckets)+1)
+	for _, b := range bins {
+		i := sort.Search(len(histogramBuckets), func(i int) bool { return b.ns < histogramBuckets[i] })
+		counts[i] += b.count
+	}
+	var res string
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if res != "" {
+			res += " "
+		}
+		share := 100 * float64(count) / float64(total)
+		if i < len(histogramBuckets) {
+			res += fmt.Sprintf("<%d=%.2f%%", int64(nsToUsec(float64(histogramBuckets[i]))), share)
+		} else {
+			res += fmt.Sprintf(">=%d=%.2f%%", int64(nsToUsec(float64(histogramBuckets[i-1]))), share)
+		}
+	}
+	return res
+}
+
+type fioBin struct {
+	ns    int64
+	count int64
+}
+
+// sortedBins returns the histogram bins ordered by latency, fio reports them keyed by ns
+func (n FioNS) sortedBins() []fioBin {
+	var bins []fioBin
+	for key, count := range n.Bins {
+		ns, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		bins = append(bins, fioBin{ns: ns, count: count})
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i].ns < bins[j].ns })
+	return bins
+}
+
+// binsPercentile computes a latency percentile in ns from the histogram bins
+func (n FioNS) binsPercentile(p float64) (float64, bool) {
+	bins := n.sortedBins()
+	var total int64
+	for _, b := range bins {
+		total += b.count
+	}
+	if total == 0 {
+		return 0, false
+	}
+	target := p / 100 * float64(total)
+	var seen int64
+	for _, b := range bins {
+		seen += b.count
+		if float64(seen) >= target {
+			return float64(b.ns), true
+		}
+	}
+	return float64(bins[len(bins)-1].ns), true
+}
+
+func nsToUsec(ns float64) float64 {
+	return ns / 1e3
 }
 
 type FioDepth struct {
-	FioDepth0    float32 `json:"0,omitempty"`
-	FioDepth1    float32 `json:"1,omitempty"`
-	FioDepth2    float32 `json:"2,omitempty"`
-	FioDepth4    float32 `json:"4,omitempty"`
-	FioDepth8    float32 `json:"8,omitempty"`
-	FioDepth16   float32 `json:"16,omitempty"`
-	FioDepth32   float32 `json:"32,omitempty"`
-	FioDepth64   float32 `json:"64,omitempty"`
-	FioDepthGE64 float32 `json:">=64,omitempty"`
+	FioDepth0    float64 `json:"0,omitempty"`
+	FioDepth1    float64 `json:"1,omitempty"`
+	FioDepth2    float64 `json:"2,omitempty"`
+	FioDepth4    float64 `json:"4,omitempty"`
+	FioDepth8    float64 `json:"8,omitempty"`
+	FioDepth16   float64 `json:"16,omitempty"`
+	FioDepth32   float64 `json:"32,omitempty"`
+	FioDepth64   float64 `json:"64,omitempty"`
+	FioDepthGE64 float64 `json:">=64,omitempty"`
 }
 
 type FioLatency struct {
-	FioLat2      float32 `json:"2,omitempty"`
-	FioLat4      float32 `json:"4,omitempty"`
-	FioLat10     float32 `json:"10,omitempty"`
-	FioLat20     float32 `json:"20,omitempty"`
-	FioLat50     float32 `json:"50,omitempty"`
-	FioLat100    float32 `json:"100,omitempty"`
-	FioLat250    float32 `json:"250,omitempty"`
-	FioLat500    float32 `json:"500,omitempty"`
-	FioLat750    float32 `json:"750,omitempty"`
-	FioLat1000   float32 `json:"1000,omitempty"`
-	FioLat2000   float32 `json:"2000,omitempty"`
-	FioLatGE2000 float32 `json:">=2000,omitempty"`
+	FioLat2      float64 `json:"2,omitempty"`
+	FioLat4      float64 `json:"4,omitempty"`
+	FioLat10     float64 `json:"10,omitempty"`
+	FioLat20     float64 `json:"20,omitempty"`
+	FioLat50     float64 `json:"50,omitempty"`
+	FioLat100    float64 `json:"100,omitempty"`
+	FioLat250    float64 `json:"250,omitempty"`
+	FioLat500    float64 `json:"500,omitempty"`
+	FioLat750    float64 `json:"750,omitempty"`
+	FioLat1000   float64 `json:"1000,omitempty"`
+	FioLat2000   float64 `json:"2000,omitempty"`
+	FioLatGE2000 float64 `json:">=2000,omitempty"`
 }
 
 type FioDiskUtil struct {
@@ -173,7 +465,16 @@ type FioDiskUtil struct {
 	ReadTicks   int64   `json:"read_ticks,omitempty"`
 	WriteTicks  int64   `json:"write_ticks,omitempty"`
 	InQueue     int64   `json:"in_queue,omitempty"`
-	Util        float32 `json:"util,omitempty"`
+	Util        float64 `json:"util,omitempty"`
+	// aggregated stats of the member devices of a software RAID or device mapper device
+	AggrReadIos     int64   `json:"aggr_read_ios,omitempty"`
+	AggrWriteIos    int64   `json:"aggr_write_ios,omitempty"`
+	AggrReadMerges  int64   `json:"aggr_read_merges,omitempty"`
+	AggrWriteMerges int64   `json:"aggr_write_merge,omitempty"`
+	AggrReadTicks   int64   `json:"aggr_read_ticks,omitempty"`
+	AggrWriteTicks  int64   `json:"aggr_write_ticks,omitempty"`
+	AggrInQueue     int64   `json:"aggr_in_queue,omitempty"`
+	AggrUtil        float64 `json:"aggr_util,omitempty"`
 }
 
 func (d FioDiskUtil) Print() string {
@@ -0,0 +1,114 @@
+package fio
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+// loadFioOutput parses a fixture of testdata. The fixtures are hand-built in the JSON layout of
+// the fio version they are named after, they are not captured from fio runs.
+func loadFioOutput(c *C, name string) FioResult {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	c.Assert(err, IsNil)
+	var result FioResult
+	c.Assert(json.Unmarshal(data, &result), IsNil)
+	return result
+}
+
+func (s *FIOTestSuite) TestParseFio31(c *C) {
+	result := loadFioOutput(c, "fio-3.1.json")
+	c.Assert(result.FioVersion, Equals, "fio-3.1")
+	c.Assert(result.GlobalOptions.IOEngine, Equals, "sync")
+	c.Assert(result.GlobalOptions.Options["fdatasync"], Equals, "1")
+	c.Assert(result.Jobs, HasLen, 1)
+	job := result.Jobs[0]
+	c.Assert(job.JobOptions.Options, DeepEquals, map[string]string{"name": "wal", "rw": "write", "bs": "2300", "size": "22m"})
+	// the number of latency samples is missing before it was reported
+	c.Assert(job.Write.ClatNs.N, Equals, int64(10029))
+	c.Assert(job.Write.ClatNs.Percentile["99.000000"], Equals, float64(39680))
+	c.Assert(job.Read.ClatNs.N, Equals, int64(0))
+	c.Assert(job.UsrCpu, Equals, 1.061329)
+	c.Assert(result.DiskUtil[0].Util, Equals, 86.341107)
+}
+
+func (s *FIOTestSuite) TestParseFio328Numjobs(c *C) {
+	result := loadFioOutput(c, "fio-3.28-numjobs.json")
+	c.Assert(result.Jobs, HasLen, 2)
+	job := result.Jobs[0]
+	// values beyond 32 bits and the full precision of floats are kept
+	c.Assert(job.Read.IOBytes, Equals, int64(3221225472))
+	c.Assert(job.Read.Iops, Equals, 13107.218546)
+	c.Assert(job.JobRuntime, Equals, int64(60000))
+	c.Assert(job.JobStart, Equals, int64(1700000000123))
+	c.Assert(job.JobOptions.Options["rwmixread"], Equals, "70")
+	c.Assert(job.JobOptions.Options["numjobs"], Equals, "2")
+	c.Assert(job.JobOptions.Options["time_based"], Equals, "")
+	c.Assert(job.Sync.TotalIos, Equals, int64(10535))
+	c.Assert(job.Sync.LatNs.Percentile["99.000000"], Equals, float64(4489216))
+	c.Assert(result.GlobalOptions.Options["thread"], Equals, "")
+
+	grouped := result.Grouped()
+	c.Assert(grouped.Jobs, HasLen, 1)
+	c.Assert(grouped.Jobs[0].Read.IOBytes, Equals, int64(3221225472+3145728000))
+	c.Assert(grouped.Jobs[0].Read.Iops, Equals, 13107.218546+12799.986667)
+	c.Assert(grouped.Jobs[0].Sync.TotalIos, Equals, int64(10535+10287))
+	c.Assert(grouped.Jobs[0].UsrCpu, Equals, (3.412+3.388)/2)
+	c.Assert(grouped.Jobs[0].Write.ClatNs.N, Equals, job.Write.ClatNs.N+result.Jobs[1].Write.ClatNs.N)
+	// the clones are not changed
+	c.Assert(result.Jobs[0].Read.IOBytes, Equals, int64(3221225472))
+
+	thresholds := EvaluateThresholds(result, []Threshold{
+		{Metric: ThresholdIOPS, Selector: "read", Min: "20000"},
+		{Metric: ThresholdLatP99, Selector: "read", Max: "1s"},
+	})
+	c.Assert(thresholds, HasLen, 2)
+	c.Assert(thresholds[0].Status, Equals, ThresholdOK)
+	// without json+ bins the percentiles of the clones can't be recomputed, the highest is used
+	p99 := max(job.Read.ClatNs.Percentile["99.000000"], result.Jobs[1].Read.ClatNs.Percentile["99.000000"])
+	c.Assert(thresholds[1].Status, Equals, ThresholdOK)
+	c.Assert(thresholds[1].Value, Equals, p99)
+	c.Assert(thresholds[1].Message, Matches, ".*approximate: highest of the merged jobs.*")
+	c.Assert(grouped.Jobs[0].Read.printLatency(), Matches, "(?s).*clat percentiles\\(usec, approximate: highest of the merged jobs\\): p50=.*")
+}
+
+func (s *FIOTestSuite) TestParseFio336GroupReporting(c *C) {
+	result := loadFioOutput(c, "fio-3.36-group-reporting.json")
+	c.Assert(result.Jobs, HasLen, 1)
+	c.Assert(result.GlobalOptions.Options["group_reporting"], Equals, "")
+	c.Assert(result.Grouped(), DeepEquals, result)
+	c.Assert(result.DiskUtil[0].AggrReadIos, Equals, int64(1554512))
+	c.Assert(result.DiskUtil[0].AggrWriteMerges, Equals, int64(12))
+	c.Assert(result.DiskUtil[0].AggrUtil, Equals, 99.871234)
+}
+
+func (s *FIOTestSuite) TestFioOptionsRoundTrip(c *C) {
+	result := loadFioOutput(c, "fio-3.28-numjobs.json")
+	options := result.Jobs[0].JobOptions
+	options.BS = "8k"
+	data, err := json.Marshal(options)
+	c.Assert(err, IsNil)
+	var decoded map[string]string
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+	c.Assert(decoded["rwmixread"], Equals, "70")
+	c.Assert(decoded["bs"], Equals, "8k")
+	c.Assert(decoded, HasLen, len(options.Options))
+
+	data, err = json.Marshal(FioJobOptions{Name: "job"})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `{"name":"job"}`)
+
+	var tolerant FioJobOptions
+	c.Assert(json.Unmarshal([]byte(`{"name":"job","numjobs":4}`), &tolerant), IsNil)
+	c.Assert(tolerant.Options["numjobs"], Equals, "4")
+}
+
+func (s *FIOTestSuite) TestFioJobOptionsPrint(c *C) {
+	result := loadFioOutput(c, "fio-3.1.json")
+	c.Assert(result.Jobs[0].JobOptions.Print(), Equals, "JobName: wal\n  blocksize=2300 filesize=22m iodepth= rw=write")
+	result = loadFioOutput(c, "fio-3.28-numjobs.json")
+	c.Assert(result.Jobs[0].JobOptions.Print(), Equals, "JobName: randrw\n  blocksize=4k filesize=4G iodepth=16 rw=randrw\n"+
+		"  fsync=32 ioengine=libaio numjobs=2 runtime=60s rwmixread=70 time_based=")
+}
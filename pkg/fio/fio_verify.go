@@ -0,0 +1,73 @@
+package fio
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+const (
+	// IntegrityFIOJob is the predefined test that writes data and reads it back to verify it
+	IntegrityFIOJob = "integrity"
+	// VerifyMethod is the checksum fio writes into every block it verifies
+	VerifyMethod = "crc32c"
+	// defaultVerifyBacklog is the backlog used for time based jobs, which never reach a verify phase
+	defaultVerifyBacklog = 1024
+)
+
+// FioJobError is a job that fio reported an error for, e.g. data that failed verification
+type FioJobError struct {
+	Job     string `json:"job"`
+	Errno   int    `json:"errno"`
+	Message string `json:"message"`
+}
+
+func (e FioJobError) String() string {
+	return fmt.Sprintf("job (%s) failed with error %d (%s)", e.Job, e.Errno, e.Message)
+}
+
+// JobErrors returns the jobs that ended with an error
+func (f FioResult) JobErrors() []FioJobError {
+	var errs []FioJobError
+	for _, job := range f.Jobs {
+		if job.Error == 0 {
+			continue
+		}
+		errs = append(errs, FioJobError{
+			Job:     job.name(),
+			Errno:   job.Error,
+			Message: syscall.Errno(job.Error).Error(),
+		})
+	}
+	return errs
+}
+
+// verifyFioJob makes every job of the job file that writes read its data back and check it.
+// With a backlog, written blocks are verified while the job runs instead of afterwards.
+func verifyFioJob(config string, backlog int64) (string, error) {
+	return appendJobOptions(config, func(job FioJob) []string {
+		if !jobWrites(job) {
+			return nil
+		}
+		options := []string{"verify=" + VerifyMethod, "do_verify=1"}
+		jobBacklog := backlog
+		if _, timeBased := job.Option("time_based"); timeBased && jobBacklog == 0 {
+			jobBacklog = defaultVerifyBacklog
+		}
+		if jobBacklog > 0 {
+			options = append(options, fmt.Sprintf("verify_backlog=%d", jobBacklog))
+		}
+		return options
+	})
+}
+
+// jobWrites reports whether a job writes, fio reads when rw isn't set
+func jobWrites(job FioJob) bool {
+	for _, key := range []string{"rw", "readwrite"} {
+		if rw, ok := job.Option(key); ok {
+			mode, _, _ := strings.Cut(rw.Value, ":")
+			return mode != "read" && mode != "randread"
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FIOTestSuite) TestVerifyFioJob(c *C) {
+	config, err := verifyFioJob(`[global]
+verify=0
+[reader]
+rw=randread
+[writer]
+rw=randwrite
+bs=4k
+
+[timed]
+rw=write
+time_based
+runtime=60s
+`, 0)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, `[global]
+verify=0
+[reader]
+rw=randread
+[writer]
+rw=randwrite
+bs=4k
+verify=crc32c
+do_verify=1
+
+[timed]
+rw=write
+time_based
+runtime=60s
+verify=crc32c
+do_verify=1
+verify_backlog=1024
+`)
+
+	config, err = verifyFioJob("[global]\nrw=write\n[job]\nbs=4k", 64)
+	c.Assert(err, IsNil)
+	c.Assert(config, Equals, "[global]\nrw=write\n[job]\nbs=4k\nverify=crc32c\ndo_verify=1\nverify_backlog=64")
+
+	_, err = verifyFioJob("[job\nrw=write", 0)
+	c.Assert(err, NotNil)
+}
+
+func (s *FIOTestSuite) TestFioJobConfigVerify(c *C) {
+	_, config, err := fioJobConfig(&RunFIOArgs{FIOJobName: "randrw", Verify: true, VerifyBacklog: 32})
+	c.Assert(err, IsNil)
+	file, err := ParseFioJobFile(config)
+	c.Assert(err, IsNil)
+	for _, job := range file.Jobs() {
+		verify, ok := job.Option("verify")
+		c.Assert(ok, Equals, true)
+		c.Assert(verify.Value, Equals, VerifyMethod)
+		backlog, ok := job.Option("verify_backlog")
+		c.Assert(ok, Equals, true)
+		c.Assert(backlog.Value, Equals, "32")
+	}
+
+	args := RunFIOArgs{StorageClass: "sc", Size: "1Gi", Namespace: "ns", VerifyBacklog: 32}
+	c.Assert(args.Validate(), NotNil)
+	args.Verify = true
+	c.Assert(args.Validate(), IsNil)
+}
+
+func (s *FIOTestSuite) TestJobErrors(c *C) {
+	result := FioResult{Jobs: []FioJobs{
+		{JobName: "ok"},
+		{JobName: "corrupt", Error: 84},
+	}}
+	errs := result.JobErrors()
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs[0].Job, Equals, "corrupt")
+	c.Assert(errs[0].Errno, Equals, 84)
+	c.Assert(errs[0].Message, Not(Equals), "")
+	c.Assert(FioResult{}.JobErrors(), IsNil)
+}
+
+func (s *FIOTestSuite) TestRunFioCommandVerifyFailure(c *C) {
+	executor := &fakeKubeExecutor{
+		keErr:    fmt.Errorf("command terminated with exit code 1"),
+		keStrErr: "verify: bad header rand_seed",
+		keStdOut: `{"jobs":[{"jobname":"rand_write_verify","error":84}]}`,
+	}
+	stepper := &fioStepper{kubeExecutor: executor}
+	out, err := stepper.runFIOCommand(context.Background(), "pod", "container", "tfName", DefaultNS, nil, fioRunOptions{outputFormat: OutputFormatJSON})
+	c.Assert(err, IsNil)
+	c.Assert(out.JobErrors(), HasLen, 1)
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+)
+
+// DefaultTolerance is how much worse than the baseline a metric may get before it is a regression
+const DefaultTolerance = 0.1
+
+// MetricDelta is the change of a metric from the baseline run to the compared run
+type MetricDelta struct {
+	Metric     Metric  `json:"metric"` // the metric of the compared run
+	Base       float64 `json:"base"`
+	Change     float64 `json:"change"` // relative to the baseline, 0.1 is 10% more
+	Regression bool    `json:"regression,omitempty"`
+}
+
+// Comparison compares the metrics of a run with those of a baseline run
+type Comparison struct {
+	Base      *Record       `json:"base"`
+	Current   *Record       `json:"current"`
+	Tolerance float64       `json:"tolerance"`
+	Deltas    []MetricDelta `json:"deltas"`
+	Missing   []string      `json:"missing,omitempty"` // metrics of the baseline the compared run doesn't have
+}
+
+// Compare compares every metric of the run with the baseline. A metric regressed when it is worse
+// than the baseline by more than the tolerance, e.g. 0.1 for 10%.
+func Compare(base, current *Record, tolerance float64) (*Comparison, error) {
+	if base.Kind != current.Kind {
+		return nil, fmt.Errorf("can't compare a %s run (%s) with a %s run (%s)", current.Kind, current.ID, base.Kind, base.ID)
+	}
+	if tolerance < 0 {
+		return nil, fmt.Errorf("the tolerance (%v) can't be negative", tolerance)
+	}
+	c := &Comparison{Base: base, Current: current, Tolerance: tolerance}
+	for _, m := range current.Metrics {
+		b, ok := base.Metric(m.Name)
+		if !ok {
+			continue
+		}
+		delta := MetricDelta{Metric: m, Base: b.Value}
+		if b.Value != 0 {
+			delta.Change = (m.Value - b.Value) / b.Value
+			if m.HigherIsBetter {
+				delta.Regression = delta.Change < -tolerance
+			} else {
+				delta.Regression = delta.Change > tolerance
+			}
+		}
+		c.Deltas = append(c.Deltas, delta)
+	}
+	for _, b := range base.Metrics {
+		if _, ok := current.Metric(b.Name); !ok {
+			c.Missing = append(c.Missing, b.Name)
+		}
+	}
+	return c, nil
+}
+
+// Regressions returns the metrics that regressed
+func (c *Comparison) Regressions() []MetricDelta {
+	var regressions []MetricDelta
+	for _, d := range c.Deltas {
+		if d.Regression {
+			regressions = append(regressions, d)
+		}
+	}
+	return regressions
+}
+
+// Print renders a table of the metrics with their change from the baseline
+func (c *Comparison) Print() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Baseline %s (%s, %s)\n", c.Base.ID, c.Base.Timestamp.Format("2006-01-02 15:04"), c.Base.Describe())
+	fmt.Fprintf(&buf, "Compared %s (%s, %s)\n\n", c.Current.ID, c.Current.Timestamp.Format("2006-01-02 15:04"), c.Current.Describe())
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Metric\tBaseline\tCompared\tChange\t")
+	for _, d := range c.Deltas {
+		change := "-"
+		if d.Base != 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*d.Change)
+		}
+		if d.Regression {
+			change += " REGRESSION"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t\n", d.Metric.Name, d.Metric.Format(d.Base), d.Metric.Format(d.Metric.Value), change)
+	}
+	_ = w.Flush()
+	for _, name := range c.Missing {
+		fmt.Fprintf(&buf, "  %s: not reported by the compared run\n", name)
+	}
+	fmt.Fprintf(&buf, "\n%d of %d metrics regressed by more than %.0f%%\n", len(c.Regressions()), len(c.Deltas), 100*c.Tolerance)
+	return buf.String()
+}
@@ -0,0 +1,58 @@
+package history
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCompare(t *testing.T) {
+	c := qt.New(t)
+	iops := func(v float64) Metric {
+		return Metric{Name: "randread.read.iops", Value: v, Unit: UnitIOPS, HigherIsBetter: true}
+	}
+	latency := func(v float64) Metric {
+		return Metric{Name: "randread.read.lat_mean", Value: v, Unit: UnitNanoseconds}
+	}
+	base := &Record{ID: "a", Kind: KindFio, Metrics: []Metric{iops(1000), latency(1e6), {Name: "randwrite.write.iops", Value: 10}}}
+
+	for _, tc := range []struct {
+		name        string
+		metrics     []Metric
+		regressions int
+	}{
+		{name: "within-tolerance", metrics: []Metric{iops(950), latency(1.05e6)}},
+		{name: "faster", metrics: []Metric{iops(2000), latency(0.5e6)}},
+		{name: "fewer-iops", metrics: []Metric{iops(800), latency(1e6)}, regressions: 1},
+		{name: "slower", metrics: []Metric{iops(1000), latency(1.2e6)}, regressions: 1},
+	} {
+		c.Run(tc.name, func(c *qt.C) {
+			current := &Record{ID: "b", Kind: KindFio, Metrics: tc.metrics}
+			comparison, err := Compare(base, current, DefaultTolerance)
+			c.Assert(err, qt.IsNil)
+			c.Assert(comparison.Deltas, qt.HasLen, 2)
+			c.Assert(comparison.Regressions(), qt.HasLen, tc.regressions)
+			c.Assert(comparison.Missing, qt.DeepEquals, []string{"randwrite.write.iops"})
+		})
+	}
+
+	comparison, err := Compare(base, &Record{ID: "b", Kind: KindFio, Metrics: []Metric{iops(800), latency(1e6)}}, 0.25)
+	c.Assert(err, qt.IsNil)
+	c.Assert(comparison.Regressions(), qt.HasLen, 0)
+	c.Assert(comparison.Deltas[0].Change, qt.Equals, -0.2)
+	out := comparison.Print()
+	for _, line := range []string{
+		"randread.read.iops      1000.00 IOPS  800.00 IOPS  -20.0%",
+		"randread.read.lat_mean  1ms           1ms          +0.0%",
+		"randwrite.write.iops: not reported by the compared run",
+		"0 of 2 metrics regressed by more than 25%",
+	} {
+		c.Check(strings.Contains(out, line), qt.IsTrue, qt.Commentf("missing %q in:\n%s", line, out))
+	}
+
+	_, err = Compare(base, &Record{ID: "b", Kind: KindCSICheck}, DefaultTolerance)
+	c.Assert(err, qt.IsNotNil)
+	_, err = Compare(base, base, -1)
+	c.Assert(err, qt.IsNotNil)
+}
@@ -0,0 +1,222 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RecordVersion is the version of the record format written by this kubestr
+const RecordVersion = 1
+
+// Kind is the check a record holds the results of
+type Kind string
+
+const (
+	// KindFio is a fio run
+	KindFio = Kind("fio")
+	// KindCSICheck is a CSI snapshot and restore check
+	KindCSICheck = Kind("csicheck")
+	// KindBlockMount is a block mount check
+	KindBlockMount = Kind("blockmount")
+)
+
+// Units of the metrics of a record
+const (
+	UnitIOPS           = "IOPS"
+	UnitBytesPerSecond = "B/s"
+	UnitNanoseconds    = "ns"
+)
+
+// recordExt is the extension of the record files in a store
+const recordExt = ".json"
+
+// Metric is a single value recorded for a run
+type Metric struct {
+	Name           string  `json:"name"`
+	Value          float64 `json:"value"`
+	Unit           string  `json:"unit"`
+	HigherIsBetter bool    `json:"higherIsBetter,omitempty"`
+}
+
+// Format formats the value of the metric in its unit
+func (m Metric) Format(value float64) string {
+	if m.Unit == UnitNanoseconds {
+		return time.Duration(value).String()
+	}
+	return fmt.Sprintf("%.2f %s", value, m.Unit)
+}
+
+// Record is a run saved to the history, with what it ran against
+type Record struct {
+	Version       int             `json:"version"`
+	ID            string          `json:"id"`
+	Kind          Kind            `json:"kind"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Cluster       string          `json:"cluster,omitempty"`
+	StorageClass  string          `json:"storageClass,omitempty"`
+	Driver        string          `json:"driver,omitempty"`
+	DriverVersion string          `json:"driverVersion,omitempty"`
+	Job           string          `json:"job,omitempty"`
+	Metrics       []Metric        `json:"metrics"`
+	Result        json.RawMessage `json:"result,omitempty"` // the result of the check as kubestr reported it
+}
+
+// newRecord creates a record of a check run now, the raw result is kept with the metrics
+func newRecord(kind Kind, storageClass string, metrics []Metric, result interface{}) (*Record, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the result")
+	}
+	return &Record{
+		Version:      RecordVersion,
+		Kind:         kind,
+		Timestamp:    time.Now().UTC(),
+		StorageClass: storageClass,
+		Metrics:      metrics,
+		Result:       raw,
+	}, nil
+}
+
+// Metric looks up a metric of the record by name
+func (r *Record) Metric(name string) (Metric, bool) {
+	for _, m := range r.Metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// Describe summarises what the run ran against
+func (r *Record) Describe() string {
+	parts := []string{string(r.Kind)}
+	if r.Job != "" {
+		parts = append(parts, r.Job)
+	}
+	if r.StorageClass != "" {
+		parts = append(parts, "StorageClass "+r.StorageClass)
+	}
+	if r.Driver != "" {
+		driver := r.Driver
+		if r.DriverVersion != "" {
+			driver += " " + r.DriverVersion
+		}
+		parts = append(parts, driver)
+	}
+	if r.Cluster != "" {
+		parts = append(parts, "on "+r.Cluster)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Store is a directory of records, one JSON file per run
+type Store struct {
+	dir string
+}
+
+// DefaultDir is where records are stored unless another directory is given
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".kubestr", "history")
+	}
+	return filepath.Join(home, ".kubestr", "history")
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes a record to the store. A record without an id is given one from its time and kind.
+func (s *Store) Save(r *Record) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the history directory (%s)", s.dir)
+	}
+	if r.ID == "" {
+		r.ID = s.newID(r)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal record (%s)", r.ID)
+	}
+	if err := os.WriteFile(s.path(r.ID), data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write record (%s)", r.ID)
+	}
+	return nil
+}
+
+// newID names a record after its time and kind, with a suffix when the name is taken
+func (s *Store) newID(r *Record) string {
+	base := fmt.Sprintf("%s-%s", r.Timestamp.UTC().Format("20060102-150405"), r.Kind)
+	id := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(s.path(id)); os.IsNotExist(err) {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+recordExt)
+}
+
+// Load reads a record by id
+func (s *Store) Load(id string) (*Record, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid record id (%s)", id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no record (%s) in (%s)", id, s.dir)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read record (%s)", id)
+	}
+	return parseRecord(id, data)
+}
+
+func parseRecord(id string, data []byte) (*Record, error) {
+	r := &Record{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse record (%s)", id)
+	}
+	if r.Version < 1 || r.Version > RecordVersion {
+		return nil, fmt.Errorf("record (%s) has version %d, supported versions are 1 to %d", id, r.Version, RecordVersion)
+	}
+	r.ID = id
+	return r, nil
+}
+
+// List reads every record in the store, oldest first. An empty or missing store has no records.
+func (s *Store) List() ([]*Record, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the history directory (%s)", s.dir)
+	}
+	var records []*Record
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != recordExt {
+			continue
+		}
+		r, err := s.Load(strings.TrimSuffix(entry.Name(), recordExt))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
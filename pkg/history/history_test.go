@@ -0,0 +1,125 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/kastenhq/kubestr/pkg/block"
+	"github.com/kastenhq/kubestr/pkg/csi/types"
+	"github.com/kastenhq/kubestr/pkg/fio"
+	sv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStore(t *testing.T) {
+	c := qt.New(t)
+	dir := filepath.Join(t.TempDir(), "history")
+	store := NewStore(dir)
+
+	// a missing store has no records
+	records, err := store.List()
+	c.Assert(err, qt.IsNil)
+	c.Assert(records, qt.HasLen, 0)
+
+	ts := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	later := &Record{Version: RecordVersion, Kind: KindCSICheck, Timestamp: ts.Add(time.Hour), StorageClass: "fast"}
+	first := &Record{Version: RecordVersion, Kind: KindFio, Timestamp: ts, Metrics: []Metric{{Name: "a", Value: 1}}}
+	again := &Record{Version: RecordVersion, Kind: KindFio, Timestamp: ts}
+	for _, r := range []*Record{later, first, again} {
+		c.Assert(store.Save(r), qt.IsNil)
+	}
+	c.Assert(first.ID, qt.Equals, "20260701-120000-fio")
+	c.Assert(again.ID, qt.Equals, "20260701-120000-fio-2")
+
+	records, err = store.List()
+	c.Assert(err, qt.IsNil)
+	c.Assert(records, qt.HasLen, 3)
+	c.Assert(records[2].ID, qt.Equals, later.ID)
+	c.Assert(records[2].StorageClass, qt.Equals, "fast")
+
+	loaded, err := store.Load(first.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(loaded.Metrics, qt.DeepEquals, first.Metrics)
+
+	_, err = store.Load("missing")
+	c.Assert(err, qt.ErrorMatches, "no record \\(missing\\) in .*")
+	_, err = store.Load("../history/" + first.ID)
+	c.Assert(err, qt.IsNotNil)
+
+	// records written by a newer kubestr are refused
+	c.Assert(os.WriteFile(filepath.Join(dir, "future.json"), []byte(`{"version": 2, "kind": "fio"}`), 0644), qt.IsNil)
+	_, err = store.Load("future")
+	c.Assert(err, qt.ErrorMatches, "record \\(future\\) has version 2.*")
+}
+
+func TestFioRecord(t *testing.T) {
+	c := qt.New(t)
+	result := &fio.RunFIOResult{
+		StorageClass: &sv1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: "ebs.csi.aws.com"},
+		Result: fio.FioResult{Jobs: []fio.FioJobs{{
+			JobName: "randread",
+			Read:    fio.FioStats{TotalIos: 10, Iops: 100, BWBytes: 409600, ClatNs: fio.FioNS{N: 10, Mean: 1000}},
+		}}},
+	}
+	r, err := FioRecord("default-fio", result)
+	c.Assert(err, qt.IsNil)
+	c.Assert(r.Version, qt.Equals, RecordVersion)
+	c.Assert(r.Kind, qt.Equals, KindFio)
+	c.Assert(r.Job, qt.Equals, "default-fio")
+	c.Assert(r.StorageClass, qt.Equals, "fast")
+	c.Assert(r.Driver, qt.Equals, "ebs.csi.aws.com")
+	c.Assert(r.Metrics, qt.DeepEquals, []Metric{
+		{Name: "randread.read.iops", Value: 100, Unit: UnitIOPS, HigherIsBetter: true},
+		{Name: "randread.read.bw", Value: 409600, Unit: UnitBytesPerSecond, HigherIsBetter: true},
+		{Name: "randread.read.lat_mean", Value: 1000, Unit: UnitNanoseconds},
+	})
+	c.Assert(len(r.Result) > 0, qt.IsTrue)
+}
+
+func TestCheckRecords(t *testing.T) {
+	c := qt.New(t)
+	r, err := CSICheckRecord("fast", &types.CSISnapshotRestoreResults{Timings: types.CSISnapshotRestoreTimings{
+		CreateApplication:   2 * time.Second,
+		SnapshotApplication: 3 * time.Second,
+	}})
+	c.Assert(err, qt.IsNil)
+	c.Assert(r.Kind, qt.Equals, KindCSICheck)
+	c.Assert(r.Metrics, qt.DeepEquals, []Metric{
+		{Name: "create_application", Value: 2e9, Unit: UnitNanoseconds},
+		{Name: "snapshot_application", Value: 3e9, Unit: UnitNanoseconds},
+	})
+
+	r, err = BlockMountRecord("fast", &block.BlockMountCheckerResult{
+		StorageClass: &sv1.StorageClass{Provisioner: "rbd.csi.ceph.com"},
+		Provision:    time.Second,
+		PodReady:     4 * time.Second,
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(r.Kind, qt.Equals, KindBlockMount)
+	c.Assert(r.Driver, qt.Equals, "rbd.csi.ceph.com")
+	c.Assert(r.Metrics, qt.HasLen, 2)
+	c.Assert(r.Metrics[1].Format(r.Metrics[1].Value), qt.Equals, "4s")
+}
+
+func TestDescribeDriver(t *testing.T) {
+	c := qt.New(t)
+	cli := fake.NewSimpleClientset(
+		&sv1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: "ebs.csi.aws.com"},
+		&sv1.CSIDriver{ObjectMeta: metav1.ObjectMeta{Name: "ebs.csi.aws.com", Labels: map[string]string{DriverVersionLabel: "1.35.0"}}},
+	)
+	r := &Record{Kind: KindCSICheck, StorageClass: "fast"}
+	DescribeDriver(context.Background(), cli, r)
+	c.Assert(r.Driver, qt.Equals, "ebs.csi.aws.com")
+	c.Assert(r.DriverVersion, qt.Equals, "1.35.0")
+	c.Assert(r.Describe(), qt.Equals, "csicheck, StorageClass fast, ebs.csi.aws.com 1.35.0")
+
+	// what can't be looked up is left empty
+	r = &Record{Kind: KindCSICheck, StorageClass: "missing"}
+	DescribeDriver(context.Background(), cli, r)
+	c.Assert(r.Driver, qt.Equals, "")
+}
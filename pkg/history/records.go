@@ -0,0 +1,94 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/kastenhq/kubestr/pkg/block"
+	"github.com/kastenhq/kubestr/pkg/csi/types"
+	"github.com/kastenhq/kubestr/pkg/fio"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DriverVersionLabel is the label of a CSIDriver object the version of the driver is read from
+const DriverVersionLabel = "app.kubernetes.io/version"
+
+// FioRecord records the IOPS, bandwidth and latencies of every job and direction of a fio run,
+// named <job>.<direction>.<metric>
+func FioRecord(job string, result *fio.RunFIOResult) (*Record, error) {
+	var metrics []Metric
+	for _, m := range result.Result.JobMetrics() {
+		metric := Metric{
+			Name:  m.Job + "." + m.Direction + "." + string(m.Metric),
+			Value: m.Value,
+			Unit:  UnitNanoseconds,
+		}
+		switch m.Metric {
+		case fio.ThresholdIOPS:
+			metric.Unit, metric.HigherIsBetter = UnitIOPS, true
+		case fio.ThresholdBW:
+			metric.Unit, metric.HigherIsBetter = UnitBytesPerSecond, true
+		}
+		metrics = append(metrics, metric)
+	}
+	var storageClass, driver string
+	if result.StorageClass != nil {
+		storageClass, driver = result.StorageClass.Name, result.StorageClass.Provisioner
+	}
+	r, err := newRecord(KindFio, storageClass, metrics, result)
+	if err != nil {
+		return nil, err
+	}
+	r.Job, r.Driver = job, driver
+	return r, nil
+}
+
+// CSICheckRecord records the time each step of a snapshot and restore check took
+func CSICheckRecord(storageClass string, result *types.CSISnapshotRestoreResults) (*Record, error) {
+	var metrics []Metric
+	metrics = appendDuration(metrics, "create_application", result.Timings.CreateApplication)
+	metrics = appendDuration(metrics, "snapshot_application", result.Timings.SnapshotApplication)
+	metrics = appendDuration(metrics, "restore_application", result.Timings.RestoreApplication)
+	return newRecord(KindCSICheck, storageClass, metrics, result)
+}
+
+// BlockMountRecord records the time it took to provision a block volume and mount it in a pod
+func BlockMountRecord(storageClass string, result *block.BlockMountCheckerResult) (*Record, error) {
+	var metrics []Metric
+	metrics = appendDuration(metrics, "provision", result.Provision)
+	metrics = appendDuration(metrics, "pod_ready", result.PodReady)
+	r, err := newRecord(KindBlockMount, storageClass, metrics, result)
+	if err != nil {
+		return nil, err
+	}
+	if result.StorageClass != nil {
+		r.Driver = result.StorageClass.Provisioner
+	}
+	return r, nil
+}
+
+// appendDuration records the duration of a step if it ran, shorter is better
+func appendDuration(metrics []Metric, name string, d time.Duration) []Metric {
+	if d <= 0 {
+		return metrics
+	}
+	return append(metrics, Metric{Name: name, Value: float64(d), Unit: UnitNanoseconds})
+}
+
+// DescribeDriver fills in the driver of the StorageClass of the record and its version, which is
+// read from the DriverVersionLabel of the CSIDriver object. Drivers installed from charts usually
+// set it. What can't be looked up is left empty.
+func DescribeDriver(ctx context.Context, cli kubernetes.Interface, r *Record) {
+	if r.Driver == "" && r.StorageClass != "" {
+		if sc, err := cli.StorageV1().StorageClasses().Get(ctx, r.StorageClass, metav1.GetOptions{}); err == nil {
+			r.Driver = sc.Provisioner
+		}
+	}
+	if r.Driver == "" || r.DriverVersion != "" {
+		return
+	}
+	if driver, err := cli.StorageV1().CSIDrivers().Get(ctx, r.Driver, metav1.GetOptions{}); err == nil {
+		r.DriverVersion = driver.Labels[DriverVersionLabel]
+	}
+}
@@ -89,3 +89,12 @@ func LoadKubeCli() (kubernetes.Interface, error) {
 	}
 	return clientset, nil
 }
+
+// ClusterHost returns the address of the API server of the cluster the config points at
+func ClusterHost() (string, error) {
+	config, err := kube.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	return config.Host, nil
+}